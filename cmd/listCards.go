@@ -3,16 +3,15 @@ package main
 import (
 	"fmt"
 
-	"github.com/yobert/alsa"
+	alsautil "github.com/renan-campos/sound-utils/pkg/alsa"
 )
 
 func main() {
-	cards, err := alsa.OpenCards()
+	cards, err := alsautil.ListCards()
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-	defer alsa.CloseCards(cards)
 
 	for _, card := range cards {
 		fmt.Println(card)
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/pkg/errors"
 	"github.com/renan-campos/sound-utils/pkg/alsa"
 	. "github.com/renan-campos/sound-utils/pkg/logging"
 )
@@ -24,9 +23,9 @@ func main() {
 		os.Exit(1)
 	}
 
-	devices, err := card.Devices()
+	devices, err := alsa.ListDevices(card)
 	if err != nil {
-		Stderr(errors.Wrap(err, "Failed to get card devices").Error())
+		Stderr(err.Error())
 		os.Exit(1)
 	}
 	fmt.Println("===", card, "Device List ===")
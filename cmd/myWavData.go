@@ -38,8 +38,11 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"sort"
+	"strings"
 )
 
 func main() {
@@ -56,17 +59,21 @@ func main() {
 	}
 	w.PrintRiffChunk()
 
-	err = w.ReadFmtChunk()
+	err = w.ReadChunks()
 	if err != nil {
 		log.Fatal(err)
 	}
 	w.PrintFmtChunk()
+	w.PrintFactChunk()
+	w.PrintInfoChunk()
+	w.PrintDataChunk()
 
-	err = w.ReadDataChunk()
-	if err != nil {
-		log.Fatal(err)
+	// A second file name writes the chunks back out to it.
+	if len(os.Args) > 2 {
+		if err := w.Save(os.Args[2]); err != nil {
+			log.Fatal(err)
+		}
 	}
-	w.PrintDataChunk()
 }
 
 func bytesToLittleEndianInt(buffer []byte) int {
@@ -97,6 +104,13 @@ type FmtChunk struct {
 	ExtraData     []byte // For when the format chunk is greated than 16
 }
 
+// FactChunk is in compressed and float files, holding the number of samples per channel.
+type FactChunk struct {
+	ChunkID      []byte // big endian.    "fact"
+	ChunkSize    []byte // little endian.
+	SampleLength []byte // little endian.
+}
+
 type DataChunk struct {
 	Subchunk2ID   []byte // big endian.    "data"
 	Subchunk2Size []byte // little endian.
@@ -109,7 +123,17 @@ type Wav struct {
 
 	riffChunk RiffChunk
 	fmtChunk  FmtChunk
+	factChunk FactChunk
 	dataChunk DataChunk
+
+	// dataOffset is where the audio starts, once the data chunk was found.
+	dataOffset int64
+
+	// Metadata holds the tags of the LIST/INFO chunk by id: IART, INAM, ICMT, ICRD...
+	Metadata map[string]string
+
+	// chunks are the ids of the chunks read, in file order, so Save writes them back the same way.
+	chunks []string
 }
 
 func newWav(fileName string) *Wav {
@@ -161,23 +185,14 @@ func (w *Wav) PrintRiffChunk() {
 	)
 }
 
-func (w *Wav) ReadFmtChunk() error {
-	w.fmtChunk = FmtChunk{
-		Subchunk1ID:   make([]byte, 4),
-		Subchunk1Size: make([]byte, 4),
-		AudioFormat:   make([]byte, 2),
-		NumChannels:   make([]byte, 2),
-		SampleRate:    make([]byte, 4),
-		ByteRate:      make([]byte, 4),
-		BlockAlign:    make([]byte, 2),
-		BitsPerSample: make([]byte, 2),
-	}
-	if _, err := w.fp.Read(w.fmtChunk.Subchunk1ID); err != nil {
-		return fmt.Errorf("Failed to read fmt chunk: %v", err)
-	}
-	if _, err := w.fp.Read(w.fmtChunk.Subchunk1Size); err != nil {
-		return fmt.Errorf("Failed to read fmt chunk: %v", err)
-	}
+// readFmtBody reads the fields of the fmt chunk, once its id and size are in fmtChunk.
+func (w *Wav) readFmtBody() error {
+	w.fmtChunk.AudioFormat = make([]byte, 2)
+	w.fmtChunk.NumChannels = make([]byte, 2)
+	w.fmtChunk.SampleRate = make([]byte, 4)
+	w.fmtChunk.ByteRate = make([]byte, 4)
+	w.fmtChunk.BlockAlign = make([]byte, 2)
+	w.fmtChunk.BitsPerSample = make([]byte, 2)
 	if _, err := w.fp.Read(w.fmtChunk.AudioFormat); err != nil {
 		return fmt.Errorf("Failed to read fmt chunk: %v", err)
 	}
@@ -232,18 +247,86 @@ func (w *Wav) PrintFmtChunk() {
 	)
 }
 
-func (w *Wav) ReadDataChunk() error {
-	w.dataChunk = DataChunk{
-		Subchunk2ID:   make([]byte, 4),
-		Subchunk2Size: make([]byte, 4),
+// readChunkHeader reads the 4 byte id and 4 byte size that start every chunk.
+func (w *Wav) readChunkHeader() (id, size []byte, err error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(w.fp, header); err != nil {
+		return nil, nil, err
 	}
-	if _, err := w.fp.Read(w.dataChunk.Subchunk2ID); err != nil {
-		return fmt.Errorf("Failed to read data chunk: %v", err)
+	return header[0:4], header[4:8], nil
+}
+
+// paddedSize is the size a chunk takes up in the file: chunks are word aligned,
+// so an odd sized one is followed by a pad byte that isn't counted in its size.
+func paddedSize(size []byte) int64 {
+	n := int64(bytesToLittleEndianInt(size))
+	return n + n%2
+}
+
+// ReadChunks reads every chunk after the RIFF header, in whatever order they come.
+// fmt, fact, data and LIST chunks are read, anything else is skipped over.
+// Only the header of the data chunk is read, the audio is skipped like the unknown chunks.
+func (w *Wav) ReadChunks() error {
+	if _, err := w.fp.Seek(12, io.SeekStart); err != nil {
+		return fmt.Errorf("Failed to read chunks: %v", err)
 	}
-	if _, err := w.fp.Read(w.dataChunk.Subchunk2Size); err != nil {
-		return fmt.Errorf("Failed to read data chunk: %v", err)
+	w.Metadata = map[string]string{}
+	w.chunks = nil
+	for {
+		id, size, err := w.readChunkHeader()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to read chunk header: %v", err)
+		}
+		start, err := w.fp.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fmt.Errorf("Failed to read chunks: %v", err)
+		}
+
+		known := true
+		switch string(id) {
+		case "fmt ":
+			w.fmtChunk = FmtChunk{Subchunk1ID: id, Subchunk1Size: size}
+			err = w.readFmtBody()
+		case "fact":
+			w.factChunk = FactChunk{ChunkID: id, ChunkSize: size, SampleLength: make([]byte, 4)}
+			_, err = io.ReadFull(w.fp, w.factChunk.SampleLength)
+		case "data":
+			w.dataChunk = DataChunk{Subchunk2ID: id, Subchunk2Size: size}
+			w.dataOffset = start
+		case "LIST":
+			known, err = w.readListBody(bytesToLittleEndianInt(size))
+		default:
+			known = false
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to read %q chunk: %v", id, err)
+		}
+		if known && !w.hasChunk(string(id)) {
+			w.chunks = append(w.chunks, string(id))
+		}
+
+		if _, err := w.fp.Seek(start+paddedSize(size), io.SeekStart); err != nil {
+			return fmt.Errorf("Failed to read chunks: %v", err)
+		}
 	}
-	return nil
+}
+
+func (w *Wav) PrintFactChunk() {
+	if w.factChunk.ChunkID == nil {
+		return
+	}
+	fmt.Printf(`-- FACT CHUNK --
+%20s %s
+%20s %d
+%20s %d
+`,
+		"ChunkID:", w.factChunk.ChunkID,
+		"ChunkSize:", bytesToLittleEndianInt(w.factChunk.ChunkSize),
+		"SampleLength:", bytesToLittleEndianInt(w.factChunk.SampleLength),
+	)
 }
 
 func (w *Wav) PrintDataChunk() {
@@ -255,3 +338,270 @@ func (w *Wav) PrintDataChunk() {
 		"Subchunk2Size:", bytesToLittleEndianInt(w.dataChunk.Subchunk2Size),
 	)
 }
+
+// infoTagNames are the names of the common INFO tags.
+var infoTagNames = map[string]string{
+	"IART": "Artist",
+	"INAM": "Title",
+	"ICMT": "Comment",
+	"ICRD": "Date",
+}
+
+// readListBody reads the body of a LIST chunk, keeping the tags and reporting true if it's an INFO list.
+// A list cut short by the end of the file gives whatever tags are there.
+func (w *Wav) readListBody(size int) (bool, error) {
+	left, err := w.remaining()
+	if err != nil {
+		return false, err
+	}
+	if int64(size) > left {
+		size = int(left)
+	}
+	if size < 4 {
+		return false, nil
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(w.fp, body); err != nil {
+		return false, err
+	}
+	if string(body[0:4]) != "INFO" {
+		return false, nil
+	}
+	w.readInfoTags(body[4:])
+	return true, nil
+}
+
+func (w *Wav) hasChunk(id string) bool {
+	for _, c := range w.chunks {
+		if c == id {
+			return true
+		}
+	}
+	return false
+}
+
+// readInfoTags reads the sub-chunks of a LIST/INFO chunk, each a tag id, a size and a NUL terminated string.
+func (w *Wav) readInfoTags(body []byte) {
+	for len(body) >= 8 {
+		id, size := string(body[0:4]), bytesToLittleEndianInt(body[4:8])
+		body = body[8:]
+		if size > len(body) {
+			size = len(body)
+		}
+		value := string(body[:size])
+		if end := strings.IndexByte(value, 0); end >= 0 {
+			value = value[:end]
+		}
+		w.Metadata[id] = value
+		if size%2 == 1 && size < len(body) {
+			size++
+		}
+		body = body[size:]
+	}
+}
+
+func (w *Wav) PrintInfoChunk() {
+	fmt.Println("-- INFO CHUNK --")
+	ids := make([]string, 0, len(w.Metadata))
+	for id := range w.Metadata {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		name := id
+		if n, ok := infoTagNames[id]; ok {
+			name = n
+		}
+		fmt.Printf("%20s %s\n", name+":", w.Metadata[id])
+	}
+}
+
+// ReadData reads the audio of the data chunk into the struct, so WriteDataChunk writes it back out.
+// The data chunk has to have been found by ReadChunks first.
+// A file cut short gives whatever audio there is.
+func (w *Wav) ReadData() error {
+	if w.dataOffset == 0 {
+		return fmt.Errorf("Failed to read data: no data chunk found yet")
+	}
+	if _, err := w.fp.Seek(w.dataOffset, io.SeekStart); err != nil {
+		return fmt.Errorf("Failed to read data: %v", err)
+	}
+	size := int64(bytesToLittleEndianInt(w.dataChunk.Subchunk2Size))
+	left, err := w.remaining()
+	if err != nil {
+		return fmt.Errorf("Failed to read data: %v", err)
+	}
+	if size > left {
+		size = left
+	}
+	w.dataChunk.data = make([]byte, size)
+	if _, err := io.ReadFull(w.fp, w.dataChunk.data); err != nil {
+		return fmt.Errorf("Failed to read data: %v", err)
+	}
+	return nil
+}
+
+// remaining is how many bytes of the file are left after the current position,
+// which caps what a chunk that declares more than that gets allocated.
+func (w *Wav) remaining() (int64, error) {
+	info, err := w.fp.Stat()
+	if err != nil {
+		return 0, err
+	}
+	pos, err := w.fp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size() - pos, nil
+}
+
+// Create creates the file at path to write the chunks to, replacing any file the Wav had open.
+// The Write methods write the struct fields as they are, so the sizes in them need to be right.
+func (w *Wav) Create(path string) error {
+	fp, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Failed to create wav file: %v", err)
+	}
+	if w.fp != nil {
+		w.fp.Close()
+	}
+	w.FileName = path
+	w.fp = fp
+	return nil
+}
+
+// writeFields writes the fields one after the other. They're already in file byte order:
+// ids as they read, numbers little endian.
+func (w *Wav) writeFields(chunk string, fields ...[]byte) error {
+	for _, field := range fields {
+		if _, err := w.fp.Write(field); err != nil {
+			return fmt.Errorf("Failed to write %s chunk: %v", chunk, err)
+		}
+	}
+	return nil
+}
+
+// writePad writes the pad byte that follows an odd sized chunk.
+func (w *Wav) writePad(chunk string, size []byte) error {
+	if bytesToLittleEndianInt(size)%2 == 0 {
+		return nil
+	}
+	return w.writeFields(chunk, []byte{0})
+}
+
+func (w *Wav) WriteRiffChunk() error {
+	return w.writeFields("riff", w.riffChunk.ChunkID, w.riffChunk.ChunkSize, w.riffChunk.Format)
+}
+
+func (w *Wav) WriteFmtChunk() error {
+	err := w.writeFields("fmt",
+		w.fmtChunk.Subchunk1ID,
+		w.fmtChunk.Subchunk1Size,
+		w.fmtChunk.AudioFormat,
+		w.fmtChunk.NumChannels,
+		w.fmtChunk.SampleRate,
+		w.fmtChunk.ByteRate,
+		w.fmtChunk.BlockAlign,
+		w.fmtChunk.BitsPerSample,
+		w.fmtChunk.ExtraData,
+	)
+	if err != nil {
+		return err
+	}
+	return w.writePad("fmt", w.fmtChunk.Subchunk1Size)
+}
+
+// WriteFactChunk writes the fact chunk, if the file had one.
+func (w *Wav) WriteFactChunk() error {
+	if w.factChunk.ChunkID == nil {
+		return nil
+	}
+	return w.writeFields("fact", w.factChunk.ChunkID, w.factChunk.ChunkSize, w.factChunk.SampleLength)
+}
+
+// WriteInfoChunk writes Metadata as a LIST/INFO chunk, the tags sorted by id,
+// each NUL terminated and padded to an even size. Nothing is written without tags.
+func (w *Wav) WriteInfoChunk() error {
+	if len(w.Metadata) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(w.Metadata))
+	for id := range w.Metadata {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	body := []byte("INFO")
+	for _, id := range ids {
+		value := append([]byte(w.Metadata[id]), 0)
+		body = append(body, id...)
+		body = append(body, littleEndianBytes(len(value), 4)...)
+		body = append(body, value...)
+		if len(value)%2 == 1 {
+			body = append(body, 0)
+		}
+	}
+	return w.writeFields("LIST", []byte("LIST"), littleEndianBytes(len(body), 4), body)
+}
+
+// WriteDataChunk writes the data chunk header and the audio read by ReadData, if any.
+func (w *Wav) WriteDataChunk() error {
+	if err := w.writeFields("data", w.dataChunk.Subchunk2ID, w.dataChunk.Subchunk2Size, w.dataChunk.data); err != nil {
+		return err
+	}
+	return w.writePad("data", w.dataChunk.Subchunk2Size)
+}
+
+// littleEndianBytes encodes v in size bytes, for filling in the size and number fields of the chunks.
+func littleEndianBytes(v, size int) []byte {
+	out := make([]byte, size)
+	for i := range out {
+		out[i] = byte(v >> (8 * i))
+	}
+	return out
+}
+
+// Save writes the file out again to path: the RIFF header, then the fmt, fact, LIST/INFO and data chunks
+// in the order they were read, leaving out any others. The data and RIFF sizes are set to what's written,
+// so a file cut short comes out whole.
+func (w *Wav) Save(path string) error {
+	if err := w.ReadData(); err != nil {
+		return err
+	}
+	w.dataChunk.Subchunk2Size = littleEndianBytes(len(w.dataChunk.data), 4)
+	if w.factChunk.ChunkID != nil {
+		// Only the sample length was kept.
+		w.factChunk.ChunkSize = littleEndianBytes(len(w.factChunk.SampleLength), 4)
+	}
+	if err := w.Create(path); err != nil {
+		return err
+	}
+	if err := w.WriteRiffChunk(); err != nil {
+		return err
+	}
+	for _, id := range w.chunks {
+		var err error
+		switch id {
+		case "fmt ":
+			err = w.WriteFmtChunk()
+		case "fact":
+			err = w.WriteFactChunk()
+		case "LIST":
+			err = w.WriteInfoChunk()
+		case "data":
+			err = w.WriteDataChunk()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	end, err := w.fp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("Failed to write riff chunk: %v", err)
+	}
+	w.riffChunk.ChunkSize = littleEndianBytes(int(end-8), 4)
+	if _, err := w.fp.WriteAt(w.riffChunk.ChunkSize, 4); err != nil {
+		return fmt.Errorf("Failed to write riff chunk: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testChunk struct {
+	id   string
+	body []byte
+}
+
+// testWavBytes builds a WAV file out of the chunks, filling in the sizes and pad bytes.
+func testWavBytes(chunks ...testChunk) []byte {
+	out := []byte("RIFF\x00\x00\x00\x00WAVE")
+	for _, c := range chunks {
+		out = append(out, c.id...)
+		out = append(out, littleEndianBytes(len(c.body), 4)...)
+		out = append(out, c.body...)
+		if len(c.body)%2 == 1 {
+			out = append(out, 0)
+		}
+	}
+	copy(out[4:], littleEndianBytes(len(out)-8, 4))
+	return out
+}
+
+// testFmt is the fmt chunk of 16-bit stereo at 44100 Hz.
+var testFmt = testChunk{"fmt ", concat(
+	littleEndianBytes(1, 2),
+	littleEndianBytes(2, 2),
+	littleEndianBytes(44100, 4),
+	littleEndianBytes(44100*4, 4),
+	littleEndianBytes(4, 2),
+	littleEndianBytes(16, 2),
+)}
+
+func concat(fields ...[]byte) []byte {
+	var out []byte
+	for _, f := range fields {
+		out = append(out, f...)
+	}
+	return out
+}
+
+// infoList is a LIST/INFO chunk of the tags, each NUL terminated and padded to an even size.
+func infoList(tags ...string) testChunk {
+	body := []byte("INFO")
+	for i := 0; i < len(tags); i += 2 {
+		value := append([]byte(tags[i+1]), 0)
+		body = append(body, tags[i]...)
+		body = append(body, littleEndianBytes(len(value), 4)...)
+		body = append(body, value...)
+		if len(value)%2 == 1 {
+			body = append(body, 0)
+		}
+	}
+	return testChunk{"LIST", body}
+}
+
+// readTestWav writes data to a file and reads its chunks.
+func readTestWav(t *testing.T, data []byte) *Wav {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "in.wav")
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := newWav(file)
+	if err := w.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	if err := w.ReadRiffChunk(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.ReadChunks(); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestInfoTags(t *testing.T) {
+	w := readTestWav(t, testWavBytes(
+		testFmt,
+		infoList("IART", "Artist", "INAM", "Odd", "ICMT", "A comment", "ICRD", "2024-01-02"),
+		testChunk{"data", make([]byte, 8)},
+		infoList("ISFT", "after the data"),
+	))
+	want := map[string]string{
+		"IART": "Artist",
+		"INAM": "Odd",
+		"ICMT": "A comment",
+		"ICRD": "2024-01-02",
+		"ISFT": "after the data",
+	}
+	if len(w.Metadata) != len(want) {
+		t.Errorf("got tags %q, want %q", w.Metadata, want)
+	}
+	for id, value := range want {
+		if w.Metadata[id] != value {
+			t.Errorf("%s is %q, want %q", id, w.Metadata[id], value)
+		}
+	}
+}
+
+// A chunk declaring more than the file holds gets what's there, not an allocation of the declared size.
+func TestChunksCutShort(t *testing.T) {
+	data := testWavBytes(testFmt, testChunk{"data", make([]byte, 8)}, infoList("INAM", "Cut"))
+	copy(data[len(data)-len(infoList("INAM", "Cut").body)-4:], littleEndianBytes(0x7fffffff, 4))
+	w := readTestWav(t, data)
+	if w.Metadata["INAM"] != "Cut" {
+		t.Errorf("got tags %q from the cut short list", w.Metadata)
+	}
+
+	data = testWavBytes(testFmt, testChunk{"data", make([]byte, 8)})
+	copy(data[len(data)-12:], littleEndianBytes(0x7fffffff, 4))
+	w = readTestWav(t, data)
+	if err := w.ReadData(); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.dataChunk.data) != 8 {
+		t.Errorf("got %d bytes of audio, want the 8 in the file", len(w.dataChunk.data))
+	}
+}
+
+// A fact chunk between fmt and data is read and stepped over to find the data.
+func TestFactChunk(t *testing.T) {
+	w := readTestWav(t, testWavBytes(
+		testFmt,
+		testChunk{"fact", littleEndianBytes(3, 4)},
+		testChunk{"odd ", []byte{1, 2, 3}},
+		testChunk{"data", []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}},
+	))
+	if got := bytesToLittleEndianInt(w.fmtChunk.NumChannels); got != 2 {
+		t.Errorf("got %d channels, want 2", got)
+	}
+	if string(w.factChunk.ChunkID) != "fact" || bytesToLittleEndianInt(w.factChunk.SampleLength) != 3 {
+		t.Errorf("got fact chunk %q with %d samples, want 3", w.factChunk.ChunkID, bytesToLittleEndianInt(w.factChunk.SampleLength))
+	}
+	if string(w.dataChunk.Subchunk2ID) != "data" || bytesToLittleEndianInt(w.dataChunk.Subchunk2Size) != 12 {
+		t.Fatalf("got data chunk %q of %d bytes, want 12", w.dataChunk.Subchunk2ID, bytesToLittleEndianInt(w.dataChunk.Subchunk2Size))
+	}
+	if err := w.ReadData(); err != nil {
+		t.Fatal(err)
+	}
+	if w.dataChunk.data[0] != 1 || len(w.dataChunk.data) != 12 {
+		t.Errorf("read the audio from the wrong place: %v", w.dataChunk.data)
+	}
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	audio := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13}
+	tests := map[string][]byte{
+		"fact and tags before the data": testWavBytes(
+			testFmt,
+			testChunk{"fact", littleEndianBytes(3, 4)},
+			infoList("IART", "Artist", "ICMT", "A comment", "INAM", "Odd"),
+			testChunk{"data", audio},
+		),
+		"tags after the data": testWavBytes(
+			testFmt,
+			testChunk{"data", audio},
+			infoList("INAM", "Title"),
+		),
+	}
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := readTestWav(t, in)
+			out := filepath.Join(t.TempDir(), "out.wav")
+			if err := w.Save(out); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, in) {
+				t.Errorf("saved file differs from the original:\n got %q\nwant %q", got, in)
+			}
+		})
+	}
+}
@@ -18,7 +18,7 @@ func usage() string {
 
 func main() {
 
-	logging.DisplayDebug = true
+	logging.SetLevel(logging.Debug)
 
 	if len(os.Args) < 2 {
 		logging.Stderr("Insufficient number of arguments")
@@ -37,14 +37,14 @@ func main() {
 		logging.Stderr(errors.Wrap(err, "Failed to find card").Error())
 		os.Exit(1)
 	}
-	logging.Debugf("%s found.\n", card)
+	logging.Debugf("%s found.", card)
 
 	device, err := alsa.FindPlayableDevice(card, deviceName)
 	if err != nil {
 		logging.Stderr(errors.Wrap(err, "Failed to determine playable device").Error())
 		os.Exit(1)
 	}
-	logging.Debugf("%s found.\n", device)
+	logging.Debugf("%s found.", device)
 
 	if err := alsa.PlayWav(device, wavFileName); err != nil {
 		logging.Stderr(errors.Wrap(err, "failed to play wav file on device").Error())
@@ -8,7 +8,9 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/renan-campos/sound-utils/pkg/alsa"
+	"github.com/yobert/alsa"
+
+	alsautil "github.com/renan-campos/sound-utils/pkg/alsa"
 	. "github.com/renan-campos/sound-utils/pkg/logging"
 )
 
@@ -18,14 +20,50 @@ func main() {
 		rate         int
 		duration_str string
 		file         string
+		float        bool
+		bits         int
+		mkdir        bool
 	)
 
 	flag.IntVar(&channels, "channels", 2, "Channels (1 for mono, 2 for stereo)")
 	flag.IntVar(&rate, "rate", 44100, "Frame rate (Hz)")
 	flag.StringVar(&duration_str, "duration", "5s", "Recording duration")
 	flag.StringVar(&file, "file", "out.wave", "Output file")
+	flag.BoolVar(&float, "float", false, "Save 32-bit float samples instead of integer PCM (only goes with -bits 32)")
+	flag.IntVar(&bits, "bits", 16, "Bits per sample of the saved file (16, 24 or 32)")
+	flag.BoolVar(&mkdir, "mkdir", false, "Create the output file's directory if it doesn't exist")
 	flag.Parse()
 
+	if float {
+		bitsSet := false
+		flag.Visit(func(f *flag.Flag) {
+			bitsSet = bitsSet || f.Name == "bits"
+		})
+		if bitsSet && bits != 32 {
+			fmt.Println("Float samples are 32 bits, -float can't be saved with -bits", bits)
+			os.Exit(1)
+		}
+		bits = 32
+	}
+
+	// The device is asked for the closest format it may have, the recording is converted if it doesn't.
+	var formats []alsa.FormatType
+	var saveFormat alsa.FormatType
+	switch bits {
+	case 16:
+		formats = []alsa.FormatType{alsa.S16_LE, alsa.S32_LE}
+		saveFormat = alsa.S16_LE
+	case 24:
+		formats = []alsa.FormatType{alsa.S32_LE, alsa.S16_LE}
+		saveFormat = alsautil.S24_3LE
+	case 32:
+		formats = []alsa.FormatType{alsa.S32_LE, alsa.S16_LE}
+		saveFormat = alsa.S32_LE
+	default:
+		fmt.Println("Bits must be 16, 24 or 32, not", bits)
+		os.Exit(1)
+	}
+
 	os.Environ()
 	cardName := os.Getenv("ALSA_CARDNAME")
 	deviceName := os.Getenv("ALSA_DEVICENAME")
@@ -36,15 +74,15 @@ func main() {
 		os.Exit(1)
 	}
 
-	card, err := alsa.FindCard(cardName)
-	defer alsa.CloseCard(card)
+	card, err := alsautil.FindCard(cardName)
+	defer alsautil.CloseCard(card)
 	if err != nil {
 		Stderr(errors.Wrap(err, "Failed to find card").Error())
 		os.Exit(1)
 	}
 	fmt.Println(card, "found!")
 
-	device, err := alsa.FindRecordableDevice(card, deviceName)
+	device, err := alsautil.FindRecordableDevice(card, deviceName)
 	if err != nil {
 		Stderr(errors.Wrap(err, "Failed to determine recordable device").Error())
 		os.Exit(1)
@@ -53,13 +91,27 @@ func main() {
 
 	fmt.Printf("Recording device: %v\n", device)
 
-	recording, err := alsa.RecordWav(device, duration, channels, rate)
+	if left, err := alsautil.EstimatedRecordTime(file, alsautil.AudioConfig{
+		Channels: channels, Rates: []int{rate}, Formats: []alsa.FormatType{saveFormat},
+	}); err == nil {
+		fmt.Printf("Room for %s of recording\n", left.Round(time.Second))
+	}
+
+	recording, err := alsautil.RecordWavWithOpts(device, duration, alsautil.RecordOpts{
+		Config: alsautil.AudioConfig{Channels: channels, Rates: []int{rate}, Formats: formats},
+	})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	recording, err = alsautil.ConvertFormat(recording, saveFormat)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	err = alsa.SaveWav(recording, file)
+	err = alsautil.SaveWavWithOpts(recording, file, alsautil.SaveOpts{Float: float, MkdirAll: mkdir})
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
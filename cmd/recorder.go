@@ -0,0 +1,198 @@
+// interactively record to a WAV file from the keyboard
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yobert/alsa"
+
+	alsautil "github.com/renan-campos/sound-utils/pkg/alsa"
+	"github.com/renan-campos/sound-utils/pkg/audiostream"
+	. "github.com/renan-campos/sound-utils/pkg/logging"
+)
+
+func main() {
+	var (
+		channels int
+		rate     int
+		file     string
+		idle     time.Duration
+	)
+
+	flag.IntVar(&channels, "channels", 1, "Channels (1 for mono, 2 for stereo)")
+	flag.IntVar(&rate, "rate", 44100, "Frame rate (Hz)")
+	flag.StringVar(&file, "file", "out.wav", "Output file")
+	flag.DurationVar(&idle, "idle", 0, "Turn off after this long in standby (0 never does)")
+	flag.Parse()
+
+	cardName := os.Getenv("ALSA_CARDNAME")
+	deviceName := os.Getenv("ALSA_DEVICENAME")
+
+	card, err := alsautil.FindCard(cardName)
+	defer alsautil.CloseCard(card)
+	if err != nil {
+		Stderr(errors.Wrap(err, "Failed to find card").Error())
+		os.Exit(1)
+	}
+	device, err := alsautil.FindRecordableDevice(card, deviceName)
+	if err != nil {
+		Stderr(errors.Wrap(err, "Failed to determine recordable device").Error())
+		os.Exit(1)
+	}
+	fmt.Printf("Recording device: %v\n", device)
+
+	config := audiostream.DeviceConfig{
+		NumChannels: channels,
+		FrameRate:   rate,
+		FrameFormat: alsa.S16_LE,
+		BufferSize:  8192,
+	}
+	stream := audiostream.NewAudioStream()
+	if err := stream.SetDevice(device, config); err != nil {
+		Stderr(errors.Wrap(err, "Failed to set device").Error())
+		os.Exit(1)
+	}
+	if err := stream.SetFileName(file); err != nil {
+		Stderr(errors.Wrap(err, "Failed to set file").Error())
+		os.Exit(1)
+	}
+	stream.SetIdleTimeout(idle)
+	monitor := stream.Monitor(4)
+	changes := stream.StatusChanges()
+	streamErrors := stream.Errors()
+	if err := stream.Standby(); err != nil {
+		Stderr(errors.Wrap(err, "Failed to start stream").Error())
+		os.Exit(1)
+	}
+
+	restore, err := rawTerminal()
+	if err != nil {
+		stream.Off()
+		Stderr(errors.Wrap(err, "Failed to put the terminal in raw mode").Error())
+		os.Exit(1)
+	}
+	defer restore()
+
+	fmt.Printf("space: record/standby, q: quit\n")
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	keys := make(chan byte)
+	go readKeys(keys)
+
+	status := audiostream.StatusStandby
+	level := math.Inf(-1)
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				stream.Off()
+				return
+			}
+			err = nil
+			switch key {
+			case ' ':
+				if status == audiostream.StatusRecording {
+					err = stream.Standby()
+				} else {
+					err = stream.Record()
+				}
+			case 'q':
+				stream.Off()
+				fmt.Println()
+				return
+			}
+			if err != nil {
+				fmt.Printf("\r\n%v\r\n", err)
+			}
+		case <-signals:
+			stream.Off()
+			fmt.Println()
+			return
+		case status = <-changes:
+			if status == audiostream.StatusOff {
+				// Idle timeout turned the stream off.
+				fmt.Printf("\r\nStream turned off\r\n")
+				return
+			}
+			level = math.Inf(-1)
+		case err := <-streamErrors:
+			stream.Off()
+			fmt.Printf("\r\n%v\r\n", err)
+			return
+		case chunk, ok := <-monitor:
+			if !ok {
+				// The stream was turned off.
+				monitor = nil
+				continue
+			}
+			level = alsautil.SamplePeak(alsa.Buffer{
+				Format: alsa.BufferFormat{
+					SampleFormat: config.FrameFormat,
+					Rate:         config.FrameRate,
+					Channels:     config.NumChannels,
+				},
+				Data: chunk,
+			})
+		}
+		fmt.Printf("\r%-10s %s", status, meter(level))
+	}
+}
+
+// rawTerminal turns off line buffering and echo so single keypresses come through,
+// returning a function that puts the terminal back the way it was.
+func rawTerminal() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("cbreak", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() {
+		stty(strings.TrimSpace(saved))
+	}, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func readKeys(keys chan<- byte) {
+	buf := make([]byte, 1)
+	for {
+		if _, err := os.Stdin.Read(buf); err != nil {
+			close(keys)
+			return
+		}
+		keys <- buf[0]
+	}
+}
+
+// meter draws a level bar covering -60 to 0 dBFS.
+func meter(db float64) string {
+	const width = 30
+	n := 0
+	if !math.IsInf(db, -1) {
+		n = int((db + 60) / 60 * width)
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > width {
+		n = width
+	}
+	return fmt.Sprintf("[%s%s] %6.1f dB", strings.Repeat("#", n), strings.Repeat(" ", width-n), db)
+}
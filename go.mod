@@ -3,12 +3,15 @@ module github.com/renan-campos/sound-utils
 go 1.17
 
 require (
+	github.com/go-audio/audio v1.0.0
 	github.com/go-audio/wav v1.0.0
+	github.com/mewkiz/flac v1.0.12
 	github.com/pkg/errors v0.9.1
 	github.com/yobert/alsa v0.0.0-20200618200352-d079056f5370
 )
 
 require (
-	github.com/go-audio/audio v1.0.0 // indirect
 	github.com/go-audio/riff v1.0.0 // indirect
+	github.com/icza/bitio v1.1.0 // indirect
+	github.com/mewkiz/pkg v0.0.0-20230226050401-4010bf0fec14 // indirect
 )
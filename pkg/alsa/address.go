@@ -0,0 +1,124 @@
+package alsa
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/yobert/alsa"
+)
+
+// deviceAddress is a parsed OpenDevice address. Indexes are -1 when the address uses titles.
+type deviceAddress struct {
+	cardTitle, deviceTitle   string
+	cardNumber, deviceNumber int
+	direction                Direction
+}
+
+// parseAddress accepts "hw:CARD,DEVICE" (or "hw:CARD" for device 0) with numbers,
+// or "Card title:Device title". Either can end in ",p" for the playback side of the device,
+// which is also what it means without one, or ",c" for the capture side.
+func parseAddress(addr string) (deviceAddress, error) {
+	direction := Playback
+	rest := addr
+	if strings.HasSuffix(rest, ",c") {
+		direction = Capture
+	}
+	if strings.HasSuffix(rest, ",c") || strings.HasSuffix(rest, ",p") {
+		rest = rest[:len(rest)-2]
+	}
+
+	if strings.HasPrefix(rest, "hw:") {
+		parts := strings.SplitN(strings.TrimPrefix(rest, "hw:"), ",", 2)
+		if len(parts) == 1 {
+			parts = append(parts, "0")
+		}
+		card, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return deviceAddress{}, fmt.Errorf("invalid card index in %q", addr)
+		}
+		device, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return deviceAddress{}, fmt.Errorf("invalid device index in %q", addr)
+		}
+		return deviceAddress{cardNumber: card, deviceNumber: device, direction: direction}, nil
+	}
+
+	i := strings.Index(rest, ":")
+	if i < 0 {
+		return deviceAddress{}, fmt.Errorf("address %q is neither \"hw:card,device\" nor \"card:device\"", addr)
+	}
+	// There's no telling which colon splits the titles if there's more than one.
+	if strings.Count(rest, ":") > 1 {
+		return deviceAddress{}, fmt.Errorf("address %q has more than one colon, use \"hw:card,device\" for titles with colons", addr)
+	}
+	return deviceAddress{
+		cardTitle:    rest[:i],
+		deviceTitle:  rest[i+1:],
+		cardNumber:   -1,
+		deviceNumber: -1,
+		direction:    direction,
+	}, nil
+}
+
+func (a deviceAddress) matchesCard(card *alsa.Card) bool {
+	if a.cardNumber >= 0 {
+		return card.Number == a.cardNumber
+	}
+	return card.Title == a.cardTitle
+}
+
+func (a deviceAddress) matchesDevice(device *alsa.Device) bool {
+	if device.Type != alsa.PCM {
+		return false
+	}
+	// Both sides of a device share its number and title.
+	if (a.direction == Capture && !device.Record) || (a.direction == Playback && !device.Play) {
+		return false
+	}
+	if a.deviceNumber >= 0 {
+		return device.Number == a.deviceNumber
+	}
+	return device.Title == a.deviceTitle
+}
+
+// OpenDevice finds a PCM device by its address, either "hw:1,0" with card and device indexes
+// like on the command line, or "Card title:Device title" as FindCard and FindPlayableDevice take them.
+// Addresses are of playback devices unless they end in ",c", like "hw:1,0,c", for capture.
+// The caller closes the card with CloseCard when done; the other cards are closed right away.
+func OpenDevice(addr string) (*alsa.Card, *alsa.Device, error) {
+	address, err := parseAddress(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	cards, err := openCards()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var found *alsa.Card
+	for _, card := range cards {
+		if address.matchesCard(card) {
+			found = card
+			break
+		}
+	}
+	closeAllBut(cards, found)
+	if found == nil {
+		return nil, nil, &cardNotFound{cardName: addr}
+	}
+
+	devices, err := cardDevices(found)
+	if err != nil {
+		closeCard(found)
+		return nil, nil, errors.Wrap(err, "Failed to get card devices")
+	}
+	for _, device := range devices {
+		if address.matchesDevice(device) {
+			return found, device, nil
+		}
+	}
+	closeCard(found)
+	return nil, nil, &DeviceNotFound{deviceName: addr}
+}
@@ -0,0 +1,70 @@
+package alsa
+
+import (
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want deviceAddress
+		err  bool
+	}{
+		{addr: "hw:1,2", want: deviceAddress{cardNumber: 1, deviceNumber: 2, direction: Playback}},
+		{addr: "hw:1", want: deviceAddress{cardNumber: 1, deviceNumber: 0, direction: Playback}},
+		{addr: "hw:1,2,p", want: deviceAddress{cardNumber: 1, deviceNumber: 2, direction: Playback}},
+		{addr: "hw:1,2,c", want: deviceAddress{cardNumber: 1, deviceNumber: 2, direction: Capture}},
+		{addr: "hw:1,c", want: deviceAddress{cardNumber: 1, deviceNumber: 0, direction: Capture}},
+		{addr: "USB Audio:USB Audio", want: deviceAddress{
+			cardTitle: "USB Audio", deviceTitle: "USB Audio", cardNumber: -1, deviceNumber: -1, direction: Playback,
+		}},
+		{addr: "HDA Intel:ALC892 Analog,c", want: deviceAddress{
+			cardTitle: "HDA Intel", deviceTitle: "ALC892 Analog", cardNumber: -1, deviceNumber: -1, direction: Capture,
+		}},
+		{addr: "Card:", want: deviceAddress{cardTitle: "Card", cardNumber: -1, deviceNumber: -1, direction: Playback}},
+		{addr: "hw:one,0", err: true},
+		{addr: "hw:1,zero", err: true},
+		{addr: "hw:1,0,x", err: true},
+		{addr: "no colon", err: true},
+		{addr: "Card: A:B", err: true}, // which colon?
+	}
+	for _, tt := range tests {
+		got, err := parseAddress(tt.addr)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q parsed as %+v, want an error", tt.addr, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.addr, err)
+		} else if got != tt.want {
+			t.Errorf("%q parsed as %+v, want %+v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+// Both sides of a device share its number and title, only the direction tells them apart.
+func TestOpenDeviceDirection(t *testing.T) {
+	tests := []struct {
+		addr    string
+		capture bool
+	}{
+		{"hw:1,0", false},
+		{"hw:1,0,p", false},
+		{"hw:1,0,c", true},
+		{"Middle:Middle", false},
+		{"Middle:Middle,c", true},
+	}
+	for _, tt := range tests {
+		fakeCards(t, "First", "Middle", "Last")
+		card, device, err := OpenDevice(tt.addr)
+		if err != nil {
+			t.Errorf("%q: %v", tt.addr, err)
+			continue
+		}
+		if card.Number != 1 || device.Record != tt.capture || device.Play == tt.capture {
+			t.Errorf("%q opened card %d, device %+v", tt.addr, card.Number, device)
+		}
+	}
+}
@@ -1,20 +1,7 @@
 package alsa
 
-import "fmt"
-
 func scale8To16(in int) int {
-	fmt.Println(in, 257*in)
-	return (65535 / 255) * in
-	// This method has some distortion.
-	var out int
-	mask := 1
-	for i := 0; i < 8; i++ {
-		if (in & mask) > 0 {
-			out |= (3 << (2 * i))
-		}
-		mask <<= 1
-	}
-	return out
+	return in << 8
 }
 
 func scale32To16(in int) int {
@@ -28,3 +15,31 @@ func scale16To32(in int) int {
 func scale8To32(in int) int {
 	return in << 24
 }
+
+func scale24To16(in int) int {
+	return in >> 8
+}
+
+func scale24To32(in int) int {
+	return in << 8
+}
+
+func scale32To24(in int) int {
+	return in >> 8
+}
+
+func scale16To24(in int) int {
+	return in << 8
+}
+
+func scale8To24(in int) int {
+	return in << 16
+}
+
+// scaleBits moves a sample from one bit depth to another, keeping it at the same level relative to full scale.
+func scaleBits(in int, from, to int) int {
+	if to > from {
+		return in << (to - from)
+	}
+	return in >> (from - to)
+}
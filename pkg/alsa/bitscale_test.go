@@ -0,0 +1,54 @@
+package alsa
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestScaleBits(t *testing.T) {
+	tests := []struct {
+		name  string
+		scale func(int) int
+		in    int
+		want  int
+	}{
+		{"8 to 16 min", scale8To16, -128, -32768},
+		{"8 to 16 silence", scale8To16, 0, 0},
+		{"8 to 16 max", scale8To16, 127, 32512},
+		{"8 to 24 min", scale8To24, -128, -1 << 23},
+		{"8 to 24 max", scale8To24, 127, 127 << 16},
+		{"8 to 32 min", scale8To32, -128, -1 << 31},
+		{"8 to 32 max", scale8To32, 127, 127 << 24},
+		{"16 to 24", scale16To24, -32768, -1 << 23},
+		{"16 to 32", scale16To32, 32767, 32767 << 16},
+		{"24 to 16", scale24To16, -1 << 23, -32768},
+		{"24 to 32", scale24To32, 1<<23 - 1, (1<<23 - 1) << 8},
+		{"32 to 16", scale32To16, -1 << 31, -32768},
+		{"32 to 24", scale32To24, 1<<31 - 1, 1<<23 - 1},
+		{"scaleBits up", func(in int) int { return scaleBits(in, 16, 24) }, -2, -512},
+		{"scaleBits down", func(in int) int { return scaleBits(in, 24, 16) }, -512, -2},
+	}
+	for _, test := range tests {
+		if got := test.scale(test.in); got != test.want {
+			t.Errorf("%s: %d became %d, want %d", test.name, test.in, got, test.want)
+		}
+	}
+}
+
+// 8-bit WAV samples are unsigned, so the bytes for the lowest, silent and highest levels come out signed.
+func TestPCMReader8Bit(t *testing.T) {
+	file := wavBytes(pcmFmt(1, 8000, 8), testChunk{"data", []byte{0, 128, 255}})
+	p, err := newPCMReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]int, 3)
+	if n, err := p.read(samples); err != nil || n != 3 {
+		t.Fatalf("read %d samples: %v", n, err)
+	}
+	for i, want := range []int{-32768, 0, 32512} {
+		if got := scale8To16(samples[i]); got != want {
+			t.Errorf("byte %d played as %d, want %d", i, got, want)
+		}
+	}
+}
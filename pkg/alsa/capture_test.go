@@ -0,0 +1,80 @@
+package alsa
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+)
+
+// fakeCapture fills reads with an increasing byte count and fails the reads listed in fail
+// the way yobert/alsa does, with the errno formatted into the message.
+type fakeCapture struct {
+	reads    int
+	fail     map[int]syscall.Errno
+	prepares int
+	next     byte
+}
+
+func (f *fakeCapture) Read(buf []byte) error {
+	f.reads++
+	if errno, ok := f.fail[f.reads]; ok {
+		return fmt.Errorf("ioctl read  (16 bytes) 0x4151 failed: %v", errno)
+	}
+	for i := range buf {
+		buf[i] = f.next
+		f.next++
+	}
+	return nil
+}
+
+func (f *fakeCapture) Prepare() error {
+	f.prepares++
+	return nil
+}
+
+func TestCaptureStrictOverrun(t *testing.T) {
+	dev := &fakeCapture{fail: map[int]syscall.Errno{3: syscall.EPIPE}}
+	data := make([]byte, 40)
+	n, err := capture(dev, data, 8, 4, true, func(off, end int) {})
+	var overrun *Overrun
+	if !errors.As(err, &overrun) {
+		t.Fatalf("got error %v, want an *Overrun", err)
+	}
+	if n != 16 || overrun.Frame != 4 {
+		t.Errorf("captured %d bytes, overrun at frame %d; want 16 bytes and frame 4", n, overrun.Frame)
+	}
+	if dev.prepares != 0 {
+		t.Error("strict mode recovered from the overrun")
+	}
+}
+
+func TestCaptureRecoversFromOverrun(t *testing.T) {
+	dev := &fakeCapture{fail: map[int]syscall.Errno{3: syscall.EPIPE}}
+	data := make([]byte, 40)
+	var chunks int
+	n, err := capture(dev, data, 8, 4, false, func(off, end int) { chunks++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(data) || chunks != 5 || dev.prepares != 1 {
+		t.Errorf("captured %d bytes in %d chunks with %d prepares, want %d bytes in 5 chunks with 1 prepare",
+			n, chunks, dev.prepares, len(data))
+	}
+	for i, b := range data {
+		if b != byte(i) {
+			t.Fatalf("byte %d is %d, the chunk after the overrun wasn't read again", i, b)
+		}
+	}
+}
+
+func TestCaptureOtherErrors(t *testing.T) {
+	dev := &fakeCapture{fail: map[int]syscall.Errno{2: syscall.EBADFD}}
+	_, err := capture(dev, make([]byte, 40), 8, 4, false, func(off, end int) {})
+	if !errors.Is(err, syscall.EBADFD) {
+		t.Errorf("got error %v, want EBADFD", err)
+	}
+	if dev.prepares != 0 {
+		t.Error("recovered from an error that isn't an overrun")
+	}
+}
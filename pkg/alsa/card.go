@@ -1,23 +1,125 @@
 package alsa
 
-import "github.com/yobert/alsa"
+import (
+	"fmt"
+	"sort"
+	"strings"
 
+	"github.com/yobert/alsa"
+)
+
+// CardInfo describes a sound card.
+type CardInfo struct {
+	Number int
+	Title  string
+	Path   string // The card's control device, /dev/snd/controlC<Number>.
+}
+
+func (c CardInfo) String() string {
+	return fmt.Sprintf("card %d: %s", c.Number, c.Title)
+}
+
+// ListCards lists the sound cards, ordered by number.
+// The cards are only open while they're being listed and are all closed again when it returns,
+// so there's nothing to clean up; use FindCard to get hold of an open card.
+func ListCards() ([]CardInfo, error) {
+	cards, err := openCards()
+	if err != nil {
+		return nil, err
+	}
+	defer closeAllBut(cards, nil)
+	return listCards(cards), nil
+}
+
+func listCards(cards []*alsa.Card) []CardInfo {
+	list := make([]CardInfo, len(cards))
+	for i, card := range cards {
+		list[i] = CardInfo{Number: card.Number, Title: card.Title, Path: card.Path}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Number < list[j].Number })
+	return list
+}
+
+// The alsa calls the card lookups make, swapped out by tests to see what gets closed.
+var (
+	openCards   = alsa.OpenCards
+	closeCard   = CloseCard
+	cardDevices = (*alsa.Card).Devices
+)
+
+// closeAllBut closes every card except keep, which stays open for the caller. A nil keep closes them all.
+func closeAllBut(cards []*alsa.Card, keep *alsa.Card) {
+	for _, card := range cards {
+		if card != keep {
+			closeCard(card)
+		}
+	}
+}
+
+// FindCard finds the card titled name. The caller owns the card that's returned and closes it with CloseCard;
+// every other card is closed before FindCard returns, including all of them when there's no match.
 func FindCard(name string) (*alsa.Card, error) {
-	cards, err := alsa.OpenCards()
+	cards, err := openCards()
 	if err != nil {
 		return nil, err
 	}
 
+	var found *alsa.Card
 	for _, card := range cards {
 		if card.Title == name {
+			found = card
+			break
+		}
+	}
+	closeAllBut(cards, found)
+	if found == nil {
+		return nil, &cardNotFound{cardName: name}
+	}
+	return found, nil
+}
+
+// FindCardFuzzy finds the one card whose title contains substring, ignoring case,
+// so "usb audio" finds "USB Audio Device". Only the card found is left open.
+// When no card or more than one card matches, the error lists the titles to pick from.
+func FindCardFuzzy(substring string) (*alsa.Card, error) {
+	cards, err := openCards()
+	if err != nil {
+		return nil, err
+	}
+	card, err := matchCard(cards, substring)
+	closeAllBut(cards, card)
+	return card, err
+}
+
+// matchCard picks the card for FindCardFuzzy. An exact title match wins even if other titles contain it too.
+func matchCard(cards []*alsa.Card, substring string) (*alsa.Card, error) {
+	var matches []*alsa.Card
+	var titles, matchTitles []string
+	want := strings.ToLower(substring)
+	for _, card := range cards {
+		if card.Title == substring {
 			return card, nil
-		} else {
-			CloseCard(card)
+		}
+		titles = append(titles, card.Title)
+		if strings.Contains(strings.ToLower(card.Title), want) {
+			matches = append(matches, card)
+			matchTitles = append(matchTitles, card.Title)
 		}
 	}
-	return nil, &cardNotFound{cardName: name}
+	switch len(matches) {
+	case 0:
+		return nil, &cardNotFound{cardName: substring, available: titles}
+	case 1:
+		return matches[0], nil
+	}
+	return nil, &AmbiguousCard{Name: substring, Matches: matchTitles}
 }
 
+// CloseCard closes a card from FindCard, FindCardFuzzy or OpenDevice. A nil card is ignored,
+// so it can be deferred before checking the error.
 func CloseCard(card *alsa.Card) {
+	if card == nil {
+		return
+	}
 	alsa.CloseCards([]*alsa.Card{card})
 }
@@ -0,0 +1,231 @@
+package alsa
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/yobert/alsa"
+)
+
+// fakeCards stands in for the alsa layer with cards numbered from 0 and titled titles,
+// each with one PCM device titled like the card, listed with its playback side before its capture side
+// like yobert/alsa does. It returns how often each card got closed.
+func fakeCards(t *testing.T, titles ...string) ([]*alsa.Card, map[*alsa.Card]int) {
+	t.Helper()
+	cards := make([]*alsa.Card, len(titles))
+	for i, title := range titles {
+		cards[i] = &alsa.Card{Number: i, Title: title}
+	}
+	closed := make(map[*alsa.Card]int)
+	savedOpen, savedClose, savedDevices := openCards, closeCard, cardDevices
+	t.Cleanup(func() {
+		openCards, closeCard, cardDevices = savedOpen, savedClose, savedDevices
+	})
+	openCards = func() ([]*alsa.Card, error) {
+		return append([]*alsa.Card(nil), cards...), nil
+	}
+	closeCard = func(card *alsa.Card) {
+		closed[card]++
+	}
+	cardDevices = func(card *alsa.Card) ([]*alsa.Device, error) {
+		return []*alsa.Device{
+			{Type: alsa.PCM, Number: 0, Play: true, Title: card.Title},
+			{Type: alsa.PCM, Number: 0, Record: true, Title: card.Title},
+		}, nil
+	}
+	return cards, closed
+}
+
+// checkClosed fails unless every card but keep was closed exactly once and keep not at all.
+func checkClosed(t *testing.T, cards []*alsa.Card, closed map[*alsa.Card]int, keep *alsa.Card) {
+	t.Helper()
+	for _, card := range cards {
+		want := 1
+		if card == keep {
+			want = 0
+		}
+		if closed[card] != want {
+			t.Errorf("card %d %q closed %d times, want %d", card.Number, card.Title, closed[card], want)
+		}
+	}
+}
+
+func TestFindCardClosesTheRest(t *testing.T) {
+	for i, name := range []string{"First", "Middle", "Last", "Missing"} {
+		t.Run(name, func(t *testing.T) {
+			cards, closed := fakeCards(t, "First", "Middle", "Last")
+			card, err := FindCard(name)
+			var want *alsa.Card
+			if i < len(cards) {
+				want = cards[i]
+			}
+			if card != want || (err == nil) != (want != nil) {
+				t.Fatalf("got card %v, error %v", card, err)
+			}
+			checkClosed(t, cards, closed, want)
+		})
+	}
+}
+
+func TestFindCardFuzzyClosesTheRest(t *testing.T) {
+	tests := []struct {
+		substring string
+		keep      int // -1 when nothing matches
+	}{
+		{"usb", 1},
+		{"audio", -1}, // ambiguous
+		{"hdmi", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.substring, func(t *testing.T) {
+			cards, closed := fakeCards(t, "HDA Intel Audio", "USB Audio Device", "Loopback")
+			card, err := FindCardFuzzy(tt.substring)
+			var want *alsa.Card
+			if tt.keep >= 0 {
+				want = cards[tt.keep]
+			}
+			if card != want || (err == nil) != (want != nil) {
+				t.Fatalf("got card %v, error %v", card, err)
+			}
+			checkClosed(t, cards, closed, want)
+		})
+	}
+}
+
+func TestOpenDeviceClosesTheRest(t *testing.T) {
+	for _, addr := range []string{"hw:2,0", "Middle:Middle", "hw:1,3", "hw:5,0"} {
+		t.Run(addr, func(t *testing.T) {
+			cards, closed := fakeCards(t, "First", "Middle", "Last")
+			card, device, err := OpenDevice(addr)
+			var want *alsa.Card
+			switch addr {
+			case "hw:2,0":
+				want = cards[2]
+			case "Middle:Middle":
+				want = cards[1]
+			}
+			if card != want || (err == nil) != (want != nil) || (device == nil) != (want == nil) {
+				t.Fatalf("got card %v, device %v, error %v", card, device, err)
+			}
+			checkClosed(t, cards, closed, want)
+		})
+	}
+}
+
+func TestDefaultDeviceClosesTheRest(t *testing.T) {
+	cards, closed := fakeCards(t, "Capture only", "Player", "Another player")
+	devices := cardDevices
+	cardDevices = func(card *alsa.Card) ([]*alsa.Device, error) {
+		if card.Number == 0 {
+			return []*alsa.Device{{Type: alsa.PCM, Record: true}}, nil
+		}
+		return devices(card)
+	}
+	card, _, err := defaultDevice(Playback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card != cards[1] {
+		t.Fatalf("got card %v, want %v", card, cards[1])
+	}
+	checkClosed(t, cards, closed, card)
+
+	cardDevices = func(*alsa.Card) ([]*alsa.Device, error) {
+		return nil, fmt.Errorf("no devices")
+	}
+	for card := range closed {
+		delete(closed, card)
+	}
+	if _, _, err := defaultDevice(Capture); err == nil {
+		t.Fatal("found a device on cards without any")
+	}
+	checkClosed(t, cards, closed, nil)
+}
+
+func TestListCards(t *testing.T) {
+	cards, closed := fakeCards(t, "USB Audio", "HDA Intel", "Loopback")
+	// The alsa layer doesn't promise any order.
+	cards[0].Number, cards[1].Number, cards[2].Number = 2, 0, 1
+	cards[1].Path = "/dev/snd/controlC0"
+
+	list, err := ListCards()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CardInfo{
+		{Number: 0, Title: "HDA Intel", Path: "/dev/snd/controlC0"},
+		{Number: 1, Title: "Loopback"},
+		{Number: 2, Title: "USB Audio"},
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("listed %v, want %v", list, want)
+	}
+	if got := list[0].String(); got != "card 0: HDA Intel" {
+		t.Errorf("card 0 prints as %q", got)
+	}
+	checkClosed(t, cards, closed, nil)
+}
+
+func TestListDevices(t *testing.T) {
+	cards, closed := fakeCards(t, "HDA Intel")
+	list, err := ListDevices(cards[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both sides of the device, in the order the card gives them.
+	want := []DeviceInfo{
+		{Number: 0, Title: "HDA Intel", Play: true},
+		{Number: 0, Title: "HDA Intel", Record: true},
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("listed %v, want %v", list, want)
+	}
+	checkClosed(t, cards, closed, cards[0])
+
+	cardDevices = func(*alsa.Card) ([]*alsa.Device, error) {
+		return nil, fmt.Errorf("no devices")
+	}
+	if _, err := ListDevices(cards[0]); err == nil {
+		t.Error("listed the devices of a card that can't list them")
+	}
+}
+
+func TestFindCardFuzzy(t *testing.T) {
+	titles := []string{"USB Audio", "USB Audio Device", "HDA Intel PCH"}
+	tests := []struct {
+		name      string
+		card      int // -1 when nothing is found
+		ambiguous []string
+	}{
+		{"USB Audio", 0, nil}, // exact, though the other USB card contains it too
+		{"usb audio device", 1, nil},
+		{"intel", 2, nil},
+		{"usb", -1, []string{"USB Audio", "USB Audio Device"}},
+		{"Loopback", -1, nil},
+	}
+	for _, tt := range tests {
+		cards, closed := fakeCards(t, titles...)
+		card, err := FindCardFuzzy(tt.name)
+		switch {
+		case tt.card >= 0:
+			if err != nil || card != cards[tt.card] {
+				t.Errorf("%q found %v, %v, want %q", tt.name, card, err, titles[tt.card])
+			}
+		case tt.ambiguous != nil:
+			var ambiguous *AmbiguousCard
+			if !errors.As(err, &ambiguous) || !reflect.DeepEqual(ambiguous.Matches, tt.ambiguous) {
+				t.Errorf("%q got %v, want it to match %v", tt.name, err, tt.ambiguous)
+			}
+		default:
+			var notFound *cardNotFound
+			if !errors.As(err, &notFound) || !reflect.DeepEqual(notFound.available, titles) {
+				t.Errorf("%q got %v, want a list of the cards there are", tt.name, err)
+			}
+		}
+		if card != nil {
+			checkClosed(t, cards, closed, card)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package alsa
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/yobert/alsa"
+)
+
+// Speaker positions of a WAVE_FORMAT_EXTENSIBLE channel mask.
+// The channels of an extensible file appear in the order of the bits set in its mask.
+const (
+	speakerFrontLeft   uint32 = 0x1
+	speakerFrontRight  uint32 = 0x2
+	speakerFrontCenter uint32 = 0x4
+	speakerLFE         uint32 = 0x8
+	speakerBackLeft    uint32 = 0x10
+	speakerBackRight   uint32 = 0x20
+	speakerBackCenter  uint32 = 0x100
+	speakerSideLeft    uint32 = 0x200
+	speakerSideRight   uint32 = 0x400
+)
+
+const wavFormatExtensible = 0xFFFE
+
+// alsaLayouts are the default ALSA channel orders, which don't match the WAV order:
+// a 5.1 WAV is FL FR FC LFE BL BR while ALSA expects FL FR RL RR FC LFE.
+var alsaLayouts = map[int][]uint32{
+	2: {speakerFrontLeft, speakerFrontRight},
+	4: {speakerFrontLeft, speakerFrontRight, speakerBackLeft, speakerBackRight},
+	6: {speakerFrontLeft, speakerFrontRight, speakerBackLeft, speakerBackRight, speakerFrontCenter, speakerLFE},
+	8: {speakerFrontLeft, speakerFrontRight, speakerBackLeft, speakerBackRight, speakerFrontCenter, speakerLFE,
+		speakerSideLeft, speakerSideRight},
+}
+
+// Speakers a channel can fall back to when the output doesn't have its exact position.
+// 5.1 files often use the side speakers where a 5.1 system has rear ones.
+var speakerAliases = map[uint32]uint32{
+	speakerSideLeft:  speakerBackLeft,
+	speakerSideRight: speakerBackRight,
+	speakerBackLeft:  speakerSideLeft,
+	speakerBackRight: speakerSideRight,
+}
+
+// readChannelMask returns the channel mask of an extensible WAV file, or 0 if the file doesn't have one.
+func readChannelMask(r io.ReadSeeker) uint32 {
+	chunks, _ := walkChunks(r)
+	c, ok := findChunk(chunks, "fmt ")
+	if !ok || c.size < 24 {
+		return 0
+	}
+	if _, err := r.Seek(c.offset, io.SeekStart); err != nil {
+		return 0
+	}
+	body := make([]byte, 24)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0
+	}
+	if binary.LittleEndian.Uint16(body[0:]) != wavFormatExtensible {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(body[20:])
+}
+
+// channelRouting maps each channel of a file with the given channel mask to its position in the
+// default ALSA layout for dstChannels. A channel with no matching output speaker maps to -1.
+// A nil routing means the channels can be passed through as they are.
+func channelRouting(mask uint32, srcChannels, dstChannels int) []int {
+	// Mono and stereo outputs have nothing to reorder.
+	layout, ok := alsaLayouts[dstChannels]
+	if mask == 0 || dstChannels < 3 || !ok {
+		return nil
+	}
+
+	var positions []uint32
+	for bit := uint32(1); bit != 0 && len(positions) < srcChannels; bit <<= 1 {
+		if mask&bit != 0 {
+			positions = append(positions, bit)
+		}
+	}
+
+	routing := make([]int, srcChannels)
+	used := make([]bool, dstChannels)
+	identity := srcChannels == dstChannels
+	for i := range routing {
+		routing[i] = -1
+		if i >= len(positions) {
+			// More channels than mask bits, these have no defined position.
+			identity = false
+			continue
+		}
+		dst := speakerIndex(layout, used, positions[i])
+		if dst < 0 {
+			if alias, ok := speakerAliases[positions[i]]; ok {
+				dst = speakerIndex(layout, used, alias)
+			}
+		}
+		if dst >= 0 {
+			used[dst] = true
+		}
+		routing[i] = dst
+		if dst != i {
+			identity = false
+		}
+	}
+	if identity {
+		return nil
+	}
+	return routing
+}
+
+func speakerIndex(layout []uint32, used []bool, speaker uint32) int {
+	for i, s := range layout {
+		if s == speaker && !used[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// routeChannels reorders interleaved samples according to routing, leaving unrouted outputs silent.
+func routeChannels(samples []int, routing []int, dstChannels int) []int {
+	srcChannels := len(routing)
+	frames := len(samples) / srcChannels
+	out := make([]int, frames*dstChannels)
+	for f := 0; f < frames; f++ {
+		for c, dst := range routing {
+			if dst >= 0 {
+				out[f*dstChannels+dst] = samples[f*srcChannels+c]
+			}
+		}
+	}
+	return out
+}
+
+// remapChannels fits the file's samples to the device's channels: a downmix matrix weighs the file's channels
+// as they're stored, so it applies to the samples before anything else, and otherwise the routing
+// moves each channel to its speaker. Either can be nil.
+func remapChannels(samples []int, matrix [][]float64, routing []int, dstChannels, bitDepth int) []int {
+	if matrix != nil {
+		return downmix(samples, matrix, bitDepth)
+	}
+	if routing != nil {
+		return routeChannels(samples, routing, dstChannels)
+	}
+	return samples
+}
+
+// minus3dB is the ITU-R BS.775 weight of the center and surround channels in a downmix.
+const minus3dB = 0.7071
+
+// defaultDownmix returns the ITU-R BS.775 downmix from srcChannels (in WAV order) to dstChannels,
+// or nil when there isn't a standard one. The LFE is dropped.
+// Rows are output channels and columns input channels.
+func defaultDownmix(srcChannels, dstChannels int) [][]float64 {
+	var stereo [][]float64
+	switch srcChannels {
+	case 2:
+		stereo = [][]float64{{1, 0}, {0, 1}}
+	case 3: // FL FR FC
+		stereo = [][]float64{
+			{1, 0, minus3dB},
+			{0, 1, minus3dB},
+		}
+	case 4: // FL FR BL BR
+		stereo = [][]float64{
+			{1, 0, minus3dB, 0},
+			{0, 1, 0, minus3dB},
+		}
+	case 5: // FL FR FC BL BR
+		stereo = [][]float64{
+			{1, 0, minus3dB, minus3dB, 0},
+			{0, 1, minus3dB, 0, minus3dB},
+		}
+	case 6: // FL FR FC LFE BL BR
+		stereo = [][]float64{
+			{1, 0, minus3dB, 0, minus3dB, 0},
+			{0, 1, minus3dB, 0, 0, minus3dB},
+		}
+	case 8: // FL FR FC LFE BL BR SL SR
+		stereo = [][]float64{
+			{1, 0, minus3dB, 0, minus3dB, 0, minus3dB, 0},
+			{0, 1, minus3dB, 0, 0, minus3dB, 0, minus3dB},
+		}
+	default:
+		return nil
+	}
+	switch dstChannels {
+	case 2:
+		return stereo
+	case 1:
+		mono := make([]float64, srcChannels)
+		for c := range mono {
+			mono[c] = (stereo[0][c] + stereo[1][c]) / 2
+		}
+		return [][]float64{mono}
+	}
+	return nil
+}
+
+// downmixFits reports whether the matrix has a row for each of dstChannels with a weight for each of srcChannels.
+func downmixFits(matrix [][]float64, srcChannels, dstChannels int) bool {
+	if len(matrix) != dstChannels {
+		return false
+	}
+	for _, row := range matrix {
+		if len(row) != srcChannels {
+			return false
+		}
+	}
+	return true
+}
+
+// downmix mixes interleaved samples down with the matrix, saturating at the limits of the bit depth.
+func downmix(samples []int, matrix [][]float64, bitDepth int) []int {
+	srcChannels := len(matrix[0])
+	dstChannels := len(matrix)
+	max := math.Ldexp(1, bitDepth-1) - 1
+	frames := len(samples) / srcChannels
+	out := make([]int, frames*dstChannels)
+	for f := 0; f < frames; f++ {
+		in := samples[f*srcChannels : (f+1)*srcChannels]
+		for d, row := range matrix {
+			var v float64
+			for c, coef := range row {
+				v += coef * float64(in[c])
+			}
+			out[f*dstChannels+d] = int(math.Round(math.Max(-max-1, math.Min(max, v))))
+		}
+	}
+	return out
+}
+
+// upmix fills channels channels from the recording's by cycling through them,
+// so mono goes to every channel and stereo to every pair.
+func upmix(recording alsa.Buffer, channels int) alsa.Buffer {
+	in := recording.Format.Channels
+	size := SampleSize(recording.Format.SampleFormat)
+	frames := len(recording.Data) / (in * size)
+	data := make([]byte, frames*channels*size)
+	for f := 0; f < frames; f++ {
+		for c := 0; c < channels; c++ {
+			src := (f*in + c%in) * size
+			copy(data[(f*channels+c)*size:], recording.Data[src:src+size])
+		}
+	}
+	format := recording.Format
+	format.Channels = channels
+	return alsa.Buffer{Format: format, Data: data}
+}
@@ -0,0 +1,79 @@
+package alsa
+
+import (
+	"reflect"
+	"testing"
+)
+
+// One frame of 5.1 in WAV order: FL FR FC LFE BL BR.
+var frame51 = []int{100, 200, 300, 400, 500, 600}
+
+const mask51 = speakerFrontLeft | speakerFrontRight | speakerFrontCenter | speakerLFE | speakerBackLeft | speakerBackRight
+
+func TestRemapChannelsRoutes(t *testing.T) {
+	routing := channelRouting(mask51, 6, 6)
+	if routing == nil {
+		t.Fatal("5.1 in WAV order needs routing to ALSA order")
+	}
+	// ALSA order: FL FR BL BR FC LFE.
+	if got, want := remapChannels(frame51, nil, routing, 6, 16), []int{100, 200, 500, 600, 300, 400}; !reflect.DeepEqual(got, want) {
+		t.Errorf("routed to %v, want %v", got, want)
+	}
+}
+
+// A custom downmix weighs the channels in the file's order, even when there's routing that would narrow them first.
+func TestRemapChannelsDownmixesBeforeRouting(t *testing.T) {
+	routing := channelRouting(mask51, 6, 4)
+	matrix := [][]float64{
+		{0, 0, 1, 0, 0, 0},
+		{0, 0, 0, 1, 0, 0},
+	}
+	if got, want := remapChannels(frame51, matrix, routing, 2, 16), []int{300, 400}; !reflect.DeepEqual(got, want) {
+		t.Errorf("downmixed to %v, want %v", got, want)
+	}
+}
+
+// The center of 5.1 goes to both sides of stereo at -3dB, and to mono.
+func TestDefaultDownmixCenter(t *testing.T) {
+	center := []int{0, 0, 10000, 0, 0, 0, 0, 0, 20000, 0, 0, 0}
+	if got, want := remapChannels(center, defaultDownmix(6, 2), nil, 2, 16), []int{7071, 7071, 14142, 14142}; !reflect.DeepEqual(got, want) {
+		t.Errorf("downmixed to stereo as %v, want %v", got, want)
+	}
+	if got, want := remapChannels(center, defaultDownmix(6, 1), nil, 1, 16), []int{7071, 14142}; !reflect.DeepEqual(got, want) {
+		t.Errorf("downmixed to mono as %v, want %v", got, want)
+	}
+}
+
+func TestDefaultDownmixShapes(t *testing.T) {
+	for src := 2; src <= 8; src++ {
+		for dst := 1; dst < src; dst++ {
+			matrix := defaultDownmix(src, dst)
+			if matrix == nil {
+				if src != 7 && dst <= 2 {
+					t.Errorf("no downmix from %d to %d channels", src, dst)
+				}
+				continue
+			}
+			if !downmixFits(matrix, src, dst) {
+				t.Errorf("downmix from %d to %d channels is %v", src, dst, matrix)
+			}
+		}
+	}
+}
+
+func TestDownmixFits(t *testing.T) {
+	tests := []struct {
+		matrix [][]float64
+		fits   bool
+	}{
+		{[][]float64{{1, 0, 1}, {0, 1, 1}}, true},
+		{[][]float64{{1, 0, 1}}, false},
+		{[][]float64{{1, 0, 1}, {0, 1}}, false}, // ragged
+		{[][]float64{{1, 0}, {0, 1, 1}}, false},
+	}
+	for _, tt := range tests {
+		if got := downmixFits(tt.matrix, 3, 2); got != tt.fits {
+			t.Errorf("%v fits 3 to 2 channels: %v, want %v", tt.matrix, got, tt.fits)
+		}
+	}
+}
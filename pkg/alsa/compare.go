@@ -0,0 +1,40 @@
+package alsa
+
+import (
+	"bytes"
+
+	"github.com/yobert/alsa"
+)
+
+// BuffersEqual reports whether two buffers have the same format and exactly the same samples.
+func BuffersEqual(a, b alsa.Buffer) bool {
+	return a.Format == b.Format && bytes.Equal(a.Data, b.Data)
+}
+
+// BuffersApproxEqual compares two buffers of the same format sample by sample, in the units of the format,
+// and reports whether no sample differs by more than tolerance, along with the largest difference.
+// Buffers with different formats, lengths or a format that can't be decoded are never equal.
+func BuffersApproxEqual(a, b alsa.Buffer, tolerance int) (bool, int) {
+	if a.Format != b.Format || len(a.Data) != len(b.Data) {
+		return false, 0
+	}
+	as, err := decodeSamples(a)
+	if err != nil {
+		return false, 0
+	}
+	bs, err := decodeSamples(b)
+	if err != nil {
+		return false, 0
+	}
+	maxDiff := 0
+	for i := range as {
+		diff := as[i] - bs[i]
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > maxDiff {
+			maxDiff = diff
+		}
+	}
+	return maxDiff <= tolerance, maxDiff
+}
@@ -0,0 +1,64 @@
+package alsa
+
+import (
+	"testing"
+
+	"github.com/yobert/alsa"
+)
+
+func s16Buffer(t *testing.T, samples ...int) alsa.Buffer {
+	t.Helper()
+	data, err := encodeSamples(samples, alsa.S16_LE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alsa.Buffer{Format: alsa.BufferFormat{SampleFormat: alsa.S16_LE, Rate: 8000, Channels: 1}, Data: data}
+}
+
+func TestBuffersEqual(t *testing.T) {
+	a := s16Buffer(t, 1, -2, 3)
+	otherRate := s16Buffer(t, 1, -2, 3)
+	otherRate.Format.Rate = 16000
+	tests := []struct {
+		name string
+		b    alsa.Buffer
+		want bool
+	}{
+		{"same", s16Buffer(t, 1, -2, 3), true},
+		{"different sample", s16Buffer(t, 1, -2, 4), false},
+		{"shorter", s16Buffer(t, 1, -2), false},
+		{"different format", otherRate, false},
+	}
+	for _, test := range tests {
+		if got := BuffersEqual(a, test.b); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestBuffersApproxEqual(t *testing.T) {
+	a := s16Buffer(t, 100, -100, 0)
+	tests := []struct {
+		name      string
+		b         alsa.Buffer
+		tolerance int
+		want      bool
+		maxDiff   int
+	}{
+		{"same", s16Buffer(t, 100, -100, 0), 0, true, 0},
+		{"within tolerance", s16Buffer(t, 102, -101, 0), 2, true, 2},
+		{"negative difference", s16Buffer(t, 100, -103, 0), 2, false, 3},
+		{"shorter", s16Buffer(t, 100, -100), 10, false, 0},
+	}
+	for _, test := range tests {
+		got, maxDiff := BuffersApproxEqual(a, test.b, test.tolerance)
+		if got != test.want || maxDiff != test.maxDiff {
+			t.Errorf("%s: got %v with a difference of %d, want %v with %d", test.name, got, maxDiff, test.want, test.maxDiff)
+		}
+	}
+
+	float := alsa.Buffer{Format: alsa.BufferFormat{SampleFormat: alsa.FLOAT_LE}, Data: make([]byte, 8)}
+	if equal, _ := BuffersApproxEqual(float, float, 0); equal {
+		t.Error("compared samples it can't decode")
+	}
+}
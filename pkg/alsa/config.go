@@ -0,0 +1,87 @@
+package alsa
+
+import (
+	"time"
+
+	"github.com/yobert/alsa"
+)
+
+// AudioConfig describes what to ask a device for when negotiating parameters.
+// It's shared by the play and record paths so tuning latency happens in one place.
+// Any field left at its zero value falls back to what that path has always used:
+//
+//	Playback:      the file's channel count (or 2), 44100 Hz, S32_LE then S16_LE
+//	               (S24_LE, S24_3LE, S32_LE then S16_LE for 24-bit files),
+//	               a 2048 frame period and a buffer of two periods.
+//	Recording:     the requested channels and rate, S16_LE, S32_LE then S24_3LE,
+//	               no period negotiation and an 8192 or 16384 frame buffer.
+//	PrepareDevice: 2 (or 1) channels, 44100 Hz, S16_LE, S32_LE then S24_3LE,
+//	               the period and buffer sizes up to the device.
+//
+// There's no access type: yobert/alsa only does interleaved access, see Deinterleave for planar processing.
+type AudioConfig struct {
+	// Channels is the channel count to negotiate.
+	Channels int
+	// Rates are tried in order of preference.
+	Rates []int
+	// Formats are tried in order of preference.
+	Formats []alsa.FormatType
+	// PeriodDuration is the amount of audio moved per device read or write.
+	PeriodDuration time.Duration
+	// BufferDuration is the size of the device's ring buffer.
+	BufferDuration time.Duration
+	// BufferFrames is the size of the device's ring buffer in frames, for callers that already count in frames.
+	// It takes precedence over BufferDuration.
+	BufferFrames int
+}
+
+func (c AudioConfig) channelChoices(defaults ...int) []int {
+	if c.Channels > 0 {
+		return []int{c.Channels}
+	}
+	return defaults
+}
+
+func (c AudioConfig) rateChoices(defaults ...int) []int {
+	if len(c.Rates) > 0 {
+		return c.Rates
+	}
+	return defaults
+}
+
+func (c AudioConfig) formatChoices(defaults ...alsa.FormatType) []alsa.FormatType {
+	if len(c.Formats) > 0 {
+		return c.Formats
+	}
+	return defaults
+}
+
+func (c AudioConfig) periodFrames(rate int, defaultFrames int) int {
+	if c.PeriodDuration > 0 {
+		return durationToFrames(c.PeriodDuration, rate)
+	}
+	return defaultFrames
+}
+
+func (c AudioConfig) bufferFrames(rate int, defaultFrames ...int) []int {
+	switch {
+	case c.BufferFrames > 0:
+		return []int{c.BufferFrames}
+	case c.BufferDuration > 0:
+		return []int{durationToFrames(c.BufferDuration, rate)}
+	}
+	return defaultFrames
+}
+
+// byteRate is the bytes per second a capture negotiated with the first choice of every field takes up.
+func (c AudioConfig) byteRate() int {
+	return c.channelChoices(2)[0] * c.rateChoices(44100)[0] * SampleSize(c.formatChoices(alsa.S16_LE)[0])
+}
+
+func durationToFrames(d time.Duration, rate int) int {
+	return int(float64(rate)*d.Seconds() + 0.5)
+}
+
+func framesToDuration(frames, rate int) time.Duration {
+	return time.Duration(frames) * time.Second / time.Duration(rate)
+}
@@ -0,0 +1,312 @@
+package alsa
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/yobert/alsa"
+)
+
+// CopyOpts control what CopyWav changes on the way.
+type CopyOpts struct {
+	// StripChunks drops everything but the fmt and data chunks.
+	// By default metadata, cue points, bext and any unknown chunks are carried over verbatim.
+	StripChunks bool
+	// BitDepth converts the audio to 16, 24 or 32 bit PCM. 0 keeps the original depth.
+	BitDepth int
+	// Rate resamples the audio to this rate in Hz, see ResampleBuffer. 0 keeps the original rate.
+	// The sample positions in the cue, bext and fact chunks are scaled to match.
+	Rate int
+}
+
+// CopyWav copies a WAV file chunk by chunk, so unlike decoding and re-encoding
+// it keeps the chunks the audio libraries don't know about, in their original order.
+func CopyWav(in, out string, opts CopyOpts) error {
+	if opts.Rate < 0 {
+		return fmt.Errorf("invalid rate %d Hz", opts.Rate)
+	}
+	inf, err := os.Open(in)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %q", in)
+	}
+	defer inf.Close()
+
+	chunks, err := walkChunks(inf)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the chunks of %q", in)
+	}
+	fmtChunk, ok := findChunk(chunks, "fmt ")
+	if !ok {
+		return fmt.Errorf("%q has no fmt chunk", in)
+	}
+	if _, ok := findChunk(chunks, "data"); !ok {
+		return fmt.Errorf("%q has no data chunk", in)
+	}
+	srcFmt, err := readFmtChunk(inf, fmtChunk)
+	if err != nil {
+		return err
+	}
+
+	dstFmt := srcFmt
+	if opts.BitDepth != 0 {
+		dstFmt.bitsPerSample = uint16(opts.BitDepth)
+	}
+	if opts.Rate != 0 {
+		dstFmt.sampleRate = uint32(opts.Rate)
+	}
+	convert := dstFmt != srcFmt
+	if convert {
+		if srcFmt.audioFormat != wavFormatPCM {
+			return fmt.Errorf("can only convert PCM audio")
+		}
+		if srcFmt.numChannels == 0 || srcFmt.sampleRate == 0 {
+			return fmt.Errorf("%q has %d channels at %d Hz", in, srcFmt.numChannels, srcFmt.sampleRate)
+		}
+		if _, err := wavBitsFormat(int(srcFmt.bitsPerSample)); err != nil {
+			return err
+		}
+		if _, err := wavBitsFormat(int(dstFmt.bitsPerSample)); err != nil {
+			return err
+		}
+		dstFmt.blockAlign = dstFmt.numChannels * dstFmt.bitsPerSample / 8
+		dstFmt.byteRate = dstFmt.sampleRate * uint32(dstFmt.blockAlign)
+	}
+	rescale := func(position uint64) uint64 {
+		return (position*uint64(dstFmt.sampleRate) + uint64(srcFmt.sampleRate)/2) / uint64(srcFmt.sampleRate)
+	}
+
+	of, err := CreateFile(out, false)
+	if err != nil {
+		return err
+	}
+	defer of.Close()
+
+	if _, err := of.Write([]byte("RIFF\x00\x00\x00\x00WAVE")); err != nil {
+		return err
+	}
+	riffSize := int64(4)
+	for _, c := range chunks {
+		var written int64
+		switch {
+		case c.id == "fmt " && convert:
+			written, err = writeFmtChunk(of, dstFmt)
+		case c.id == "data" && convert:
+			written, err = convertDataChunk(of, inf, c, srcFmt, dstFmt)
+		case isPositionChunk(c.id) && !opts.StripChunks && dstFmt.sampleRate != srcFmt.sampleRate:
+			written, err = copyPositionChunk(of, inf, c, rescale)
+		case c.id == "fmt " || c.id == "data" || !opts.StripChunks:
+			written, err = copyChunk(of, inf, c)
+		default:
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to copy %q chunk", c.id)
+		}
+		riffSize += written
+	}
+
+	if riffSize > math.MaxUint32 {
+		return fmt.Errorf("%q would be over 4GB, which only fits in an RF64 file", out)
+	}
+	if _, err := of.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+	return binary.Write(of, binary.LittleEndian, uint32(riffSize))
+}
+
+// wavBitsFormat returns the ALSA format holding samples of a PCM WAV file with the given bit depth.
+func wavBitsFormat(bits int) (alsa.FormatType, error) {
+	switch bits {
+	case 16:
+		return alsa.S16_LE, nil
+	case 24:
+		return S24_3LE, nil
+	case 32:
+		return alsa.S32_LE, nil
+	}
+	return alsa.Unknown, fmt.Errorf("unsupported bit depth %d", bits)
+}
+
+func writeChunkHeader(w io.Writer, id string, size uint32) error {
+	header := make([]byte, chunkHeaderSize)
+	copy(header, id)
+	binary.LittleEndian.PutUint32(header[4:], size)
+	_, err := w.Write(header)
+	return err
+}
+
+// copyChunk copies a chunk as it is, pad byte included. It returns the bytes written.
+func copyChunk(w io.Writer, r io.ReadSeeker, c riffChunk) (int64, error) {
+	if err := writeChunkHeader(w, c.id, uint32(c.size)); err != nil {
+		return 0, err
+	}
+	if _, err := r.Seek(c.offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if _, err := io.CopyN(w, r, c.size); err != nil {
+		return 0, err
+	}
+	if c.size%2 == 1 {
+		if _, err := w.Write([]byte{0}); err != nil {
+			return 0, err
+		}
+	}
+	return chunkHeaderSize + c.size + c.size%2, nil
+}
+
+func writeFmtChunk(w io.Writer, f wavFmt) (int64, error) {
+	body := fmtBody(f)
+	size := int64(len(body))
+	if err := writeChunkHeader(w, "fmt ", uint32(size)); err != nil {
+		return 0, err
+	}
+	if size%2 == 1 {
+		body = append(body, 0)
+	}
+	_, err := w.Write(body)
+	return chunkHeaderSize + size + size%2, err
+}
+
+// convertDataChunk rewrites the samples of a data chunk at the bit depth and rate of dst, a block of frames at a time.
+// The size of resampled audio isn't known until it's written, so the chunk header is filled in afterwards.
+func convertDataChunk(w io.WriteSeeker, r io.ReadSeeker, c riffChunk, src, dst wavFmt) (int64, error) {
+	srcFormat, _ := wavBitsFormat(int(src.bitsPerSample))
+	dstFormat, _ := wavBitsFormat(int(dst.bitsPerSample))
+	channels := int(src.numChannels)
+	var resampler *streamResampler
+	if src.sampleRate != dst.sampleRate {
+		resampler = newStreamResampler(int(src.sampleRate), int(dst.sampleRate), channels)
+	}
+
+	header, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if err := writeChunkHeader(w, "data", 0); err != nil {
+		return 0, err
+	}
+	if _, err := r.Seek(c.offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	var size int64
+	write := func(samples []int) error {
+		data, err := encodeSamples(samples, dstFormat)
+		if err != nil {
+			return err
+		}
+		size += int64(len(data))
+		_, err = w.Write(data)
+		return err
+	}
+	block := make([]byte, 4096*int(src.blockAlign))
+	for remaining := c.size - c.size%int64(src.blockAlign); remaining > 0; {
+		if remaining < int64(len(block)) {
+			block = block[:remaining]
+		}
+		if _, err := io.ReadFull(r, block); err != nil {
+			return 0, err
+		}
+		remaining -= int64(len(block))
+
+		samples, err := decodeSamples(alsa.Buffer{
+			Format: alsa.BufferFormat{SampleFormat: srcFormat},
+			Data:   block,
+		})
+		if err != nil {
+			return 0, err
+		}
+		for i, sample := range samples {
+			samples[i] = scaleBits(sample, int(src.bitsPerSample), int(dst.bitsPerSample))
+		}
+		if resampler != nil {
+			samples = resampler.process(samples)
+		}
+		if err := write(samples); err != nil {
+			return 0, err
+		}
+	}
+	if resampler != nil {
+		if err := write(resampler.flush()); err != nil {
+			return 0, err
+		}
+	}
+	if size > math.MaxUint32 {
+		return 0, fmt.Errorf("converted audio is too large for a WAV file (%d bytes)", size)
+	}
+
+	if size%2 == 1 {
+		if _, err := w.Write([]byte{0}); err != nil {
+			return 0, err
+		}
+	}
+	end, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.Seek(header, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err := writeChunkHeader(w, "data", uint32(size)); err != nil {
+		return 0, err
+	}
+	if _, err := w.Seek(end, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return chunkHeaderSize + size + size%2, nil
+}
+
+// isPositionChunk reports whether the chunk holds sample positions that a new rate moves.
+func isPositionChunk(id string) bool {
+	return id == "cue " || id == "bext" || id == "fact"
+}
+
+// copyPositionChunk copies a cue, bext or fact chunk with its sample positions passed through rescale:
+// the position and sample offset of every cue point, the bext time reference and the fact sample count.
+// A chunk too short to hold them is copied as it is.
+func copyPositionChunk(w io.Writer, r io.ReadSeeker, c riffChunk, rescale func(uint64) uint64) (int64, error) {
+	if _, err := r.Seek(c.offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	body := make([]byte, c.size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, err
+	}
+	scale32 := func(b []byte) {
+		binary.LittleEndian.PutUint32(b, uint32(rescale(uint64(binary.LittleEndian.Uint32(b)))))
+	}
+	switch c.id {
+	case "cue ":
+		// A count, then 24 bytes per point: id, position, chunk id, chunk start, block start, sample offset.
+		if len(body) >= 4 {
+			points := int(binary.LittleEndian.Uint32(body))
+			for i, off := 0, 4; i < points && off+24 <= len(body); i, off = i+1, off+24 {
+				scale32(body[off+4:])
+				scale32(body[off+20:])
+			}
+		}
+	case "bext":
+		// The time reference, in samples since midnight, follows the 338 bytes of text fields.
+		if len(body) >= 346 {
+			binary.LittleEndian.PutUint64(body[338:], rescale(binary.LittleEndian.Uint64(body[338:])))
+		}
+	case "fact":
+		if len(body) >= 4 {
+			scale32(body)
+		}
+	}
+	if err := writeChunkHeader(w, c.id, uint32(c.size)); err != nil {
+		return 0, err
+	}
+	if c.size%2 == 1 {
+		body = append(body, 0)
+	}
+	if _, err := w.Write(body); err != nil {
+		return 0, err
+	}
+	return chunkHeaderSize + c.size + c.size%2, nil
+}
@@ -0,0 +1,124 @@
+package alsa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/yobert/alsa"
+)
+
+func cueChunk(positions ...uint32) testChunk {
+	body := appendUint32(nil, uint32(len(positions)))
+	for i, p := range positions {
+		body = appendUint32(body, uint32(i+1))
+		body = appendUint32(body, p)
+		body = append(body, "data"...)
+		body = appendUint32(body, 0)
+		body = appendUint32(body, 0)
+		body = appendUint32(body, p)
+	}
+	return testChunk{"cue ", body}
+}
+
+func bextChunk(timeReference uint64) testChunk {
+	body := make([]byte, 602)
+	copy(body, "broadcast")
+	binary.LittleEndian.PutUint64(body[338:], timeReference)
+	return testChunk{"bext", body}
+}
+
+func TestCopyWavKeepsChunks(t *testing.T) {
+	audio := sineBuffer(t, alsa.S16_LE, 2, 48000, 100)
+	in := writeTemp(t, "in.wav", wavBytes(
+		bextChunk(48000*3600),
+		pcmFmt(2, 48000, 16),
+		testChunk{"odd ", []byte{1, 2, 3}},
+		testChunk{"data", audio.Data},
+		cueChunk(10, 50),
+	))
+	out := writeTemp(t, "out.wav", nil)
+	if err := CopyWav(in, out, CopyOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readFile(t, out), readFile(t, in); !bytes.Equal(got, want) {
+		t.Errorf("copy differs from the original")
+	}
+}
+
+func TestCopyWavRate(t *testing.T) {
+	const frames = 4800
+	audio := sineBuffer(t, alsa.S16_LE, 2, 48000, frames)
+	in := writeTemp(t, "in.wav", wavBytes(
+		bextChunk(48000*3600),
+		pcmFmt(2, 48000, 16),
+		testChunk{"fact", appendUint32(nil, frames)},
+		testChunk{"data", audio.Data},
+		cueChunk(10, 4800),
+	))
+	out := writeTemp(t, "out.wav", nil)
+	if err := CopyWav(in, out, CopyOpts{Rate: 24000, BitDepth: 24}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := readFile(t, out)
+	if binary.LittleEndian.Uint32(data[4:]) != uint32(len(data)-8) {
+		t.Errorf("RIFF size %d, file is %d bytes", binary.LittleEndian.Uint32(data[4:]), len(data))
+	}
+	chunks, err := ListChunks(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids string
+	for _, c := range chunks {
+		ids += c.ID
+	}
+	if want := "bextfmt factdatacue "; ids != want {
+		t.Fatalf("got chunks %q, want %q", ids, want)
+	}
+
+	body := func(i int) []byte {
+		start := chunks[i].Offset + chunkHeaderSize
+		return data[start : start+chunks[i].Size]
+	}
+	if got := binary.LittleEndian.Uint64(body(0)[338:]); got != 24000*3600 {
+		t.Errorf("bext time reference %d, want %d", got, 24000*3600)
+	}
+	f := body(1)
+	if rate, bits := binary.LittleEndian.Uint32(f[4:]), binary.LittleEndian.Uint16(f[14:]); rate != 24000 || bits != 24 {
+		t.Errorf("fmt says %d Hz at %d bits, want 24000 Hz at 24 bits", rate, bits)
+	}
+	if got := binary.LittleEndian.Uint32(body(2)); got != frames/2 {
+		t.Errorf("fact sample count %d, want %d", got, frames/2)
+	}
+	if got := chunks[3].Size / 6; got != frames/2 {
+		t.Errorf("got %d frames of audio, want %d", got, frames/2)
+	}
+	cue := body(4)
+	for i, want := range []uint32{5, 2400} {
+		point := cue[4+24*i:]
+		if pos, off := binary.LittleEndian.Uint32(point[4:]), binary.LittleEndian.Uint32(point[20:]); pos != want || off != want {
+			t.Errorf("cue point %d at %d/%d, want %d", i, pos, off, want)
+		}
+	}
+}
+
+func TestCopyWavRateNeedsPCM(t *testing.T) {
+	f := pcmFmt(1, 48000, 32)
+	binary.LittleEndian.PutUint16(f.body, 3) // IEEE float
+	in := writeTemp(t, "in.wav", wavBytes(f, testChunk{"data", make([]byte, 40)}))
+	if err := CopyWav(in, writeTemp(t, "out.wav", nil), CopyOpts{Rate: 44100}); err == nil {
+		t.Errorf("resampled float audio")
+	}
+}
+
+func TestCopyWavMissingDirectory(t *testing.T) {
+	in := writeTemp(t, "in.wav", wavBytes(pcmFmt(1, 8000, 16), testChunk{"data", make([]byte, 8)}))
+	err := CopyWav(in, filepath.Join(t.TempDir(), "missing", "out.wav"), CopyOpts{})
+	var notFound *DirectoryNotFound
+	if !errors.As(err, &notFound) {
+		t.Errorf("got %v, want a DirectoryNotFound", err)
+	}
+}
@@ -0,0 +1,63 @@
+package alsa
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-audio/audio"
+	"github.com/pkg/errors"
+)
+
+// ExpectedDataBytes returns the size of the data chunk holding dur of audio in the given format.
+// Durations that don't land on a frame boundary are rounded to the nearest frame, like a capture of that length.
+func ExpectedDataBytes(format audio.Format, bitDepth int, dur time.Duration) int {
+	frameSize := format.NumChannels * ((bitDepth + 7) / 8)
+	return durationToFrames(dur, format.SampleRate) * frameSize
+}
+
+// VerifyDataSize checks that the data chunk of a WAV file declares the size that dur of audio
+// in the file's format takes up, and that all of it is actually in the file.
+// Inconsistencies are reported with a *DataSizeMismatch.
+func VerifyDataSize(file string, dur time.Duration) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %q", file)
+	}
+	defer f.Close()
+
+	chunks, err := walkChunks(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the chunks of %q", file)
+	}
+	fmtChunk, ok := findChunk(chunks, "fmt ")
+	if !ok {
+		return fmt.Errorf("%q has no fmt chunk", file)
+	}
+	dataChunk, ok := findChunk(chunks, "data")
+	if !ok {
+		return fmt.Errorf("%q has no data chunk", file)
+	}
+	header, err := readFmtChunk(f, fmtChunk)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	actual := info.Size() - dataChunk.offset
+	if actual > dataChunk.size {
+		actual = dataChunk.size
+	}
+
+	expected := int64(ExpectedDataBytes(audio.Format{
+		NumChannels: int(header.numChannels),
+		SampleRate:  int(header.sampleRate),
+	}, int(header.bitsPerSample), dur))
+	if dataChunk.size != expected || actual != dataChunk.size {
+		return &DataSizeMismatch{Declared: dataChunk.size, Expected: expected, Actual: actual}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package alsa
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-audio/audio"
+)
+
+func TestExpectedDataBytes(t *testing.T) {
+	tests := []struct {
+		channels, rate, bits int
+		dur                  time.Duration
+		want                 int
+	}{
+		{2, 8000, 16, time.Second, 32000},
+		{1, 44100, 24, 100 * time.Millisecond, 13230},
+		{2, 48000, 32, 10 * time.Millisecond, 3840},
+		{1, 8000, 16, 62500 * time.Microsecond, 1000}, // 500 frames exactly
+		{1, 8000, 16, 62600 * time.Microsecond, 1002}, // rounds to the nearest frame
+	}
+	for _, tt := range tests {
+		format := audio.Format{NumChannels: tt.channels, SampleRate: tt.rate}
+		if got := ExpectedDataBytes(format, tt.bits, tt.dur); got != tt.want {
+			t.Errorf("%d channels at %d Hz, %d bits for %v: got %d bytes, want %d",
+				tt.channels, tt.rate, tt.bits, tt.dur, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyDataSize(t *testing.T) {
+	// 50ms of 16-bit stereo at 8000 Hz.
+	file := writeTemp(t, "ok.wav", wavBytes(pcmFmt(2, 8000, 16), testChunk{"data", make([]byte, 1600)}))
+	if err := VerifyDataSize(file, 50*time.Millisecond); err != nil {
+		t.Errorf("matching file: %v", err)
+	}
+
+	err := VerifyDataSize(file, 100*time.Millisecond)
+	var mismatch *DataSizeMismatch
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("got %v, want a DataSizeMismatch", err)
+	}
+	if want := (DataSizeMismatch{Declared: 1600, Expected: 3200, Actual: 1600}); *mismatch != want {
+		t.Errorf("got %+v, want %+v", *mismatch, want)
+	}
+
+	// The header is right but the file was cut short.
+	cut, _ := cutShort(t, 400, 436, 0)
+	data := readFile(t, cut)
+	cut = writeTemp(t, "short.wav", data[:len(data)-100])
+	err = VerifyDataSize(cut, 12500*time.Microsecond)
+	if !errors.As(err, &mismatch) || mismatch.Declared != 400 || mismatch.Actual != 300 {
+		t.Errorf("got %v, want 300 of 400 bytes present", err)
+	}
+}
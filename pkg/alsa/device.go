@@ -1,10 +1,48 @@
 package alsa
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/pkg/errors"
 	"github.com/yobert/alsa"
+
+	"github.com/renan-campos/sound-utils/pkg/logging"
 )
 
+// DeviceInfo describes a device of a card.
+type DeviceInfo struct {
+	Number int
+	Title  string
+	Play   bool
+	Record bool
+	Path   string // The device node, /dev/snd/pcmC<card>D<device>p or c for PCM devices.
+}
+
+// ListDevices lists the devices of the card, in the order the card reports them.
+// Nothing is opened, the card just has to be open itself.
+func ListDevices(card *alsa.Card) ([]DeviceInfo, error) {
+	return listDevices(card, cardDevices)
+}
+
+func listDevices(card *alsa.Card, devicesOf func(*alsa.Card) ([]*alsa.Device, error)) ([]DeviceInfo, error) {
+	devices, err := devicesOf(card)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get card devices")
+	}
+	list := make([]DeviceInfo, len(devices))
+	for i, device := range devices {
+		list[i] = DeviceInfo{
+			Number: device.Number,
+			Title:  device.Title,
+			Play:   device.Play,
+			Record: device.Record,
+			Path:   device.Path,
+		}
+	}
+	return list, nil
+}
+
 func FindPlayableDevice(card *alsa.Card, deviceName string) (*alsa.Device, error) {
 	devices, err := card.Devices()
 	if err != nil {
@@ -36,3 +74,50 @@ func FindRecordableDevice(card *alsa.Card, deviceName string) (*alsa.Device, err
 	}
 	return nil, &DeviceNotFound{deviceName: deviceName}
 }
+
+// DefaultPlaybackDevice finds the first playback PCM device, on the lowest numbered card that has one.
+// The caller closes the card with CloseCard when done; the other cards are closed right away.
+func DefaultPlaybackDevice() (*alsa.Card, *alsa.Device, error) {
+	return defaultDevice(Playback)
+}
+
+// DefaultRecordDevice is DefaultPlaybackDevice for capture devices.
+func DefaultRecordDevice() (*alsa.Card, *alsa.Device, error) {
+	return defaultDevice(Capture)
+}
+
+func defaultDevice(direction Direction) (*alsa.Card, *alsa.Device, error) {
+	cards, err := openCards()
+	if err != nil {
+		return nil, nil, err
+	}
+	card, device := firstDevice(cards, cardDevices, direction)
+	closeAllBut(cards, card)
+	if card == nil {
+		return nil, nil, fmt.Errorf("no %v device found", direction)
+	}
+	return card, device, nil
+}
+
+// firstDevice returns the first PCM device going the direction, looking through the cards by number.
+// Cards whose devices can't be listed are skipped.
+func firstDevice(cards []*alsa.Card, devicesOf func(*alsa.Card) ([]*alsa.Device, error), direction Direction) (*alsa.Card, *alsa.Device) {
+	sorted := append([]*alsa.Card(nil), cards...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Number < sorted[j].Number })
+	for _, card := range sorted {
+		devices, err := devicesOf(card)
+		if err != nil {
+			logging.Debugf("Failed to get the devices of card %d: %v", card.Number, err)
+			continue
+		}
+		for _, device := range devices {
+			if device.Type != alsa.PCM {
+				continue
+			}
+			if (direction == Capture && device.Record) || (direction == Playback && device.Play) {
+				return card, device
+			}
+		}
+	}
+	return nil, nil
+}
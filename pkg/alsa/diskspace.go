@@ -0,0 +1,42 @@
+package alsa
+
+import (
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// EstimatedRecordTime returns how long a recording made with cfg can run before it fills
+// the filesystem that path is on. path can be the file about to be written, it doesn't need to exist yet.
+// Fields of cfg left at their zero value are taken as the recording defaults (stereo, 44100 Hz, S16_LE).
+func EstimatedRecordTime(path string, cfg AudioConfig) (time.Duration, error) {
+	free, err := freeSpace(path)
+	if err != nil {
+		return 0, err
+	}
+	return recordTime(free, cfg.byteRate()), nil
+}
+
+func recordTime(free uint64, byteRate int) time.Duration {
+	if byteRate <= 0 {
+		return 0
+	}
+	seconds := float64(free) / float64(byteRate)
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// freeSpace returns the bytes available to an unprivileged user on the filesystem holding path.
+// A path that doesn't exist yet is looked up through its directory.
+func freeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(path, &stat)
+	if err == syscall.ENOENT {
+		err = syscall.Statfs(filepath.Dir(path), &stat)
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get free space for %q", path)
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,98 @@
+package alsa
+
+import (
+	"math"
+	"time"
+
+	"github.com/yobert/alsa"
+)
+
+// CompressOpts are the optional extras of CompressWithOpts.
+type CompressOpts struct {
+	// MakeupGainDB is applied after compression to bring the level back up.
+	MakeupGainDB float64
+	// Linked uses the loudest channel to drive the gain of all of them,
+	// so the stereo image doesn't shift when one side gets loud.
+	Linked bool
+}
+
+// Compress evens out the dynamic range of the recording.
+// Whenever the level rises above threshold (in dBFS), the excess is divided by ratio;
+// a ratio of 4 turns 8dB over the threshold into 2dB over.
+// attack and release set how quickly the gain reacts to the level going up and coming back down.
+// A very high ratio with a short attack makes a limiter.
+// Buffers with a format that can't be converted are returned unchanged.
+func Compress(recording alsa.Buffer, threshold float64, ratio float64, attack, release time.Duration) alsa.Buffer {
+	return CompressWithOpts(recording, threshold, ratio, attack, release, CompressOpts{})
+}
+
+func CompressWithOpts(recording alsa.Buffer, threshold float64, ratio float64, attack, release time.Duration, opts CompressOpts) alsa.Buffer {
+	channels := recording.Format.Channels
+	rate := recording.Format.Rate
+	samples, err := decodeFloats(recording)
+	if err != nil || channels < 1 || rate < 1 || ratio < 1 {
+		return recording
+	}
+
+	attackCoef := envelopeCoefficient(attack, rate)
+	releaseCoef := envelopeCoefficient(release, rate)
+	makeup := dbToGain(opts.MakeupGainDB)
+
+	// The envelope follows the peak level of each channel (or of all of them when linked),
+	// rising at the attack speed and falling at the release speed.
+	envelopes := make([]float64, channels)
+	frames := len(samples) / channels
+	for f := 0; f < frames; f++ {
+		frame := samples[f*channels : (f+1)*channels]
+		var linkedLevel float64
+		if opts.Linked {
+			for _, v := range frame {
+				linkedLevel = math.Max(linkedLevel, math.Abs(v))
+			}
+		}
+		for c, v := range frame {
+			level := math.Abs(v)
+			if opts.Linked {
+				level = linkedLevel
+			}
+			coef := releaseCoef
+			if level > envelopes[c] {
+				coef = attackCoef
+			}
+			envelopes[c] = coef*envelopes[c] + (1-coef)*level
+
+			gain := makeup
+			if envelopeDB := gainToDB(envelopes[c]); envelopeDB > threshold {
+				over := envelopeDB - threshold
+				gain *= dbToGain(over/ratio - over)
+			}
+			frame[c] = v * gain
+		}
+	}
+
+	data, err := encodeFloats(samples, recording.Format.SampleFormat)
+	if err != nil {
+		return recording
+	}
+	return alsa.Buffer{Format: recording.Format, Data: data}
+}
+
+// envelopeCoefficient is the smoothing factor of a one pole filter that
+// gets about two thirds of the way to a new level in d.
+func envelopeCoefficient(d time.Duration, rate int) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return math.Exp(-1 / (d.Seconds() * float64(rate)))
+}
+
+func dbToGain(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+func gainToDB(gain float64) float64 {
+	if gain <= 0 {
+		return math.Inf(-1)
+	}
+	return 20 * math.Log10(gain)
+}
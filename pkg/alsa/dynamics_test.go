@@ -0,0 +1,58 @@
+package alsa
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/yobert/alsa"
+)
+
+// burstBuffer is mono S16_LE at 8000 Hz: a second of a 440 Hz tone that's loud for the first 100ms and quiet after.
+func burstBuffer(t *testing.T) alsa.Buffer {
+	t.Helper()
+	samples := make([]float64, 8000)
+	for i := range samples {
+		amp := 0.05
+		if i < 800 {
+			amp = 0.9
+		}
+		samples[i] = amp * math.Sin(2*math.Pi*440*float64(i)/8000)
+	}
+	data, err := encodeFloats(samples, alsa.S16_LE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alsa.Buffer{Format: alsa.BufferFormat{SampleFormat: alsa.S16_LE, Rate: 8000, Channels: 1}, Data: data}
+}
+
+func crestFactor(t *testing.T, buf alsa.Buffer) float64 {
+	t.Helper()
+	samples, err := decodeFloats(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rms, peak := level(samples)
+	return peak / rms
+}
+
+func TestCompressEvensOutLevels(t *testing.T) {
+	in := burstBuffer(t)
+	out := Compress(in, -20, 4, 0, 50*time.Millisecond)
+	if len(out.Data) != len(in.Data) || out.Format != in.Format {
+		t.Fatalf("compressed to %d bytes of %+v", len(out.Data), out.Format)
+	}
+	before, after := crestFactor(t, in), crestFactor(t, out)
+	if after >= before*0.9 {
+		t.Errorf("peak to RMS went from %.2f to %.2f, want it to drop", before, after)
+	}
+
+	// The quiet part is under the threshold, so once the release is over it's left alone.
+	inSamples, _ := decodeFloats(in)
+	outSamples, _ := decodeFloats(out)
+	for i := 4000; i < len(inSamples); i++ {
+		if math.Abs(inSamples[i]-outSamples[i]) > 1e-4 {
+			t.Fatalf("quiet sample %d went from %.4f to %.4f", i, inSamples[i], outSamples[i])
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package alsa
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/yobert/alsa"
+)
+
+// TruncateToFrames returns the first frames frames of the recording.
+// frames is clamped to what the recording holds, so asking for more returns the whole recording
+// and a trailing partial frame is never included.
+// The returned buffer shares its data with the recording.
+func TruncateToFrames(buf alsa.Buffer, frames int) alsa.Buffer {
+	frameSize := bytesPerFrame(buf.Format)
+	if frameSize == 0 {
+		return alsa.Buffer{Format: buf.Format}
+	}
+	if available := len(buf.Data) / frameSize; frames > available {
+		frames = available
+	}
+	if frames < 0 {
+		frames = 0
+	}
+	return alsa.Buffer{Format: buf.Format, Data: buf.Data[:frames*frameSize]}
+}
+
+// TrimSilence returns the recording without the frames at the start and end where every channel is below thresholdDB dBFS.
+// A recording that's silent throughout comes back empty, and one in a format that can't be decoded comes back as is.
+// The returned buffer shares its data with the recording.
+func TrimSilence(buf alsa.Buffer, thresholdDB float64) alsa.Buffer {
+	frameSize := bytesPerFrame(buf.Format)
+	samples, err := decodeSamples(buf)
+	if err != nil || frameSize == 0 {
+		return buf
+	}
+	threshold := dbToGain(thresholdDB) * (float64(maxSample(buf.Format.SampleFormat)) + 1)
+	channels := buf.Format.Channels
+	loud := func(frame int) bool {
+		for _, sample := range samples[frame*channels : (frame+1)*channels] {
+			if math.Abs(float64(sample)) >= threshold {
+				return true
+			}
+		}
+		return false
+	}
+
+	frames := len(buf.Data) / frameSize
+	start, end := 0, frames
+	for start < end && !loud(start) {
+		start++
+	}
+	for end > start && !loud(end-1) {
+		end--
+	}
+	return alsa.Buffer{Format: buf.Format, Data: buf.Data[start*frameSize : end*frameSize]}
+}
+
+// InsertSilence returns a copy of the recording with dur of silence spliced in at position at,
+// both rounded to whole frames. at may be anywhere from the start to the end of the recording.
+func InsertSilence(buf alsa.Buffer, at, dur time.Duration) (alsa.Buffer, error) {
+	if _, err := sampleBytes(buf.Format.SampleFormat); err != nil {
+		return alsa.Buffer{}, err
+	}
+	frameSize := bytesPerFrame(buf.Format)
+	if frameSize == 0 || buf.Format.Rate < 1 {
+		return alsa.Buffer{}, fmt.Errorf("invalid format %d channels %d Hz", buf.Format.Channels, buf.Format.Rate)
+	}
+	if dur < 0 {
+		return alsa.Buffer{}, fmt.Errorf("negative silence duration %s", dur)
+	}
+	frames := len(buf.Data) / frameSize
+	atFrame := durationToFrames(at, buf.Format.Rate)
+	if at < 0 || atFrame > frames {
+		return alsa.Buffer{}, fmt.Errorf("position %s is outside the %s recording", at, framesToDuration(frames, buf.Format.Rate))
+	}
+
+	// All the sample formats are signed, so silence is zero bytes.
+	silence := durationToFrames(dur, buf.Format.Rate) * frameSize
+	data := make([]byte, frames*frameSize+silence)
+	copy(data, buf.Data[:atFrame*frameSize])
+	copy(data[atFrame*frameSize+silence:], buf.Data[atFrame*frameSize:frames*frameSize])
+	return alsa.Buffer{Format: buf.Format, Data: data}, nil
+}
+
+// CutOpts tweak how CutRegionWithOpts joins the audio either side of the cut.
+type CutOpts struct {
+	// Crossfade blends the audio leading up to the cut into the audio leading up to the end of the removed region
+	// over this long, so the join continues smoothly instead of clicking. A few milliseconds is plenty.
+	// The length of the result doesn't change.
+	Crossfade time.Duration
+}
+
+// CutRegion returns a copy of the recording with the region from start up to end removed, both rounded to whole frames.
+func CutRegion(buf alsa.Buffer, start, end time.Duration) (alsa.Buffer, error) {
+	return CutRegionWithOpts(buf, start, end, CutOpts{})
+}
+
+func CutRegionWithOpts(buf alsa.Buffer, start, end time.Duration, opts CutOpts) (alsa.Buffer, error) {
+	frameSize := bytesPerFrame(buf.Format)
+	if frameSize == 0 || buf.Format.Rate < 1 {
+		return alsa.Buffer{}, fmt.Errorf("invalid format %d channels %d Hz", buf.Format.Channels, buf.Format.Rate)
+	}
+	frames := len(buf.Data) / frameSize
+	startFrame := durationToFrames(start, buf.Format.Rate)
+	endFrame := durationToFrames(end, buf.Format.Rate)
+	if start < 0 || end < start || endFrame > frames {
+		return alsa.Buffer{}, fmt.Errorf("region %s-%s is not within the %s recording",
+			start, end, framesToDuration(frames, buf.Format.Rate))
+	}
+
+	data := make([]byte, (frames-endFrame+startFrame)*frameSize)
+	copy(data, buf.Data[:startFrame*frameSize])
+	copy(data[startFrame*frameSize:], buf.Data[endFrame*frameSize:frames*frameSize])
+	out := alsa.Buffer{Format: buf.Format, Data: data}
+
+	fade := durationToFrames(opts.Crossfade, buf.Format.Rate)
+	if fade > startFrame {
+		fade = startFrame
+	}
+	if fade < 1 || startFrame == endFrame {
+		return out, nil
+	}
+	// The audio just before endFrame runs straight into what follows the cut,
+	// so fading over to it before the join leaves nothing to jump at the join itself.
+	samples, err := decodeSamples(out)
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+	lead, err := decodeSamples(alsa.Buffer{Format: buf.Format, Data: buf.Data[(endFrame-fade)*frameSize : endFrame*frameSize]})
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+	crossfade(samples[(startFrame-fade)*buf.Format.Channels:], lead, buf.Format)
+	if out.Data, err = encodeSamples(samples, buf.Format.SampleFormat); err != nil {
+		return alsa.Buffer{}, err
+	}
+	return out, nil
+}
+
+// crossfade fades the start of samples out and lead in over the frames in lead, leaving it all lead by the last frame.
+func crossfade(samples, lead []int, format alsa.BufferFormat) {
+	channels := format.Channels
+	frames := len(lead) / channels
+	for i, v := range lead {
+		w := float64(i/channels+1) / float64(frames)
+		samples[i] = clampSample(float64(samples[i])*(1-w)+float64(v)*w, format.SampleFormat)
+	}
+}
+
+// FadeIn ramps the start of the recording up from silence over d, or over the whole recording if it's shorter.
+// Buffers with a format that can't be converted are returned unchanged.
+func FadeIn(recording alsa.Buffer, d time.Duration) alsa.Buffer {
+	return applyFade(recording, d, true)
+}
+
+// FadeOut ramps the end of the recording down to silence over d, or over the whole recording if it's shorter.
+// Buffers with a format that can't be converted are returned unchanged.
+func FadeOut(recording alsa.Buffer, d time.Duration) alsa.Buffer {
+	return applyFade(recording, d, false)
+}
+
+// applyFade applies a linear ramp, starting at zero for a fade in and ending at zero for a fade out.
+func applyFade(recording alsa.Buffer, d time.Duration, in bool) alsa.Buffer {
+	channels := recording.Format.Channels
+	samples, err := decodeSamples(recording)
+	if err != nil || channels < 1 || recording.Format.Rate < 1 {
+		return recording
+	}
+	frames := len(samples) / channels
+	n := durationToFrames(d, recording.Format.Rate)
+	if n > frames {
+		n = frames
+	}
+	for i := 0; i < n; i++ {
+		frame := i
+		w := float64(i) / float64(n)
+		if !in {
+			frame = frames - n + i
+			w = float64(n-1-i) / float64(n)
+		}
+		for c := 0; c < channels; c++ {
+			s := frame*channels + c
+			samples[s] = clampSample(float64(samples[s])*w, recording.Format.SampleFormat)
+		}
+	}
+	data, err := encodeSamples(samples, recording.Format.SampleFormat)
+	if err != nil {
+		return recording
+	}
+	return alsa.Buffer{Format: recording.Format, Data: data}
+}
@@ -0,0 +1,101 @@
+package alsa
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yobert/alsa"
+)
+
+// paddedBuffer is stereo: lead frames of silence, loud frames at half scale and tail frames of silence.
+// The silence carries a little noise on the left, well below -60dBFS.
+func paddedBuffer(t *testing.T, format alsa.FormatType, lead, loud, tail int) alsa.Buffer {
+	t.Helper()
+	frames := lead + loud + tail
+	half := maxSample(format) / 2
+	samples := make([]int, 2*frames)
+	for f := 0; f < frames; f++ {
+		if f >= lead && f < lead+loud {
+			samples[2*f], samples[2*f+1] = half, -half
+		} else {
+			samples[2*f] = maxSample(format) / 10000 * (f%3 - 1)
+		}
+	}
+	data, err := encodeSamples(samples, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alsa.Buffer{Format: alsa.BufferFormat{SampleFormat: format, Rate: 8000, Channels: 2}, Data: data}
+}
+
+func TestTrimSilence(t *testing.T) {
+	for _, format := range []alsa.FormatType{alsa.S16_LE, alsa.S32_LE} {
+		frameSize := 2 * SampleSize(format)
+		buf := paddedBuffer(t, format, 100, 200, 50)
+		trimmed := TrimSilence(buf, -60)
+		if got := len(trimmed.Data) / frameSize; got != 200 {
+			t.Errorf("%v: trimmed to %d frames, want 200", format, got)
+		}
+		samples, err := decodeSamples(trimmed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if half := maxSample(format) / 2; samples[0] != half || samples[len(samples)-1] != -half {
+			t.Errorf("%v: trimmed to start at %d and end at %d", format, samples[0], samples[len(samples)-1])
+		}
+
+		if silent := TrimSilence(paddedBuffer(t, format, 100, 0, 0), -60); len(silent.Data) != 0 {
+			t.Errorf("%v: a silent buffer trimmed to %d bytes", format, len(silent.Data))
+		}
+		if whole := TrimSilence(paddedBuffer(t, format, 0, 10, 0), -60); len(whole.Data) != 10*frameSize {
+			t.Errorf("%v: a buffer without silence trimmed to %d bytes", format, len(whole.Data))
+		}
+	}
+}
+
+func TestFades(t *testing.T) {
+	// 100 frames at half scale, 12.5ms at 8000 Hz.
+	buf := paddedBuffer(t, alsa.S16_LE, 0, 100, 0)
+	half := maxSample(alsa.S16_LE) / 2
+	tests := []struct {
+		name  string
+		fade  func(alsa.Buffer, time.Duration) alsa.Buffer
+		d     time.Duration
+		check func(samples []int) bool
+	}{
+		{"in", FadeIn, 5 * time.Millisecond, func(s []int) bool {
+			// 40 frames of ramp, the rest left alone.
+			return s[0] == 0 && s[1] == 0 && abs(s[2]) < half/20 && s[80] == half && s[199] == -half
+		}},
+		{"out", FadeOut, 5 * time.Millisecond, func(s []int) bool {
+			return s[0] == half && s[119] == -half && abs(s[197]) < half/20 && s[198] == 0 && s[199] == 0
+		}},
+		{"in longer than the buffer", FadeIn, time.Second, func(s []int) bool {
+			return s[0] == 0 && abs(s[2]) < half/50 && abs(s[198]) > half*9/10
+		}},
+		{"out longer than the buffer", FadeOut, time.Second, func(s []int) bool {
+			return abs(s[0]) > half*9/10 && abs(s[197]) < half/50 && s[199] == 0
+		}},
+	}
+	for _, tt := range tests {
+		faded := tt.fade(buf, tt.d)
+		if len(faded.Data) != len(buf.Data) {
+			t.Errorf("%s: faded to %d bytes", tt.name, len(faded.Data))
+			continue
+		}
+		samples, err := decodeSamples(faded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tt.check(samples) {
+			t.Errorf("%s: faded to %v ... %v", tt.name, samples[:4], samples[len(samples)-4:])
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -1,13 +1,41 @@
 package alsa
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"syscall"
+)
 
-type cardNotFound struct{ cardName string }
+type cardNotFound struct {
+	cardName  string
+	available []string // Titles of the cards there are, if they're worth mentioning.
+}
 
 func (cnf *cardNotFound) Error() string {
+	if len(cnf.available) > 0 {
+		return fmt.Sprintf("Card %q not found, available cards: %s", cnf.cardName, quoteList(cnf.available))
+	}
 	return fmt.Sprintf("Card %q not found", cnf.cardName)
 }
 
+// AmbiguousCard is returned when more than one card matches a name.
+type AmbiguousCard struct {
+	Name    string
+	Matches []string
+}
+
+func (a *AmbiguousCard) Error() string {
+	return fmt.Sprintf("%q matches more than one card: %s", a.Name, quoteList(a.Matches))
+}
+
+func quoteList(list []string) string {
+	quoted := make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return strings.Join(quoted, ", ")
+}
+
 type DeviceNotFound struct{ deviceName string }
 
 func (cnf *DeviceNotFound) Error() string {
@@ -17,5 +45,79 @@ func (cnf *DeviceNotFound) Error() string {
 type deviceNotPlayable struct{ deviceName string }
 
 func (d *deviceNotPlayable) Error() string {
-	return fmt.Sprint("unable to play audio on device %q", d)
+	return fmt.Sprintf("unable to play audio on device %q", d.deviceName)
+}
+
+// Overrun is returned when the device had to drop captured frames because they weren't read in time.
+type Overrun struct{ Frame int }
+
+func (o *Overrun) Error() string {
+	return fmt.Sprintf("Capture overrun after %d frames", o.Frame)
+}
+
+// ioctlError is a failed yobert/alsa ioctl along with its errno.
+type ioctlError struct {
+	msg   string
+	errno syscall.Errno
+}
+
+func (e *ioctlError) Error() string {
+	return e.msg
+}
+
+func (e *ioctlError) Unwrap() error {
+	return e.errno
+}
+
+// deviceErrnos are the errnos a read or write can fail with that callers want to tell apart.
+var deviceErrnos = []syscall.Errno{syscall.EPIPE, syscall.ESTRPIPE, syscall.EBADFD, syscall.EAGAIN}
+
+// deviceError gives an error from a device read or write its errno back, so it can be checked with errors.Is.
+// yobert/alsa formats the errno into the message with %v, which loses it; this is the one place it's recovered.
+func deviceError(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, errno := range deviceErrnos {
+		if strings.HasSuffix(err.Error(), "failed: "+errno.Error()) {
+			return &ioctlError{msg: err.Error(), errno: errno}
+		}
+	}
+	return err
+}
+
+// DataSizeMismatch is returned when a WAV file's data chunk isn't the size its format and duration call for.
+// Actual is the number of data bytes really present in the file, which is less than Declared when it was cut short.
+// Expected is -1 when there was no duration to go by, as from WavReport.Err.
+type DataSizeMismatch struct {
+	Declared int64
+	Expected int64
+	Actual   int64
+}
+
+func (d *DataSizeMismatch) Error() string {
+	if d.Expected < 0 {
+		return fmt.Sprintf("data chunk declares %d bytes, %d present in the file", d.Declared, d.Actual)
+	}
+	return fmt.Sprintf("data chunk declares %d bytes, expected %d (%d present in the file)", d.Declared, d.Expected, d.Actual)
+}
+
+// UnsupportedFormat is returned for audio files the package can't decode.
+type UnsupportedFormat struct {
+	Path   string
+	Format string // What the file looks like, if it could be recognized.
+}
+
+func (u *UnsupportedFormat) Error() string {
+	if u.Format == "" {
+		return fmt.Sprintf("unsupported format: %q is not a recognized audio file", u.Path)
+	}
+	return fmt.Sprintf("unsupported format: %q is %s, which can't be played yet", u.Path, u.Format)
+}
+
+// DirectoryNotFound is returned when a file can't be created because its directory doesn't exist.
+type DirectoryNotFound struct{ Dir string }
+
+func (d *DirectoryNotFound) Error() string {
+	return fmt.Sprintf("directory %q does not exist", d.Dir)
 }
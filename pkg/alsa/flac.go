@@ -0,0 +1,179 @@
+package alsa
+
+import (
+	"fmt"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+	"github.com/yobert/alsa"
+)
+
+/*
+FLAC is written with mewkiz/flac, which codes subframes the way it's told to, so picking the coding is up to us:
+every block is coded on its own with each channel as a separate subframe, using whichever of the
+fixed polynomial predictors (orders 0-4) leaves the smallest residual, Rice coded as a single partition.
+Blocks that don't compress are stored verbatim. That gets most of the way to what flac -0 does.
+
+The bit depth is the one SaveWav writes. mewkiz/flac only goes up to 24 bits, so S32_LE captures are
+refused rather than cut down, save those with SaveWav.
+*/
+
+const flacBlockSize = 4096
+
+// SaveFlac saves the recording as a FLAC file, S16_LE as 16-bit and S24_LE and S24_3LE as 24-bit.
+func SaveFlac(recording alsa.Buffer, file string) error {
+	if err := validateRecording(recording); err != nil {
+		return err
+	}
+	channels := recording.Format.Channels
+	if channels > 8 {
+		return fmt.Errorf("FLAC holds up to 8 channels, the recording has %d", channels)
+	}
+	bits := SampleBits(recording.Format.SampleFormat)
+	if bits > 24 {
+		return fmt.Errorf("FLAC is written up to 24 bits, save %d-bit recordings with SaveWav", bits)
+	}
+	samples, err := decodeSamples(recording)
+	if err != nil {
+		return err
+	}
+
+	of, err := CreateFile(file, false)
+	if err != nil {
+		return err
+	}
+	frames := len(samples) / channels
+	// The encoder fills in the sample count and MD5 signature again when it's closed.
+	enc, err := flac.NewEncoder(of, &meta.StreamInfo{
+		BlockSizeMin:  flacBlockSize,
+		BlockSizeMax:  flacBlockSize,
+		SampleRate:    uint32(recording.Format.Rate),
+		NChannels:     uint8(channels),
+		BitsPerSample: uint8(bits),
+		NSamples:      uint64(frames),
+	})
+	if err != nil {
+		of.Close()
+		return err
+	}
+
+	for start := 0; start < frames; start += flacBlockSize {
+		size := frames - start
+		if size > flacBlockSize {
+			size = flacBlockSize
+		}
+		f := &frame.Frame{
+			Header: frame.Header{
+				HasFixedBlockSize: true,
+				BlockSize:         uint16(size),
+				Channels:          frame.Channels(channels - 1), // every channel on its own
+				BitsPerSample:     uint8(bits),
+			},
+		}
+		for c := 0; c < channels; c++ {
+			channel := make([]int32, size)
+			for i := range channel {
+				channel[i] = int32(samples[(start+i)*channels+c])
+			}
+			f.Subframes = append(f.Subframes, flacSubframe(channel, bits))
+		}
+		if err := enc.WriteFrame(f); err != nil {
+			enc.Close()
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+// flacSubframe codes one channel of a block with the best fixed predictor, or verbatim if nothing beats it.
+func flacSubframe(x []int32, bits int) *frame.Subframe {
+	sub := &frame.Subframe{
+		SubHeader: frame.SubHeader{Pred: frame.PredConstant},
+		Samples:   x,
+		NSamples:  len(x),
+	}
+	for _, v := range x {
+		if v != x[0] {
+			sub.Pred = frame.PredVerbatim
+			break
+		}
+	}
+	if sub.Pred == frame.PredConstant {
+		return sub
+	}
+
+	bestSize := uint64(len(x) * bits)
+	for order := 0; order <= 4 && order < len(x); order++ {
+		param, size := riceParam(fixedResidual(x, order))
+		if size += uint64(order * bits); size < bestSize {
+			bestSize = size
+			sub.Pred = frame.PredFixed
+			sub.Order = order
+			sub.ResidualCodingMethod = frame.ResidualCodingMethodRice1
+			if param > 14 {
+				sub.ResidualCodingMethod = frame.ResidualCodingMethodRice2
+			}
+			sub.RiceSubframe = &frame.RiceSubframe{
+				PartOrder:  0,
+				Partitions: []frame.RicePartition{{Param: uint(param)}},
+			}
+		}
+	}
+	return sub
+}
+
+// fixedResidual is what's left of x[order:] after the fixed polynomial predictor of the order.
+func fixedResidual(x []int32, order int) []int {
+	r := make([]int, len(x)-order)
+	for i := order; i < len(x); i++ {
+		switch order {
+		case 0:
+			r[i] = int(x[i])
+		case 1:
+			r[i-1] = int(x[i]) - int(x[i-1])
+		case 2:
+			r[i-2] = int(x[i]) - 2*int(x[i-1]) + int(x[i-2])
+		case 3:
+			r[i-3] = int(x[i]) - 3*int(x[i-1]) + 3*int(x[i-2]) - int(x[i-3])
+		case 4:
+			r[i-4] = int(x[i]) - 4*int(x[i-1]) + 6*int(x[i-2]) - 4*int(x[i-3]) + int(x[i-4])
+		}
+	}
+	return r
+}
+
+func zigzag(r int) uint64 {
+	if r < 0 {
+		return uint64(-r)*2 - 1
+	}
+	return uint64(r) * 2
+}
+
+// riceParam picks the Rice parameter for the residual and returns it with the size of the coded residual in bits.
+// Parameters up to 14 fit the 4-bit coding, bigger ones up to 30 need the 5-bit one.
+func riceParam(r []int) (int, uint64) {
+	var sum uint64
+	for _, v := range r {
+		sum += zigzag(v)
+	}
+	best, bestSize := 0, uint64(0)
+	for k := 0; k <= 30; k++ {
+		size := uint64(len(r)) * uint64(k+1)
+		for _, v := range r {
+			size += zigzag(v) >> uint(k)
+		}
+		if k == 0 || size < bestSize {
+			best, bestSize = k, size
+		}
+		// Every bigger parameter only adds bits once the quotients are all zero.
+		if sum>>uint(k) == 0 {
+			break
+		}
+	}
+	paramBits := uint64(4)
+	if best > 14 {
+		paramBits = 5
+	}
+	return best, bestSize + 6 + paramBits
+}
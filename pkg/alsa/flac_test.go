@@ -0,0 +1,100 @@
+package alsa
+
+import (
+	"bytes"
+	"crypto/md5"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewkiz/flac"
+	"github.com/yobert/alsa"
+)
+
+// flacSamples decodes a FLAC file back to interleaved samples, checking them against its MD5 signature.
+func flacSamples(t *testing.T, file string) ([]int, *flac.Stream) {
+	t.Helper()
+	stream, err := flac.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	sum := md5.New()
+	var samples []int
+	for {
+		f, err := stream.ParseNext()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Hash(sum)
+		for i := 0; i < int(f.BlockSize); i++ {
+			for _, sub := range f.Subframes {
+				samples = append(samples, int(sub.Samples[i]))
+			}
+		}
+	}
+	if !bytes.Equal(sum.Sum(nil), stream.Info.MD5sum[:]) {
+		t.Error("decoded audio doesn't match the MD5 signature")
+	}
+	return samples, stream
+}
+
+func TestSaveFlacRoundTrip(t *testing.T) {
+	tests := []struct {
+		format   alsa.FormatType
+		channels int
+		bits     int
+	}{
+		{alsa.S16_LE, 2, 16},
+		{alsa.S24_LE, 1, 24},
+		{S24_3LE, 3, 24},
+	}
+	for _, tt := range tests {
+		// A tone, noise that won't compress and silence, ending partway through a block.
+		const frames = 3*flacBlockSize + 100
+		recording := sineBuffer(t, tt.format, tt.channels, 48000, frames)
+		want, err := decodeSamples(recording)
+		if err != nil {
+			t.Fatal(err)
+		}
+		noise := rand.New(rand.NewSource(1))
+		for i := flacBlockSize * tt.channels; i < 2*flacBlockSize*tt.channels; i++ {
+			want[i] = noise.Intn(maxSample(tt.format)) - maxSample(tt.format)/2
+		}
+		for i := 2 * flacBlockSize * tt.channels; i < len(want); i++ {
+			want[i] = 0
+		}
+		if recording.Data, err = encodeSamples(want, tt.format); err != nil {
+			t.Fatal(err)
+		}
+
+		file := filepath.Join(t.TempDir(), "out.flac")
+		if err := SaveFlac(recording, file); err != nil {
+			t.Fatalf("%v: %v", tt.format, err)
+		}
+		got, stream := flacSamples(t, file)
+		info := stream.Info
+		if info.SampleRate != 48000 || int(info.NChannels) != tt.channels || int(info.BitsPerSample) != tt.bits || info.NSamples != frames {
+			t.Errorf("%v: stream info %+v", tt.format, info)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%v: got %d samples, want %d", tt.format, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%v: sample %d is %d, want %d", tt.format, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestSaveFlacRefuses32Bit(t *testing.T) {
+	recording := sineBuffer(t, alsa.S32_LE, 1, 48000, 100)
+	if err := SaveFlac(recording, filepath.Join(t.TempDir(), "out.flac")); err == nil {
+		t.Error("saved a 32-bit recording as FLAC")
+	}
+}
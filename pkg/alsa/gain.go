@@ -0,0 +1,84 @@
+package alsa
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/yobert/alsa"
+)
+
+// ApplyChannelGains scales every channel of the recording by its own gain factor.
+// gains[0] is applied to channel 0, gains[1] to channel 1 and so on.
+// Scaled samples are clamped to the range of the sample format.
+func ApplyChannelGains(recording alsa.Buffer, gains []float64) (alsa.Buffer, error) {
+	channels := recording.Format.Channels
+	if channels < 1 {
+		return alsa.Buffer{}, fmt.Errorf("invalid channel count %d", channels)
+	}
+	if len(gains) != channels {
+		return alsa.Buffer{}, fmt.Errorf("expected %d channel gains, got %d", channels, len(gains))
+	}
+
+	samples, err := decodeSamples(recording)
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+	for i, sample := range samples {
+		samples[i] = clampSample(float64(sample)*gains[i%channels], recording.Format.SampleFormat)
+	}
+
+	data, err := encodeSamples(samples, recording.Format.SampleFormat)
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+	return alsa.Buffer{Format: recording.Format, Data: data}, nil
+}
+
+// ApplyGain scales the recording by gainDB decibels. Samples pushed past full scale are clamped rather than wrapping.
+// Buffers with a format that can't be converted are returned unchanged.
+func ApplyGain(recording alsa.Buffer, gainDB float64) alsa.Buffer {
+	return scaleRecording(recording, dbToGain(gainDB))
+}
+
+// Normalize scales the recording so its loudest sample is at full scale.
+// Silent recordings and buffers with a format that can't be converted are returned unchanged.
+func Normalize(recording alsa.Buffer) alsa.Buffer {
+	samples, err := decodeSamples(recording)
+	if err != nil {
+		return recording
+	}
+	peak := 0
+	for _, sample := range samples {
+		if sample < 0 {
+			sample = -sample
+		}
+		if sample > peak {
+			peak = sample
+		}
+	}
+	if peak == 0 {
+		return recording
+	}
+	return scaleRecording(recording, float64(maxSample(recording.Format.SampleFormat))/float64(peak))
+}
+
+func scaleRecording(recording alsa.Buffer, gain float64) alsa.Buffer {
+	samples, err := decodeSamples(recording)
+	if err != nil {
+		return recording
+	}
+	for i, sample := range samples {
+		samples[i] = clampSample(float64(sample)*gain, recording.Format.SampleFormat)
+	}
+	data, err := encodeSamples(samples, recording.Format.SampleFormat)
+	if err != nil {
+		return recording
+	}
+	return alsa.Buffer{Format: recording.Format, Data: data}
+}
+
+// gainSample scales a sample of bitDepth bits, clamping it to the range of the bit depth.
+func gainSample(sample int, gain float64, bitDepth int) int {
+	max := math.Ldexp(1, bitDepth-1) - 1
+	return int(math.Round(math.Max(-max-1, math.Min(max, float64(sample)*gain))))
+}
@@ -0,0 +1,76 @@
+package alsa
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yobert/alsa"
+)
+
+func stereoS16(t *testing.T, samples ...int) alsa.Buffer {
+	t.Helper()
+	data, err := encodeSamples(samples, alsa.S16_LE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alsa.Buffer{Format: alsa.BufferFormat{SampleFormat: alsa.S16_LE, Rate: 8000, Channels: 2}, Data: data}
+}
+
+func TestApplyChannelGains(t *testing.T) {
+	in := stereoS16(t, 1000, 1000, -2000, 20000, 30000, -30000)
+	out, err := ApplyChannelGains(in, []float64{0.5, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples, err := decodeSamples(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The right channel is doubled, and clamped at full scale either way.
+	if want := []int{500, 2000, -1000, 32767, 15000, -32768}; !reflect.DeepEqual(samples, want) {
+		t.Errorf("got %v, want %v", samples, want)
+	}
+
+	if _, err := ApplyChannelGains(in, []float64{1}); err == nil {
+		t.Error("applied one gain to two channels")
+	}
+}
+
+func peakOf(t *testing.T, buf alsa.Buffer) int {
+	t.Helper()
+	samples, err := decodeSamples(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peak := 0
+	for _, s := range samples {
+		if abs(s) > peak {
+			peak = abs(s)
+		}
+	}
+	return peak
+}
+
+func TestApplyGain(t *testing.T) {
+	in := stereoS16(t, 1000, -8000, 4000, 0)
+	if peak := peakOf(t, ApplyGain(in, -6.0206)); peak != 4000 {
+		t.Errorf("-6dB took the peak to %d, want 4000", peak)
+	}
+	if peak := peakOf(t, ApplyGain(in, 20)); peak != 32768 {
+		t.Errorf("+20dB took the peak to %d, want it clamped at full scale", peak)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for _, format := range []alsa.FormatType{alsa.S16_LE, alsa.S32_LE} {
+		quiet := sineBuffer(t, format, 2, 8000, 800)
+		if peak := peakOf(t, Normalize(quiet)); peak != maxSample(format) {
+			t.Errorf("%v: normalized to a peak of %d, want %d", format, peak, maxSample(format))
+		}
+	}
+
+	silence := stereoS16(t, 0, 0, 0, 0)
+	if out := Normalize(silence); !reflect.DeepEqual(out, silence) {
+		t.Errorf("normalizing silence changed it to %v", out.Data)
+	}
+}
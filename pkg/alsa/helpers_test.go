@@ -0,0 +1,91 @@
+package alsa
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yobert/alsa"
+)
+
+type testChunk struct {
+	id   string
+	body []byte
+}
+
+// wavBytes builds a WAV file out of the chunks, filling in the sizes and pad bytes.
+func wavBytes(chunks ...testChunk) []byte {
+	out := []byte("RIFF\x00\x00\x00\x00WAVE")
+	for _, c := range chunks {
+		out = append(out, c.id...)
+		out = appendUint32(out, uint32(len(c.body)))
+		out = append(out, c.body...)
+		if len(c.body)%2 == 1 {
+			out = append(out, 0)
+		}
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out
+}
+
+func pcmFmt(channels, rate, bits int) testChunk {
+	return testChunk{"fmt ", fmtBody(wavFmt{
+		audioFormat:   wavFormatPCM,
+		numChannels:   uint16(channels),
+		sampleRate:    uint32(rate),
+		byteRate:      uint32(rate * channels * bits / 8),
+		blockAlign:    uint16(channels * bits / 8),
+		bitsPerSample: uint16(bits),
+	})}
+}
+
+// extensibleFmt is a WAVE_FORMAT_EXTENSIBLE fmt chunk for PCM with the given channel mask.
+func extensibleFmt(channels, rate, bits int, mask uint32) testChunk {
+	ext := make([]byte, 24)
+	binary.LittleEndian.PutUint16(ext[0:], 22)
+	binary.LittleEndian.PutUint16(ext[2:], uint16(bits))
+	binary.LittleEndian.PutUint32(ext[4:], mask)
+	copy(ext[8:], "\x01\x00\x00\x00\x00\x00\x10\x00\x80\x00\x00\xaa\x00\x38\x9b\x71") // KSDATAFORMAT_SUBTYPE_PCM
+	c := pcmFmt(channels, rate, bits)
+	binary.LittleEndian.PutUint16(c.body[0:], 0xFFFE)
+	c.body = append(c.body, ext...)
+	return c
+}
+
+func writeTemp(t testing.TB, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readFile(t testing.TB, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// sineBuffer is a 440Hz sine at half scale, the same on every channel.
+func sineBuffer(t testing.TB, format alsa.FormatType, channels, rate, frames int) alsa.Buffer {
+	t.Helper()
+	amp := float64(maxSample(format)) / 2
+	samples := make([]int, frames*channels)
+	for i := range samples {
+		samples[i] = int(amp * math.Sin(2*math.Pi*440*float64(i/channels)/float64(rate)))
+	}
+	data, err := encodeSamples(samples, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alsa.Buffer{
+		Format: alsa.BufferFormat{SampleFormat: format, Rate: rate, Channels: channels},
+		Data:   data,
+	}
+}
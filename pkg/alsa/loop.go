@@ -0,0 +1,121 @@
+package alsa
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yobert/alsa"
+)
+
+// LoopOpts tweak how PlayBufferLoopWithOpts plays the loop.
+type LoopOpts struct {
+	// Crossfade blends the end of the loop into the audio leading up to its start over this long,
+	// so a loop whose ends don't line up doesn't click every time round.
+	Crossfade time.Duration
+	Config    AudioConfig
+}
+
+// PlayBufferLoop plays the buffer up to loopEnd, then keeps repeating the frames from loopStart up to loopEnd
+// until ctx is done, the way a sampler sustains a note. Playback stops as soon as ctx is done.
+// The device has to accept the buffer's format as it is.
+func PlayBufferLoop(ctx context.Context, device *alsa.Device, buf alsa.Buffer, loopStart, loopEnd int) error {
+	return PlayBufferLoopWithOpts(ctx, device, buf, loopStart, loopEnd, LoopOpts{})
+}
+
+func PlayBufferLoopWithOpts(ctx context.Context, device *alsa.Device, buf alsa.Buffer, loopStart, loopEnd int, opts LoopOpts) error {
+	source, err := newLoopSource(buf, loopStart, loopEnd, durationToFrames(opts.Crossfade, buf.Format.Rate))
+	if err != nil {
+		return err
+	}
+
+	params, err := prepareDevice(device, opts.Config, deviceDefaults{
+		channels:     []int{buf.Format.Channels},
+		rates:        []int{buf.Format.Rate},
+		formats:      []alsa.FormatType{buf.Format.SampleFormat},
+		periodFrames: defaultPeriodFrames,
+	})
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	if params.Channels != buf.Format.Channels || params.Rate != buf.Format.Rate || params.Format != buf.Format.SampleFormat {
+		return fmt.Errorf("device negotiated %d channels %d Hz %v for a %d channel %d Hz %v buffer",
+			params.Channels, params.Rate, params.Format, buf.Format.Channels, buf.Format.Rate, buf.Format.SampleFormat)
+	}
+
+	period := make([]byte, params.PeriodSize*source.frameSize)
+	for ctx.Err() == nil {
+		source.read(period)
+		if err := device.Write(period, params.PeriodSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loopSource plays the head of a buffer once and then its loop forever.
+type loopSource struct {
+	head      []byte // frames before loopStart
+	loop      []byte // loopStart to loopEnd, with the crossfade applied
+	frameSize int
+	pos       int // bytes into head, then head+loop
+}
+
+func newLoopSource(buf alsa.Buffer, loopStart, loopEnd, fade int) (*loopSource, error) {
+	frameSize := bytesPerFrame(buf.Format)
+	if frameSize == 0 {
+		return nil, fmt.Errorf("invalid format %d channels %v", buf.Format.Channels, buf.Format.SampleFormat)
+	}
+	frames := len(buf.Data) / frameSize
+	if loopStart < 0 || loopEnd <= loopStart || loopEnd > frames {
+		return nil, fmt.Errorf("loop %d-%d is not within the %d frame buffer", loopStart, loopEnd, frames)
+	}
+	source := &loopSource{
+		head:      buf.Data[:loopStart*frameSize],
+		loop:      append([]byte(nil), buf.Data[loopStart*frameSize:loopEnd*frameSize]...),
+		frameSize: frameSize,
+	}
+
+	// Same trick as CutRegionWithOpts: the frames before loopStart run straight into it,
+	// so the end of the loop fades over to them.
+	if fade > loopStart {
+		fade = loopStart
+	}
+	if fade > loopEnd-loopStart {
+		fade = loopEnd - loopStart
+	}
+	if fade < 1 {
+		return source, nil
+	}
+	samples, err := decodeSamples(alsa.Buffer{Format: buf.Format, Data: source.loop})
+	if err != nil {
+		return nil, err
+	}
+	lead, err := decodeSamples(alsa.Buffer{Format: buf.Format, Data: buf.Data[(loopStart-fade)*frameSize : loopStart*frameSize]})
+	if err != nil {
+		return nil, err
+	}
+	crossfade(samples[(loopEnd-loopStart-fade)*buf.Format.Channels:], lead, buf.Format)
+	if source.loop, err = encodeSamples(samples, buf.Format.SampleFormat); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// read fills p with the next frames.
+func (s *loopSource) read(p []byte) {
+	for len(p) > 0 {
+		var n int
+		if s.pos < len(s.head) {
+			n = copy(p, s.head[s.pos:])
+		} else {
+			n = copy(p, s.loop[s.pos-len(s.head):])
+		}
+		p = p[n:]
+		s.pos += n
+		if s.pos == len(s.head)+len(s.loop) {
+			s.pos = len(s.head)
+		}
+	}
+}
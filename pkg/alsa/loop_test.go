@@ -0,0 +1,86 @@
+package alsa
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yobert/alsa"
+)
+
+// countingBuffer is stereo S16_LE where both samples of frame i are i.
+func countingBuffer(t *testing.T, frames int) alsa.Buffer {
+	t.Helper()
+	samples := make([]int, 2*frames)
+	for i := range samples {
+		samples[i] = i / 2
+	}
+	data, err := encodeSamples(samples, alsa.S16_LE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alsa.Buffer{Format: alsa.BufferFormat{SampleFormat: alsa.S16_LE, Rate: 8000, Channels: 2}, Data: data}
+}
+
+// frameValues reads frames from the source in reads of the given sizes and returns the left sample of each.
+func frameValues(t *testing.T, s *loopSource, sizes ...int) []int {
+	t.Helper()
+	var values []int
+	for _, frames := range sizes {
+		p := make([]byte, frames*s.frameSize)
+		s.read(p)
+		samples, err := decodeSamples(alsa.Buffer{Format: alsa.BufferFormat{SampleFormat: alsa.S16_LE, Channels: 2}, Data: p})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for f := 0; f < frames; f++ {
+			values = append(values, samples[2*f])
+		}
+	}
+	return values
+}
+
+func TestLoopSource(t *testing.T) {
+	s, err := newLoopSource(countingBuffer(t, 10), 3, 7, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.frameSize != 4 || len(s.head) != 3*4 || len(s.loop) != 4*4 {
+		t.Fatalf("%d byte frames, %d byte head, %d byte loop", s.frameSize, len(s.head), len(s.loop))
+	}
+	// Reads that don't line up with the loop still play it through, frame 7 onwards never plays.
+	want := []int{0, 1, 2, 3, 4, 5, 6, 3, 4, 5, 6, 3, 4, 5, 6, 3, 4}
+	if got := frameValues(t, s, 2, 5, 1, 9); !reflect.DeepEqual(got, want) {
+		t.Errorf("played %v, want %v", got, want)
+	}
+}
+
+func TestLoopSourceWholeBuffer(t *testing.T) {
+	s, err := newLoopSource(countingBuffer(t, 3), 0, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := frameValues(t, s, 7), []int{0, 1, 2, 0, 1, 2, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("played %v, want %v", got, want)
+	}
+}
+
+func TestLoopSourceCrossfade(t *testing.T) {
+	s, err := newLoopSource(countingBuffer(t, 10), 4, 8, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last frames of the loop blend into frames 2 and 3, which lead into its start, so it ends on frame 3.
+	want := []int{0, 1, 2, 3, 4, 5, 4, 3, 4, 5, 4, 3}
+	if got := frameValues(t, s, 12); !reflect.DeepEqual(got, want) {
+		t.Errorf("played %v, want %v", got, want)
+	}
+}
+
+func TestLoopSourceRange(t *testing.T) {
+	buf := countingBuffer(t, 10)
+	for _, r := range [][2]int{{-1, 5}, {5, 5}, {6, 5}, {0, 11}} {
+		if _, err := newLoopSource(buf, r[0], r[1], 0); err == nil {
+			t.Errorf("looped %d-%d of 10 frames", r[0], r[1])
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package alsa
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yobert/alsa"
+
+	"github.com/renan-campos/sound-utils/pkg/logging"
+)
+
+// RecordLoudestWindow records until ctx is done but only keeps the loudest stretch of audio of the given length,
+// measured by RMS, so an unattended capture waiting for a rare event returns just the event instead of hours of quiet.
+// If the capture stops before a whole window was recorded, everything recorded is returned.
+// Fields of cfg left at their zero value use the RecordWav defaults.
+func RecordLoudestWindow(ctx context.Context, rec *alsa.Device, window time.Duration, cfg AudioConfig) (alsa.Buffer, error) {
+	params, err := prepareDevice(rec, cfg, deviceDefaults{
+		channels:     []int{2},
+		rates:        []int{44100},
+		formats:      []alsa.FormatType{alsa.S16_LE, alsa.S32_LE},
+		bufferFrames: []int{8192, 16384},
+	})
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+	defer rec.Close()
+
+	format := rec.BufferFormat()
+	format.SampleFormat = params.Format
+	windowFrames := durationToFrames(window, params.Rate)
+	if windowFrames < 1 {
+		return alsa.Buffer{}, fmt.Errorf("window of %s is shorter than a frame", window)
+	}
+	tracker := newLoudestWindow(format, windowFrames)
+
+	fmt.Printf("Recording, keeping the loudest %s...\n", window)
+	chunk := make([]byte, params.BufferSize*rec.BytesPerFrame())
+	for ctx.Err() == nil {
+		if err := deviceError(rec.Read(chunk)); err != nil {
+			if !isOverrun(err) {
+				return alsa.Buffer{}, err
+			}
+			// A gap only matters if it lands in the loudest window, carry on.
+			logging.Debugf("Capture overrun, recovering")
+			if err := rec.Prepare(); err != nil {
+				return alsa.Buffer{}, errors.Wrap(err, "failed to recover from overrun")
+			}
+			continue
+		}
+		if err := tracker.add(chunk); err != nil {
+			return alsa.Buffer{}, err
+		}
+	}
+	fmt.Println("Recording stopped.")
+	return tracker.loudest(), nil
+}
+
+// loudestWindow keeps the last windowFrames frames in a ring along with their energy,
+// and a copy of the window with the most energy seen so far.
+type loudestWindow struct {
+	format     alsa.BufferFormat
+	frameSize  int
+	ring       []byte
+	energies   []float64
+	next       int // frame in the ring the next frame goes to
+	filled     int // frames in the ring
+	energy     float64
+	best       []byte
+	bestEnergy float64
+}
+
+func newLoudestWindow(format alsa.BufferFormat, windowFrames int) *loudestWindow {
+	frameSize := bytesPerFrame(format)
+	return &loudestWindow{
+		format:     format,
+		frameSize:  frameSize,
+		ring:       make([]byte, windowFrames*frameSize),
+		energies:   make([]float64, windowFrames),
+		bestEnergy: -1,
+	}
+}
+
+// add pushes the frames of a chunk through the window. The window is only compared against the
+// loudest one at the end of each chunk, copying it on every frame would be too slow.
+func (w *loudestWindow) add(chunk []byte) error {
+	samples, err := decodeFloats(alsa.Buffer{Format: w.format, Data: chunk})
+	if err != nil {
+		return err
+	}
+	channels := w.format.Channels
+	windowFrames := len(w.energies)
+	for f := 0; f < len(chunk)/w.frameSize; f++ {
+		var energy float64
+		for _, v := range samples[f*channels : (f+1)*channels] {
+			energy += v * v
+		}
+		w.energy += energy - w.energies[w.next]
+		w.energies[w.next] = energy
+		copy(w.ring[w.next*w.frameSize:], chunk[f*w.frameSize:(f+1)*w.frameSize])
+		w.next = (w.next + 1) % windowFrames
+		if w.filled < windowFrames {
+			w.filled++
+		}
+	}
+	if w.filled == windowFrames && w.energy > w.bestEnergy {
+		w.bestEnergy = w.energy
+		w.best = w.window()
+	}
+	return nil
+}
+
+// window returns the frames in the ring in the order they were recorded.
+func (w *loudestWindow) window() []byte {
+	if w.filled < len(w.energies) {
+		return append([]byte(nil), w.ring[:w.filled*w.frameSize]...)
+	}
+	start := w.next * w.frameSize
+	return append(append([]byte(nil), w.ring[start:]...), w.ring[:start]...)
+}
+
+func (w *loudestWindow) loudest() alsa.Buffer {
+	if w.best == nil {
+		return alsa.Buffer{Format: w.format, Data: w.window()}
+	}
+	return alsa.Buffer{Format: w.format, Data: w.best}
+}
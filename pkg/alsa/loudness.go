@@ -0,0 +1,189 @@
+package alsa
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/yobert/alsa"
+)
+
+/*
+Loudness is measured as described in ITU-R BS.1770:
+  - Every channel is K-weighted: a high shelf boosting the top end by 4dB, roughly modelling
+    the head, followed by a high pass that ignores the lowest bass.
+  - The mean square of the weighted signal is taken over 400ms blocks that overlap by 75%.
+  - Blocks quieter than -70 LUFS are dropped (silence), then blocks more than 10LU below
+    the loudness of the remaining ones (pauses), and what's left is averaged.
+*/
+
+const (
+	loudnessBlock     = 400 * time.Millisecond
+	loudnessStep      = 100 * time.Millisecond
+	absoluteGateLUFS  = -70
+	relativeGateLU    = -10
+	limiterCeilingDB  = -1
+	limiterAttack     = time.Millisecond
+	limiterRelease    = 50 * time.Millisecond
+	loudnessReference = -0.691
+)
+
+// biquad is a second order IIR filter in direct form I.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting returns the two filters of the BS.1770 K-weighting curve for the given rate.
+// The standard gives coefficients for 48kHz only, these are derived from the analog prototypes so any rate works.
+func kWeighting(rate int) (shelf, highPass biquad) {
+	// High shelf, +4dB above about 1.5kHz.
+	gain := math.Pow(10, 4.0/40)
+	w0 := 2 * math.Pi * 1500 / float64(rate)
+	alpha := math.Sin(w0) / (2 * (1 / math.Sqrt2))
+	cos := math.Cos(w0)
+	sq := 2 * math.Sqrt(gain) * alpha
+	a0 := (gain + 1) - (gain-1)*cos + sq
+	shelf = biquad{
+		b0: gain * ((gain + 1) + (gain-1)*cos + sq) / a0,
+		b1: -2 * gain * ((gain - 1) + (gain+1)*cos) / a0,
+		b2: gain * ((gain + 1) + (gain-1)*cos - sq) / a0,
+		a1: 2 * ((gain - 1) - (gain+1)*cos) / a0,
+		a2: ((gain + 1) - (gain-1)*cos - sq) / a0,
+	}
+
+	// High pass at 38Hz.
+	w0 = 2 * math.Pi * 38 / float64(rate)
+	alpha = math.Sin(w0) / (2 * 0.5)
+	cos = math.Cos(w0)
+	a0 = 1 + alpha
+	highPass = biquad{
+		b0: (1 + cos) / 2 / a0,
+		b1: -(1 + cos) / a0,
+		b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+	return shelf, highPass
+}
+
+// channelWeight is the BS.1770 weight of a channel: the surrounds of a 5.1 file count for more
+// and the LFE isn't counted at all.
+func channelWeight(channel, channels int) float64 {
+	if channels == 6 {
+		switch channel {
+		case 3:
+			return 0
+		case 4, 5:
+			return 1.41
+		}
+	}
+	return 1
+}
+
+// IntegratedLoudness returns the loudness of the whole recording in LUFS.
+// Recordings shorter than 400ms, or that are silent, measure as -Inf.
+func IntegratedLoudness(recording alsa.Buffer) float64 {
+	channels := recording.Format.Channels
+	rate := recording.Format.Rate
+	samples, err := decodeFloats(recording)
+	if err != nil || channels < 1 || rate < 1 {
+		return math.Inf(-1)
+	}
+	frames := len(samples) / channels
+
+	// Square of the K-weighted signal of every channel, summed up with the channel weights.
+	power := make([]float64, frames)
+	for c := 0; c < channels; c++ {
+		weight := channelWeight(c, channels)
+		if weight == 0 {
+			continue
+		}
+		shelf, highPass := kWeighting(rate)
+		for f := 0; f < frames; f++ {
+			y := highPass.process(shelf.process(samples[f*channels+c]))
+			power[f] += weight * y * y
+		}
+	}
+
+	blockFrames := durationToFrames(loudnessBlock, rate)
+	stepFrames := durationToFrames(loudnessStep, rate)
+	var blocks []float64
+	for start := 0; start+blockFrames <= frames; start += stepFrames {
+		var sum float64
+		for _, p := range power[start : start+blockFrames] {
+			sum += p
+		}
+		blocks = append(blocks, sum/float64(blockFrames))
+	}
+
+	gated := gateBlocks(blocks, powerFromLoudness(absoluteGateLUFS))
+	if len(gated) == 0 {
+		return math.Inf(-1)
+	}
+	relativeGate := meanPower(gated) * math.Pow(10, relativeGateLU/10.0)
+	return loudnessFromPower(meanPower(gateBlocks(gated, relativeGate)))
+}
+
+func gateBlocks(blocks []float64, threshold float64) []float64 {
+	var kept []float64
+	for _, b := range blocks {
+		if b > threshold {
+			kept = append(kept, b)
+		}
+	}
+	return kept
+}
+
+func meanPower(blocks []float64) float64 {
+	var sum float64
+	for _, b := range blocks {
+		sum += b
+	}
+	return sum / float64(len(blocks))
+}
+
+func loudnessFromPower(p float64) float64 {
+	return loudnessReference + 10*math.Log10(p)
+}
+
+func powerFromLoudness(lufs float64) float64 {
+	return math.Pow(10, (lufs-loudnessReference)/10)
+}
+
+// NormalizeLUFS writes a copy of the in WAV file with its integrated loudness brought to targetLUFS,
+// -16 for podcasts or -23 for broadcast for example.
+// When the gain would push the true peak (see TruePeak) past -1dBTP it's held down by a limiter
+// instead of clipping, which may leave the result a little below the target.
+func NormalizeLUFS(in, out string, targetLUFS float64) error {
+	recording, err := loadWav(in)
+	if err != nil {
+		return err
+	}
+	loudness := IntegratedLoudness(recording)
+	if math.IsInf(loudness, -1) {
+		return fmt.Errorf("%q is too short or too quiet to measure its loudness", in)
+	}
+
+	samples, err := decodeFloats(recording)
+	if err != nil {
+		return err
+	}
+	gain := dbToGain(targetLUFS - loudness)
+	for i := range samples {
+		samples[i] *= gain
+	}
+	limitTruePeak(samples, recording.Format.Channels, recording.Format.Rate, limiterCeilingDB, limiterAttack, limiterRelease)
+	data, err := encodeFloats(samples, recording.Format.SampleFormat)
+	if err != nil {
+		return err
+	}
+	return SaveWav(alsa.Buffer{Format: recording.Format, Data: data}, out)
+}
@@ -0,0 +1,66 @@
+package alsa
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yobert/alsa"
+)
+
+// toneBuffer is a stereo sine at the given frequency, level (as a fraction of full scale) and phase.
+func toneBuffer(t testing.TB, format alsa.FormatType, rate, frames int, freq, amp, phase float64) alsa.Buffer {
+	t.Helper()
+	samples := make([]float64, 2*frames)
+	for i := range samples {
+		samples[i] = amp * math.Sin(2*math.Pi*freq*float64(i/2)/float64(rate)+phase)
+	}
+	data, err := encodeFloats(samples, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alsa.Buffer{Format: alsa.BufferFormat{SampleFormat: format, Rate: rate, Channels: 2}, Data: data}
+}
+
+func normalize(t *testing.T, recording alsa.Buffer, target float64) alsa.Buffer {
+	t.Helper()
+	in := writeTemp(t, "in.wav", nil)
+	if err := SaveWav(recording, in); err != nil {
+		t.Fatal(err)
+	}
+	out := writeTemp(t, "out.wav", nil)
+	if err := NormalizeLUFS(in, out, target); err != nil {
+		t.Fatal(err)
+	}
+	normalized, err := loadWav(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return normalized
+}
+
+func TestNormalizeLUFS(t *testing.T) {
+	quiet := toneBuffer(t, alsa.S16_LE, 44100, 2*44100, 1000, 0.01, 0)
+	normalized := normalize(t, quiet, -16)
+	if got := IntegratedLoudness(normalized); math.Abs(got+16) > 0.5 {
+		t.Errorf("normalized to %.2f LUFS, want -16", got)
+	}
+	if peak := TruePeak(normalized); peak > limiterCeilingDB {
+		t.Errorf("true peak %.2f dBTP", peak)
+	}
+}
+
+// A tone at a quarter of the rate, sampled 45 degrees off its peaks, reaches 3dB above its samples between them.
+// Limiting the samples to the ceiling would still let it over.
+func TestNormalizeLUFSLimitsTruePeak(t *testing.T) {
+	tone := toneBuffer(t, alsa.S16_LE, 44100, 2*44100, 44100/4, 0.1, math.Pi/4)
+	if gap := TruePeak(tone) - SamplePeak(tone); gap < 2.5 {
+		t.Fatalf("the tone's true peak is only %.2f dB above its sample peak", gap)
+	}
+	normalized := normalize(t, tone, -3)
+	if peak := TruePeak(normalized); peak > limiterCeilingDB+0.05 {
+		t.Errorf("true peak %.2f dBTP, the ceiling is %d", peak, limiterCeilingDB)
+	}
+	if peak := SamplePeak(normalized); peak > 0 {
+		t.Errorf("sample peak %.2f dBFS", peak)
+	}
+}
@@ -0,0 +1,45 @@
+package alsa
+
+import (
+	"math"
+	"time"
+
+	"github.com/yobert/alsa"
+)
+
+// RecordWavWithMeter records like RecordWav, calling onLevel with the RMS and peak level of every period
+// as a fraction of full scale while it records.
+func RecordWavWithMeter(rec *alsa.Device, duration time.Duration, channels, rate int, onLevel func(rms, peak float64)) (alsa.Buffer, error) {
+	return RecordWavWithOpts(rec, duration, RecordOpts{
+		Config:  AudioConfig{Channels: channels, Rates: []int{rate}},
+		OnLevel: onLevel,
+	})
+}
+
+// meter calls onLevel for every period in data, or once for all of it if periodBytes is 0.
+func meter(format alsa.BufferFormat, data []byte, periodBytes int, onLevel func(rms, peak float64)) {
+	if periodBytes <= 0 {
+		periodBytes = len(data)
+	}
+	for off := 0; off < len(data); off += periodBytes {
+		end := off + periodBytes
+		if end > len(data) {
+			end = len(data)
+		}
+		samples, err := decodeFloats(alsa.Buffer{Format: format, Data: data[off:end]})
+		if err != nil || len(samples) == 0 {
+			return
+		}
+		onLevel(level(samples))
+	}
+}
+
+// level returns the RMS and peak of the samples.
+func level(samples []float64) (rms, peak float64) {
+	var sum float64
+	for _, v := range samples {
+		sum += v * v
+		peak = math.Max(peak, math.Abs(v))
+	}
+	return math.Sqrt(sum / float64(len(samples))), peak
+}
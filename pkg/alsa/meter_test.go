@@ -0,0 +1,55 @@
+package alsa
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yobert/alsa"
+)
+
+// A full scale square wave reads as 1 for both RMS and peak, whatever the sample format.
+func TestMeterFullScaleSquare(t *testing.T) {
+	for _, format := range []alsa.FormatType{alsa.S16_LE, alsa.S32_LE} {
+		bufFormat := alsa.BufferFormat{SampleFormat: format, Rate: 8000, Channels: 2}
+		samples := make([]int, 2*250)
+		for i := range samples {
+			samples[i] = maxSample(format)
+			if i/2/10%2 == 1 {
+				samples[i] = -maxSample(format) - 1
+			}
+		}
+		data, err := encodeSamples(samples, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 100 frame periods, the last one short.
+		var levels [][2]float64
+		meter(bufFormat, data, 100*bytesPerFrame(bufFormat), func(rms, peak float64) {
+			levels = append(levels, [2]float64{rms, peak})
+		})
+		if len(levels) != 3 {
+			t.Fatalf("%v: metered %d periods, want 3", format, len(levels))
+		}
+		for i, l := range levels {
+			if math.Abs(l[0]-1) > 1e-4 || math.Abs(l[1]-1) > 1e-4 {
+				t.Errorf("%v: period %d metered RMS %.5f, peak %.5f, want 1", format, i, l[0], l[1])
+			}
+		}
+	}
+}
+
+func TestMeterSine(t *testing.T) {
+	buf := sineBuffer(t, alsa.S16_LE, 1, 44100, 44100)
+	calls := 0
+	meter(buf.Format, buf.Data, 0, func(rms, peak float64) {
+		calls++
+		// A half scale sine.
+		if math.Abs(peak-0.5) > 1e-3 || math.Abs(rms-0.5/math.Sqrt2) > 1e-3 {
+			t.Errorf("metered RMS %.4f, peak %.4f, want %.4f, 0.5", rms, peak, 0.5/math.Sqrt2)
+		}
+	})
+	if calls != 1 {
+		t.Errorf("metered %d times without periods, want once", calls)
+	}
+}
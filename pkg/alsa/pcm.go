@@ -0,0 +1,103 @@
+package alsa
+
+import (
+	"fmt"
+
+	"github.com/go-audio/audio"
+	"github.com/yobert/alsa"
+)
+
+// BufferToPCM converts a buffer to a go-audio PCMBuffer so it can go through go-audio processing.
+// 16-bit samples go in I16, 24 and 32-bit ones in I32 with SourceBitDepth telling them apart.
+// Buffers with a format that can't be converted give an empty PCMBuffer.
+func BufferToPCM(buf alsa.Buffer) audio.PCMBuffer {
+	pcm := audio.PCMBuffer{
+		Format: &audio.Format{NumChannels: buf.Format.Channels, SampleRate: buf.Format.Rate},
+	}
+	samples, err := decodeSamples(buf)
+	if err != nil {
+		return pcm
+	}
+	pcm.SourceBitDepth = uint8(SampleBits(buf.Format.SampleFormat) / 8)
+	if buf.Format.SampleFormat == alsa.S16_LE {
+		pcm.DataType = audio.DataTypeI16
+		pcm.I16 = make([]int16, len(samples))
+		for i, sample := range samples {
+			pcm.I16[i] = int16(sample)
+		}
+		return pcm
+	}
+	pcm.DataType = audio.DataTypeI32
+	pcm.I32 = make([]int32, len(samples))
+	for i, sample := range samples {
+		pcm.I32[i] = int32(sample)
+	}
+	return pcm
+}
+
+// PCMToBuffer converts a go-audio PCMBuffer back into a buffer of the given format.
+// Integer samples are scaled from the PCMBuffer's bit depth to the format's, float samples are taken as -1..1.
+func PCMToBuffer(pcm audio.PCMBuffer, format alsa.FormatType) (alsa.Buffer, error) {
+	if pcm.Format == nil {
+		return alsa.Buffer{}, fmt.Errorf("PCM buffer has no format")
+	}
+	if _, err := sampleBytes(format); err != nil {
+		return alsa.Buffer{}, err
+	}
+	buf := alsa.Buffer{
+		Format: alsa.BufferFormat{
+			SampleFormat: format,
+			Rate:         pcm.Format.SampleRate,
+			Channels:     pcm.Format.NumChannels,
+		},
+	}
+
+	var floats []float64
+	var samples []int
+	var srcBits int
+	switch pcm.DataType {
+	case audio.DataTypeF32:
+		floats = make([]float64, len(pcm.F32))
+		for i, f := range pcm.F32 {
+			floats[i] = float64(f)
+		}
+	case audio.DataTypeF64:
+		floats = pcm.F64
+	case audio.DataTypeI8:
+		srcBits = 8
+		samples = make([]int, len(pcm.I8))
+		for i, sample := range pcm.I8 {
+			samples[i] = int(sample)
+		}
+	case audio.DataTypeI16:
+		srcBits = 16
+		samples = make([]int, len(pcm.I16))
+		for i, sample := range pcm.I16 {
+			samples[i] = int(sample)
+		}
+	case audio.DataTypeI32:
+		srcBits = 32
+		samples = make([]int, len(pcm.I32))
+		for i, sample := range pcm.I32 {
+			samples[i] = int(sample)
+		}
+	default:
+		return alsa.Buffer{}, fmt.Errorf("unknown PCM data type %v", pcm.DataType)
+	}
+
+	var err error
+	if floats != nil {
+		buf.Data, err = encodeFloats(floats, format)
+		return buf, err
+	}
+
+	// 24-bit audio is stored in the 32-bit slice.
+	if pcm.SourceBitDepth > 0 {
+		srcBits = 8 * int(pcm.SourceBitDepth)
+	}
+	for i, sample := range samples {
+		samples[i] = scaleBits(sample, srcBits, SampleBits(format))
+	}
+	buf.Data, err = encodeSamples(samples, format)
+	return buf, err
+}
@@ -0,0 +1,50 @@
+package alsa
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-audio/audio"
+	"github.com/yobert/alsa"
+)
+
+func TestPCMRoundTrip(t *testing.T) {
+	for _, format := range []alsa.FormatType{alsa.S16_LE, alsa.S24_LE, S24_3LE, alsa.S32_LE} {
+		in := sineBuffer(t, format, 2, 44100, 441)
+		pcm := BufferToPCM(in)
+		if pcm.Format.NumChannels != 2 || pcm.Format.SampleRate != 44100 || pcm.Len() != 882 {
+			t.Errorf("%v: converted to %d samples, %+v", format, pcm.Len(), *pcm.Format)
+		}
+		out, err := PCMToBuffer(pcm, format)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		if out.Format != in.Format || !bytes.Equal(out.Data, in.Data) {
+			t.Errorf("%v: came back as %+v, with different data", format, out.Format)
+		}
+	}
+}
+
+// Converting to another format scales the samples to its bit depth.
+func TestPCMToBufferScales(t *testing.T) {
+	pcm := audio.PCMBuffer{
+		Format:   &audio.Format{NumChannels: 1, SampleRate: 8000},
+		DataType: audio.DataTypeI16,
+		I16:      []int16{0, 16384, -32768},
+	}
+	out, err := PCMToBuffer(pcm, alsa.S32_LE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples, err := decodeSamples(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 || samples[0] != 0 || samples[1] != 1<<30 || samples[2] != -1<<31 {
+		t.Errorf("16-bit samples came out as %v in 32 bits", samples)
+	}
+
+	if _, err := PCMToBuffer(audio.PCMBuffer{DataType: audio.DataTypeI16}, alsa.S16_LE); err == nil {
+		t.Error("converted a PCM buffer without a format")
+	}
+}
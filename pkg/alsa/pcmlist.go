@@ -0,0 +1,89 @@
+package alsa
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/yobert/alsa"
+)
+
+// Direction picks capture or playback devices.
+type Direction int
+
+const (
+	Capture Direction = iota
+	Playback
+)
+
+func (d Direction) String() string {
+	if d == Capture {
+		return "capture"
+	}
+	return "playback"
+}
+
+// PCMDeviceInfo describes a PCM device the way arecord -l and aplay -l list it.
+type PCMDeviceInfo struct {
+	CardNumber   int
+	CardTitle    string
+	DeviceNumber int
+	DeviceTitle  string
+	Direction    Direction
+}
+
+// Address is the "hw:card,device,p" or "hw:card,device,c" address OpenDevice takes for the device,
+// so it opens the side of the device it was listed for.
+func (p PCMDeviceInfo) Address() string {
+	side := "p"
+	if p.Direction == Capture {
+		side = "c"
+	}
+	return fmt.Sprintf("hw:%d,%d,%s", p.CardNumber, p.DeviceNumber, side)
+}
+
+func (p PCMDeviceInfo) String() string {
+	return fmt.Sprintf("card %d: %s, device %d: %s", p.CardNumber, p.CardTitle, p.DeviceNumber, p.DeviceTitle)
+}
+
+// ListPCMDevices lists every capture or playback PCM device on every card, ordered by card and device number.
+func ListPCMDevices(direction Direction) ([]PCMDeviceInfo, error) {
+	cards, err := alsa.OpenCards()
+	if err != nil {
+		return nil, err
+	}
+	defer alsa.CloseCards(cards)
+	return listPCMDevices(cards, (*alsa.Card).Devices, direction)
+}
+
+func listPCMDevices(cards []*alsa.Card, devicesOf func(*alsa.Card) ([]*alsa.Device, error), direction Direction) ([]PCMDeviceInfo, error) {
+	var list []PCMDeviceInfo
+	for _, card := range cards {
+		devices, err := devicesOf(card)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get the devices of card %d", card.Number)
+		}
+		for _, device := range devices {
+			if device.Type != alsa.PCM {
+				continue
+			}
+			if (direction == Capture && !device.Record) || (direction == Playback && !device.Play) {
+				continue
+			}
+			list = append(list, PCMDeviceInfo{
+				CardNumber:   card.Number,
+				CardTitle:    card.Title,
+				DeviceNumber: device.Number,
+				DeviceTitle:  device.Title,
+				Direction:    direction,
+			})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CardNumber != list[j].CardNumber {
+			return list[i].CardNumber < list[j].CardNumber
+		}
+		return list[i].DeviceNumber < list[j].DeviceNumber
+	})
+	return list, nil
+}
@@ -0,0 +1,54 @@
+package alsa
+
+import (
+	"testing"
+
+	"github.com/yobert/alsa"
+)
+
+func TestListPCMDevicesDirection(t *testing.T) {
+	cards := []*alsa.Card{{Number: 1, Title: "USB"}, {Number: 0, Title: "HDA"}}
+	devicesOf := func(card *alsa.Card) ([]*alsa.Device, error) {
+		if card.Number == 0 {
+			return []*alsa.Device{
+				{Type: alsa.PCM, Number: 3, Play: true, Title: "HDMI"},
+				{Type: alsa.PCM, Number: 0, Play: true, Title: "Analog"},
+				{Type: alsa.PCM, Number: 0, Record: true, Title: "Analog"},
+				{Type: alsa.UnknownDeviceType, Number: 1, Play: true, Title: "Other"},
+			}, nil
+		}
+		return []*alsa.Device{{Type: alsa.PCM, Number: 0, Record: true, Title: "Mic"}}, nil
+	}
+	tests := []struct {
+		direction Direction
+		want      []string
+	}{
+		{Playback, []string{"hw:0,0,p", "hw:0,3,p"}},
+		{Capture, []string{"hw:0,0,c", "hw:1,0,c"}},
+	}
+	for _, tt := range tests {
+		list, err := listPCMDevices(cards, devicesOf, tt.direction)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, device := range list {
+			if device.Direction != tt.direction {
+				t.Errorf("%v: listed %v as %v", tt.direction, device, device.Direction)
+			}
+			got = append(got, device.Address())
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("%v: listed %v, want %v", tt.direction, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%v: listed %v, want %v", tt.direction, got, tt.want)
+				break
+			}
+			if _, err := parseAddress(got[i]); err != nil {
+				t.Errorf("OpenDevice can't take %q: %v", got[i], err)
+			}
+		}
+	}
+}
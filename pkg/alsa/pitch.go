@@ -0,0 +1,123 @@
+package alsa
+
+import (
+	"math"
+
+	"github.com/yobert/alsa"
+)
+
+// Pitches outside this range aren't looked for.
+const (
+	minPitch = 40.0
+	maxPitch = 4000.0
+)
+
+// At most this many frames are analysed, which is plenty for a sustained note.
+const pitchWindow = 8192
+
+// DetectPitch estimates the fundamental frequency of the recording, mixed down to mono.
+// It uses the normalized square difference function (McLeod's method): the autocorrelation
+// of the signal with itself at every lag, normalized so a perfectly periodic signal scores 1.
+// The confidence is that score, so noise or several notes at once give a low confidence
+// instead of a misleading frequency. A recording that can't be analysed returns 0, 0.
+func DetectPitch(recording alsa.Buffer) (hz float64, confidence float64) {
+	rate := recording.Format.Rate
+	channels := recording.Format.Channels
+	if rate < 1 || channels < 1 {
+		return 0, 0
+	}
+	samples, err := decodeFloats(recording)
+	if err != nil {
+		return 0, 0
+	}
+	x := mixDown(samples, channels)
+
+	// Use the middle of long recordings, the attack of a note is rarely steady.
+	if len(x) > pitchWindow {
+		start := (len(x) - pitchWindow) / 2
+		x = x[start : start+pitchWindow]
+	}
+
+	var mean float64
+	for _, v := range x {
+		mean += v
+	}
+	mean /= float64(len(x))
+	for i := range x {
+		x[i] -= mean
+	}
+
+	minLag := int(float64(rate) / maxPitch)
+	if minLag < 1 {
+		minLag = 1
+	}
+	maxLag := int(float64(rate) / minPitch)
+	if maxLag > len(x)/2 {
+		maxLag = len(x) / 2
+	}
+	if maxLag <= minLag+1 {
+		return 0, 0
+	}
+
+	nsdf := make([]float64, maxLag+2)
+	for lag := 0; lag < len(nsdf); lag++ {
+		var acf, energy float64
+		for i := 0; i+lag < len(x); i++ {
+			acf += x[i] * x[i+lag]
+			energy += x[i]*x[i] + x[i+lag]*x[i+lag]
+		}
+		if energy > 0 {
+			nsdf[lag] = 2 * acf / energy
+		}
+	}
+
+	// Collect the highest point between each positive-going and negative-going zero crossing,
+	// skipping the peak at lag 0.
+	var peaks []int
+	lag := 1
+	for lag < len(nsdf) && nsdf[lag] > 0 {
+		lag++
+	}
+	for lag < len(nsdf)-1 {
+		for lag < len(nsdf)-1 && nsdf[lag] <= 0 {
+			lag++
+		}
+		best := lag
+		for lag < len(nsdf)-1 && nsdf[lag] > 0 {
+			if nsdf[lag] > nsdf[best] {
+				best = lag
+			}
+			lag++
+		}
+		if best >= minLag && best <= maxLag && nsdf[best] > 0 {
+			peaks = append(peaks, best)
+		}
+	}
+	if len(peaks) == 0 {
+		return 0, 0
+	}
+
+	// The first peak close to the highest one is the fundamental,
+	// later ones are multiples of its period.
+	highest := 0.0
+	for _, p := range peaks {
+		highest = math.Max(highest, nsdf[p])
+	}
+	chosen := peaks[0]
+	for _, p := range peaks {
+		if nsdf[p] >= 0.9*highest {
+			chosen = p
+			break
+		}
+	}
+
+	// Parabolic interpolation around the peak for sub-sample accuracy.
+	period := float64(chosen)
+	a, b, c := nsdf[chosen-1], nsdf[chosen], nsdf[chosen+1]
+	if denom := a - 2*b + c; denom != 0 {
+		period += 0.5 * (a - c) / denom
+	}
+
+	confidence = math.Min(math.Max(b, 0), 1)
+	return float64(rate) / period, confidence
+}
@@ -0,0 +1,41 @@
+package alsa
+
+import (
+	"fmt"
+
+	"github.com/yobert/alsa"
+)
+
+// Devices are always opened for interleaved access: yobert/alsa sets RW_INTERLEAVED when it opens
+// a device and has no way to ask for non-interleaved (planar) access, so every buffer read or written
+// holds frames of one sample per channel. These helpers convert to and from a buffer per channel
+// for processing that works on one channel at a time.
+
+// Deinterleave splits a recording into a mono buffer per channel.
+func Deinterleave(buf alsa.Buffer) ([]alsa.Buffer, error) {
+	size, err := sampleBytes(buf.Format.SampleFormat)
+	if err != nil {
+		return nil, err
+	}
+	channels := buf.Format.Channels
+	if channels < 1 {
+		return nil, fmt.Errorf("invalid channel count %d", channels)
+	}
+	frames := len(buf.Data) / bytesPerFrame(buf.Format)
+	planes := make([]alsa.Buffer, channels)
+	for c := range planes {
+		planes[c].Format = buf.Format
+		planes[c].Format.Channels = 1
+		planes[c].Data = make([]byte, frames*size)
+		for f := 0; f < frames; f++ {
+			copy(planes[c].Data[f*size:(f+1)*size], buf.Data[(f*channels+c)*size:])
+		}
+	}
+	return planes, nil
+}
+
+// Interleave is the inverse of Deinterleave. The planes must share a format and rate;
+// if their lengths differ the result is as long as the shortest.
+func Interleave(planes []alsa.Buffer) (alsa.Buffer, error) {
+	return interleaveChannels(planes)
+}
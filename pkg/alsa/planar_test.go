@@ -0,0 +1,45 @@
+package alsa
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yobert/alsa"
+)
+
+func TestDeinterleaveRoundTrip(t *testing.T) {
+	for _, format := range []alsa.FormatType{alsa.S16_LE, S24_3LE, alsa.S32_LE} {
+		in := sineBuffer(t, format, 3, 8000, 100)
+		planes, err := Deinterleave(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(planes) != 3 {
+			t.Fatalf("%v: split into %d planes", format, len(planes))
+		}
+		size := SampleSize(format)
+		for c, plane := range planes {
+			if plane.Format.Channels != 1 || len(plane.Data) != 100*size {
+				t.Fatalf("%v: plane %d is %d channels, %d bytes", format, c, plane.Format.Channels, len(plane.Data))
+			}
+			// Frame 10 of the plane is sample c of frame 10.
+			if !bytes.Equal(plane.Data[10*size:11*size], in.Data[(10*3+c)*size:(10*3+c+1)*size]) {
+				t.Errorf("%v: plane %d doesn't hold channel %d", format, c, c)
+			}
+		}
+		out, err := Interleave(planes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Format != in.Format || !bytes.Equal(out.Data, in.Data) {
+			t.Errorf("%v: came back as %+v, with different data", format, out.Format)
+		}
+	}
+}
+
+func TestDeinterleaveNoChannels(t *testing.T) {
+	buf := alsa.Buffer{Format: alsa.BufferFormat{SampleFormat: alsa.S16_LE, Rate: 8000}, Data: make([]byte, 8)}
+	if _, err := Deinterleave(buf); err == nil {
+		t.Error("split a buffer with 0 channels")
+	}
+}
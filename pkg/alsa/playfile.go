@@ -0,0 +1,71 @@
+package alsa
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/yobert/alsa"
+)
+
+// PlayFile plays an audio file of any format the package can decode.
+// The format is picked from the extension, or from the start of the file when the extension isn't known.
+// Only WAV can be decoded for now; MP3 and AIFF files are recognized and rejected with an *UnsupportedFormat.
+func PlayFile(device *alsa.Device, path string) error {
+	format := formatFromExtension(path)
+	if format == "" {
+		var err error
+		if format, err = sniffFormat(path); err != nil {
+			return err
+		}
+	}
+	switch format {
+	case "WAV":
+		return PlayWav(device, path)
+	}
+	return &UnsupportedFormat{Path: path, Format: format}
+}
+
+func formatFromExtension(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav", ".wave":
+		return "WAV"
+	case ".mp3":
+		return "MP3"
+	case ".aif", ".aiff", ".aifc":
+		return "AIFF"
+	}
+	return ""
+}
+
+// sniffFormat recognizes a file by its magic number.
+func sniffFormat(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to open %q", path)
+	}
+	defer f.Close()
+
+	magic := make([]byte, 12)
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", errors.Wrapf(err, "failed to read %q", path)
+	}
+	magic = magic[:n]
+
+	switch {
+	case len(magic) == 12 && string(magic[0:4]) == "RIFF" && string(magic[8:12]) == "WAVE":
+		return "WAV", nil
+	case len(magic) == 12 && string(magic[0:4]) == "FORM" && (string(magic[8:12]) == "AIFF" || string(magic[8:12]) == "AIFC"):
+		return "AIFF", nil
+	case bytes.HasPrefix(magic, []byte("ID3")):
+		return "MP3", nil
+	case len(magic) >= 2 && magic[0] == 0xFF && magic[1]&0xE0 == 0xE0:
+		// MPEG audio frame sync, an MP3 without ID3 tags.
+		return "MP3", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,204 @@
+package alsa
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/yobert/alsa"
+
+	"github.com/renan-campos/sound-utils/pkg/logging"
+)
+
+// NegotiatedParams are the parameters the device agreed to.
+type NegotiatedParams struct {
+	Channels int
+	Rate     int
+	Format   alsa.FormatType
+	// PeriodSize and BufferSize are in frames.
+	// PeriodSize is 0 when the period was left up to the device.
+	PeriodSize int
+	BufferSize int
+}
+
+// deviceDefaults fill in whatever an AudioConfig leaves at its zero value.
+type deviceDefaults struct {
+	channels     []int
+	rates        []int
+	formats      []alsa.FormatType
+	periodFrames int   // 0 leaves the period size up to the device
+	bufferFrames []int // nil asks for two periods
+}
+
+// A 50ms period is a sensible value to test low-ish latency.
+// We adjust the buffer so it's of minimal size (period * 2) since it appear ALSA won't
+// start playback until the buffer has been filled to a certain degree and the automatic
+// buffer size can be quite large.
+// Some devices only accept even periods while others want powers of 2.
+const defaultPeriodFrames = 2048 // 46ms @ 44100Hz
+
+var genericDefaults = deviceDefaults{
+	channels: []int{2, 1},
+	rates:    []int{44100},
+	formats:  []alsa.FormatType{alsa.S16_LE, alsa.S32_LE, S24_3LE},
+}
+
+// PrepareDevice opens the device, negotiates the parameters described by cfg and prepares it,
+// leaving it ready for Read or Write. The caller is responsible for closing it.
+// If negotiation fails the device is closed again.
+// Fields of cfg left at their zero value ask for stereo (or mono), 44100 Hz and S16_LE (or S32_LE, or S24_3LE).
+// The period and buffer sizes are left up to the device unless cfg sets them.
+func PrepareDevice(dev *alsa.Device, cfg AudioConfig) (NegotiatedParams, error) {
+	return prepareDevice(dev, cfg, genericDefaults)
+}
+
+// negotiator is the part of a device prepareDevice needs.
+type negotiator interface {
+	pairDevice
+	NegotiateFormat(formats ...alsa.FormatType) (alsa.FormatType, error)
+	NegotiatePeriodSize(sizes ...int) (int, error)
+	NegotiateBufferSize(sizes ...int) (int, error)
+	Prepare() error
+}
+
+func prepareDevice(dev negotiator, cfg AudioConfig, defaults deviceDefaults) (NegotiatedParams, error) {
+	if err := dev.Open(); err != nil {
+		return NegotiatedParams{}, err
+	}
+	params, err := negotiate(dev, cfg, defaults)
+	if err != nil {
+		dev.Close()
+		return NegotiatedParams{}, err
+	}
+	return params, nil
+}
+
+func negotiate(dev negotiator, cfg AudioConfig, defaults deviceDefaults) (NegotiatedParams, error) {
+	var params NegotiatedParams
+	var err error
+
+	channels, rates := cfg.channelChoices(defaults.channels...), cfg.rateChoices(defaults.rates...)
+	params.Channels, params.Rate, err = negotiatePair(dev, channels, rates)
+	if err != nil {
+		return params, err
+	}
+	// Only what the caller asked for is worth a warning, the defaults are just preferences.
+	if cfg.Channels > 0 {
+		warnMismatch("channels", channels, params.Channels)
+	}
+	if len(cfg.Rates) > 0 {
+		warnMismatch("rate", rates, params.Rate)
+	}
+
+	formats := cfg.formatChoices(defaults.formats...)
+	params.Format, err = dev.NegotiateFormat(formats...)
+	if err != nil {
+		for _, f := range formats {
+			if f == S24_3LE {
+				return params, errors.Wrap(err, "S24_3LE can't be negotiated through yobert/alsa, try S32_LE")
+			}
+		}
+		return params, err
+	}
+	if len(cfg.Formats) > 0 && formats[0] != params.Format {
+		logging.Warnf("asked for format %v, the device negotiated %v", formats[0], params.Format)
+	}
+	// Fail now rather than on the first sample after the device is set up.
+	if _, err := sampleBytes(params.Format); err != nil {
+		return params, errors.Wrap(err, "negotiated a sample format that can't be converted")
+	}
+
+	if want := cfg.periodFrames(params.Rate, defaults.periodFrames); want > 0 {
+		params.PeriodSize, err = dev.NegotiatePeriodSize(want)
+		if err != nil {
+			return params, err
+		}
+		if cfg.PeriodDuration > 0 {
+			warnMismatch("period size", []int{want}, params.PeriodSize)
+		}
+	}
+
+	bufferFrames := defaults.bufferFrames
+	if bufferFrames == nil && params.PeriodSize > 0 {
+		bufferFrames = []int{2 * params.PeriodSize}
+	}
+	if wants := cfg.bufferFrames(params.Rate, bufferFrames...); len(wants) > 0 {
+		params.BufferSize, err = dev.NegotiateBufferSize(wants...)
+		if err != nil {
+			return params, err
+		}
+		if cfg.BufferFrames > 0 || cfg.BufferDuration > 0 {
+			warnMismatch("buffer size", wants, params.BufferSize)
+		}
+	}
+
+	if err = dev.Prepare(); err != nil {
+		return params, err
+	}
+	return params, nil
+}
+
+// warnMismatch warns when a parameter didn't come out as the first choice asked for.
+func warnMismatch(what string, choices []int, got int) {
+	if len(choices) > 0 && choices[0] != got {
+		logging.Warnf("asked for %s %d, the device negotiated %d", what, choices[0], got)
+	}
+}
+
+// pairDevice is the part of a device negotiatePair needs.
+type pairDevice interface {
+	Open() error
+	Close()
+	NegotiateChannels(channels ...int) (int, error)
+	NegotiateRate(rates ...int) (int, error)
+}
+
+// negotiatePair settles the channel count and rate together. Some devices only offer certain rates
+// at certain channel counts (48kHz in stereo but 96kHz with 8 channels), so committing to a channel
+// count first can leave no valid rate. Every channel count is tried with every rate, in order of
+// preference, and the first pair the device accepts wins.
+// ALSA can't widen parameters that were already narrowed, so the device is reopened after a failed pair.
+// When the device takes none of the channel counts, whatever the rate, the error is an *unsupportedChannels.
+func negotiatePair(dev pairDevice, channels, rates []int) (int, int, error) {
+	var tried []string
+	var lastErr error
+	var channelsTaken bool
+	for _, c := range channels {
+		for _, r := range rates {
+			if lastErr != nil {
+				dev.Close()
+				if err := dev.Open(); err != nil {
+					return 0, 0, errors.Wrap(err, "failed to reopen device between negotiation attempts")
+				}
+			}
+			if _, lastErr = dev.NegotiateChannels(c); lastErr == nil {
+				channelsTaken = true
+				if _, lastErr = dev.NegotiateRate(r); lastErr == nil {
+					return c, r, nil
+				}
+			}
+			tried = append(tried, fmt.Sprintf("%d channels @ %d Hz", c, r))
+		}
+	}
+	if lastErr == nil {
+		return 0, 0, fmt.Errorf("no channel counts or rates to negotiate")
+	}
+	err := errors.Wrapf(lastErr, "device accepts none of %s", strings.Join(tried, ", "))
+	if !channelsTaken {
+		return 0, 0, &unsupportedChannels{err}
+	}
+	return 0, 0, err
+}
+
+// unsupportedChannels is a negotiation that failed on the channel count.
+type unsupportedChannels struct {
+	err error
+}
+
+func (u *unsupportedChannels) Error() string {
+	return u.err.Error()
+}
+
+func (u *unsupportedChannels) Unwrap() error {
+	return u.err
+}
@@ -0,0 +1,270 @@
+package alsa
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yobert/alsa"
+
+	"github.com/renan-campos/sound-utils/pkg/logging"
+)
+
+// fakeNegotiator accepts the first of the values it's offered that it supports, like yobert/alsa does,
+// and records every call.
+type fakeNegotiator struct {
+	channels []int
+	rates    []int
+	formats  []alsa.FormatType
+	period   int // Every period size is rounded to this, 0 takes them as asked.
+	buffer   int // Every buffer size is rounded to this, 0 takes them as asked.
+	calls    []string
+	open     bool
+}
+
+func (f *fakeNegotiator) record(format string, a ...interface{}) {
+	f.calls = append(f.calls, fmt.Sprintf(format, a...))
+}
+
+func (f *fakeNegotiator) Open() error {
+	f.record("Open")
+	f.open = true
+	return nil
+}
+
+func (f *fakeNegotiator) Close() {
+	f.record("Close")
+	f.open = false
+}
+
+func firstSupported(offered, supported []int) (int, error) {
+	for _, o := range offered {
+		for _, s := range supported {
+			if o == s {
+				return o, nil
+			}
+		}
+	}
+	return 0, errors.New("not supported")
+}
+
+func (f *fakeNegotiator) NegotiateChannels(channels ...int) (int, error) {
+	f.record("NegotiateChannels%v", channels)
+	return firstSupported(channels, f.channels)
+}
+
+func (f *fakeNegotiator) NegotiateRate(rates ...int) (int, error) {
+	f.record("NegotiateRate%v", rates)
+	return firstSupported(rates, f.rates)
+}
+
+func (f *fakeNegotiator) NegotiateFormat(formats ...alsa.FormatType) (alsa.FormatType, error) {
+	f.record("NegotiateFormat%v", formats)
+	for _, o := range formats {
+		for _, s := range f.formats {
+			if o == s {
+				return o, nil
+			}
+		}
+	}
+	return alsa.Unknown, errors.New("format not supported")
+}
+
+func (f *fakeNegotiator) NegotiatePeriodSize(sizes ...int) (int, error) {
+	f.record("NegotiatePeriodSize%v", sizes)
+	if f.period > 0 {
+		return f.period, nil
+	}
+	return sizes[0], nil
+}
+
+func (f *fakeNegotiator) NegotiateBufferSize(sizes ...int) (int, error) {
+	f.record("NegotiateBufferSize%v", sizes)
+	if f.buffer > 0 {
+		return f.buffer, nil
+	}
+	return sizes[0], nil
+}
+
+func (f *fakeNegotiator) Prepare() error {
+	f.record("Prepare")
+	return nil
+}
+
+func stereoDevice() *fakeNegotiator {
+	return &fakeNegotiator{
+		channels: []int{1, 2},
+		rates:    []int{44100, 48000},
+		formats:  []alsa.FormatType{alsa.S16_LE, alsa.S32_LE},
+	}
+}
+
+// A buffer in frames goes to the device as it is, and nothing asks for a period the caller didn't.
+func TestPrepareDeviceBufferFrames(t *testing.T) {
+	dev := stereoDevice()
+	params, err := prepareDevice(dev, AudioConfig{
+		Channels:     2,
+		Rates:        []int{48000},
+		Formats:      []alsa.FormatType{alsa.S16_LE},
+		BufferFrames: 1000,
+	}, genericDefaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"Open",
+		"NegotiateChannels[2]",
+		"NegotiateRate[48000]",
+		"NegotiateFormat[S16_LE]",
+		"NegotiateBufferSize[1000]",
+		"Prepare",
+	}
+	if !reflect.DeepEqual(dev.calls, want) {
+		t.Errorf("negotiated\n%v\nwant\n%v", dev.calls, want)
+	}
+	if params.PeriodSize != 0 || params.BufferSize != 1000 {
+		t.Errorf("got a %d frame period and %d frame buffer, want no period and 1000 frames", params.PeriodSize, params.BufferSize)
+	}
+}
+
+func TestPlayWaveDefaultsPreferMono(t *testing.T) {
+	dev := stereoDevice()
+	params, err := prepareDevice(dev, AudioConfig{}, beepDefaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NegotiatedParams{Channels: 1, Rate: 44100, Format: alsa.S16_LE, PeriodSize: 2048, BufferSize: 4096}
+	if params != want {
+		t.Errorf("negotiated %+v, want %+v", params, want)
+	}
+}
+
+func TestPrepareDeviceClosesOnError(t *testing.T) {
+	dev := stereoDevice()
+	dev.formats = []alsa.FormatType{alsa.FLOAT_LE}
+	if _, err := prepareDevice(dev, AudioConfig{}, genericDefaults); err == nil {
+		t.Fatal("negotiation succeeded without a common format")
+	}
+	if dev.open {
+		t.Error("the device was left open after negotiation failed")
+	}
+}
+
+// A device that only does packed 24-bit gets it from the default preferences.
+func TestPrepareDeviceS24_3LE(t *testing.T) {
+	dev := stereoDevice()
+	dev.formats = []alsa.FormatType{S24_3LE}
+	params, err := prepareDevice(dev, AudioConfig{}, genericDefaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Format != S24_3LE {
+		t.Errorf("negotiated %v, want S24_3LE", params.Format)
+	}
+	if formats := playbackFormats(24, false); formats[1] != S24_3LE {
+		t.Errorf("24-bit playback tries %v", formats)
+	}
+}
+
+// yobert/alsa can't set S24_3LE, so asking for it fails with a hint.
+func TestPrepareDeviceS24_3LEUnsupported(t *testing.T) {
+	dev := stereoDevice()
+	dev.formats = nil
+	_, err := prepareDevice(dev, AudioConfig{}, genericDefaults)
+	if err == nil || !strings.Contains(err.Error(), "S24_3LE can't be negotiated") {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestChannelFallbackNearest(t *testing.T) {
+	dev := stereoDevice()
+	dev.channels = []int{1, 6}
+	params, err := prepareRecording(dev, RecordOpts{
+		Config:          AudioConfig{Channels: 5, Rates: []int{48000}},
+		ChannelFallback: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Channels != 6 {
+		t.Errorf("fell back to %d channels, want the 6 closest to 5", params.Channels)
+	}
+	if got, want := fallbackChannels(3), []int{2, 4, 1, 6, 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fallback order for 3 channels is %v, want %v", got, want)
+	}
+}
+
+// A device that takes the channel count but not the rate doesn't get another channel count.
+func TestChannelFallbackOnlyForChannels(t *testing.T) {
+	dev := stereoDevice()
+	_, err := prepareRecording(dev, RecordOpts{
+		Config:          AudioConfig{Channels: 2, Rates: []int{96000}},
+		ChannelFallback: true,
+	})
+	if err == nil {
+		t.Fatal("recorded at a rate the device doesn't have")
+	}
+	for _, call := range dev.calls {
+		if strings.HasPrefix(call, "NegotiateChannels") && call != "NegotiateChannels[2]" {
+			t.Errorf("fell back with %s after a rate failure", call)
+		}
+	}
+}
+
+// captureWarnings collects what's logged as a warning while the test runs.
+func captureWarnings(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var warnings bytes.Buffer
+	logging.SetErrorOutput(&warnings)
+	t.Cleanup(func() {
+		logging.SetErrorOutput(os.Stderr)
+	})
+	return &warnings
+}
+
+// Falling back on the defaults is no reason to warn, missing what the caller asked for is.
+func TestNegotiationWarnings(t *testing.T) {
+	s16Only := func() *fakeNegotiator {
+		dev := stereoDevice()
+		dev.formats = []alsa.FormatType{alsa.S16_LE}
+		dev.buffer = 4000
+		return dev
+	}
+
+	warnings := captureWarnings(t)
+	if _, err := prepareDevice(s16Only(), AudioConfig{}, deviceDefaults{
+		channels:     []int{6, 2},
+		rates:        []int{48000},
+		formats:      []alsa.FormatType{alsa.S32_LE, alsa.S16_LE},
+		periodFrames: 2048,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if warnings.Len() > 0 {
+		t.Errorf("warned about the defaults:\n%s", warnings)
+	}
+
+	if _, err := prepareDevice(s16Only(), AudioConfig{
+		Rates:        []int{96000, 48000},
+		Formats:      []alsa.FormatType{alsa.S32_LE, alsa.S16_LE},
+		BufferFrames: 4096,
+	}, genericDefaults); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"asked for rate 96000, the device negotiated 48000",
+		"asked for format S32_LE, the device negotiated S16_LE",
+		"asked for buffer size 4096, the device negotiated 4000",
+	} {
+		if !strings.Contains(warnings.String(), want) {
+			t.Errorf("no warning %q in:\n%s", want, warnings)
+		}
+	}
+	if strings.Contains(warnings.String(), "channels") {
+		t.Errorf("warned about the default channels:\n%s", warnings)
+	}
+}
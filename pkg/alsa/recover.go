@@ -0,0 +1,144 @@
+package alsa
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// When a recording crashes the wav encoder never gets to go back and write the real sizes
+// into the header, so the RIFF and data sizes are left as placeholders.
+// The audio itself is fine though: it's everything after the data chunk header up to the end of the file.
+type fragment struct {
+	name       string
+	format     wavFmt
+	hasFormat  bool
+	dataOffset int64
+	dataSize   int64
+}
+
+func readFragment(name string) (fragment, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return fragment{}, errors.Wrapf(err, "failed to open %q", name)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fragment{}, errors.Wrapf(err, "failed to stat %q", name)
+	}
+
+	frag := fragment{name: name}
+
+	// The walk may stop with an error once it runs into the garbage left by the bogus data size.
+	// That's expected, all we need are the chunks before it.
+	chunks, _ := walkChunks(f)
+	if c, ok := findChunk(chunks, "fmt "); ok {
+		if frag.format, err = readFmtChunk(f, c); err == nil {
+			frag.hasFormat = true
+		}
+	}
+	if c, ok := findChunk(chunks, "data"); ok {
+		frag.dataOffset = c.offset
+	} else {
+		// No usable header at all, assume the canonical 44 byte one.
+		frag.dataOffset = 44
+	}
+
+	frag.dataSize = info.Size() - frag.dataOffset
+	if frag.dataSize < 0 {
+		frag.dataSize = 0
+	}
+	return frag, nil
+}
+
+// RecoverRecording joins the audio of WAV fragments left behind by a crashed recording into one valid WAV file.
+// The header sizes of the fragments are ignored; the amount of audio is computed from the file size.
+// Fragments are joined in the order given and must all have the same format.
+func RecoverRecording(fragments []string, out string) error {
+	if len(fragments) == 0 {
+		return fmt.Errorf("no fragments to recover")
+	}
+
+	frags := make([]fragment, len(fragments))
+	var format wavFmt
+	var hasFormat bool
+	for i, name := range fragments {
+		frag, err := readFragment(name)
+		if err != nil {
+			return err
+		}
+		if frag.hasFormat {
+			if !hasFormat {
+				format = frag.format
+				hasFormat = true
+			} else if frag.format != format {
+				return fmt.Errorf("%q has a different format than the previous fragments", name)
+			}
+		}
+		frags[i] = frag
+	}
+	if !hasFormat {
+		return fmt.Errorf("none of the fragments have a readable fmt chunk")
+	}
+	if format.blockAlign == 0 {
+		return fmt.Errorf("fmt chunk has a block align of 0")
+	}
+
+	of, err := CreateFile(out, false)
+	if err != nil {
+		return err
+	}
+	defer of.Close()
+
+	// Placeholder header, rewritten once the total size is known.
+	if err := writeWavHeader(of, format, 0); err != nil {
+		return err
+	}
+
+	var total int64
+	for _, frag := range frags {
+		// A crash can leave a partial frame at the end.
+		size := frag.dataSize - frag.dataSize%int64(format.blockAlign)
+		if err := copyRange(of, frag.name, frag.dataOffset, size); err != nil {
+			return err
+		}
+		total += size
+	}
+	if total > 0xffffffff-36 {
+		return fmt.Errorf("recovered audio is too large for a WAV file (%d bytes)", total)
+	}
+	if total%2 == 1 {
+		if _, err := of.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+
+	if _, err := of.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := writeWavHeader(of, format, uint32(total)); err != nil {
+		return err
+	}
+	fmt.Printf("Recovered %d bytes of audio from %d fragments to %s\n", total, len(frags), out)
+	return nil
+}
+
+func copyRange(w io.Writer, name string, offset, size int64) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %q", name)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(w, f, size); err != nil {
+		return errors.Wrapf(err, "failed to copy audio from %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package alsa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+)
+
+// crashed is what a crashed recording leaves: placeholder sizes and maybe a partial frame at the end.
+func crashed(format testChunk, audio []byte) []byte {
+	data := wavBytes(format, testChunk{"data", nil})
+	binary.LittleEndian.PutUint32(data[4:], 0)
+	return append(data, audio...)
+}
+
+func TestRecoverRecording(t *testing.T) {
+	format := pcmFmt(2, 44100, 16)
+	first := []byte{1, 0, 2, 0, 3, 0, 4, 0}
+	second := []byte{5, 0, 6, 0, 7, 0, 8, 0}
+	fragments := []string{
+		writeTemp(t, "1.wav", crashed(format, append(first, 9, 9))), // half a frame too many
+		writeTemp(t, "2.wav", crashed(format, second)),
+	}
+	out := filepath.Join(t.TempDir(), "out.wav")
+	if err := RecoverRecording(fragments, out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := wavBytes(format, testChunk{"data", append(append([]byte{}, first...), second...)})
+	if got := readFile(t, out); !bytes.Equal(got, want) {
+		t.Errorf("recovered\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestRecoverRecordingKeepsExtensibleFmt(t *testing.T) {
+	format := extensibleFmt(2, 48000, 24, 0x3)
+	audio := []byte{1, 2, 3, 4, 5, 6}
+	fragment := writeTemp(t, "1.wav", crashed(format, audio))
+	out := filepath.Join(t.TempDir(), "out.wav")
+	if err := RecoverRecording([]string{fragment}, out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := wavBytes(format, testChunk{"data", audio})
+	if got := readFile(t, out); !bytes.Equal(got, want) {
+		t.Errorf("recovered\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestRecoverRecordingFormatMismatch(t *testing.T) {
+	fragments := []string{
+		writeTemp(t, "1.wav", crashed(pcmFmt(2, 44100, 16), []byte{1, 0, 2, 0})),
+		writeTemp(t, "2.wav", crashed(extensibleFmt(2, 44100, 16, 0x3), []byte{1, 0, 2, 0})),
+	}
+	if err := RecoverRecording(fragments, filepath.Join(t.TempDir(), "out.wav")); err == nil {
+		t.Error("fragments with different fmt chunks were joined")
+	}
+}
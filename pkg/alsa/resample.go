@@ -0,0 +1,109 @@
+package alsa
+
+import (
+	"fmt"
+
+	"github.com/yobert/alsa"
+)
+
+// resampleLinear converts interleaved samples from srcRate to dstRate by linear interpolation between neighbouring frames.
+// It's cheap and good enough for speech and monitoring; it doesn't filter, so downsampling can alias.
+func resampleLinear(in []int, srcRate, dstRate, channels int) []int {
+	frames := len(in) / channels
+	if srcRate == dstRate || frames == 0 {
+		return append([]int(nil), in[:frames*channels]...)
+	}
+	outFrames := int((int64(frames)*int64(dstRate) + int64(srcRate)/2) / int64(srcRate))
+	out := make([]int, outFrames*channels)
+	step := float64(srcRate) / float64(dstRate)
+	for f := 0; f < outFrames; f++ {
+		pos := float64(f) * step
+		i := int(pos)
+		frac := pos - float64(i)
+		next := i + 1
+		if next >= frames {
+			next = frames - 1
+		}
+		if i >= frames {
+			i = frames - 1
+		}
+		for c := 0; c < channels; c++ {
+			a, b := float64(in[i*channels+c]), float64(in[next*channels+c])
+			out[f*channels+c] = roundInt(a + (b-a)*frac)
+		}
+	}
+	return out
+}
+
+// streamResampler is resampleLinear for audio that arrives a chunk at a time, as in playback.
+// It carries the last frame and the position between frames over to the next chunk,
+// so the chunks join up without clicks or drifting out of time.
+type streamResampler struct {
+	step     float64 // input frames per output frame
+	channels int
+	pos      float64 // position of the next output frame, in frames from the start of prev
+	prev     []int   // last frame of the previous chunk
+}
+
+func newStreamResampler(srcRate, dstRate, channels int) *streamResampler {
+	return &streamResampler{step: float64(srcRate) / float64(dstRate), channels: channels}
+}
+
+func (r *streamResampler) process(in []int) []int {
+	frames := append(append([]int(nil), r.prev...), in[:len(in)/r.channels*r.channels]...)
+	n := len(frames) / r.channels
+	if n == 0 {
+		return nil
+	}
+	var out []int
+	for ; r.pos < float64(n-1); r.pos += r.step {
+		i := int(r.pos)
+		frac := r.pos - float64(i)
+		for c := 0; c < r.channels; c++ {
+			a, b := float64(frames[i*r.channels+c]), float64(frames[(i+1)*r.channels+c])
+			out = append(out, roundInt(a+(b-a)*frac))
+		}
+	}
+	// The last frame is needed to interpolate towards the next chunk.
+	r.pos -= float64(n - 1)
+	r.prev = append(r.prev[:0], frames[(n-1)*r.channels:]...)
+	return out
+}
+
+// flush returns the frames still due once all the audio has gone through process, which holds back the last frame.
+// They repeat the last frame, so the output is as long as the input at the new rate.
+func (r *streamResampler) flush() []int {
+	var out []int
+	for ; r.pos < 1 && len(r.prev) > 0; r.pos += r.step {
+		out = append(out, r.prev...)
+	}
+	return out
+}
+
+func roundInt(v float64) int {
+	if v < 0 {
+		return int(v - 0.5)
+	}
+	return int(v + 0.5)
+}
+
+// ResampleBuffer returns the recording converted to targetRate, keeping its sample format and channels.
+func ResampleBuffer(buf alsa.Buffer, targetRate int) (alsa.Buffer, error) {
+	if targetRate < 1 || buf.Format.Rate < 1 {
+		return alsa.Buffer{}, fmt.Errorf("can't resample from %d Hz to %d Hz", buf.Format.Rate, targetRate)
+	}
+	if buf.Format.Channels < 1 {
+		return alsa.Buffer{}, fmt.Errorf("invalid channel count %d", buf.Format.Channels)
+	}
+	samples, err := decodeSamples(buf)
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+	data, err := encodeSamples(resampleLinear(samples, buf.Format.Rate, targetRate, buf.Format.Channels), buf.Format.SampleFormat)
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+	format := buf.Format
+	format.Rate = targetRate
+	return alsa.Buffer{Format: format, Data: data}, nil
+}
@@ -0,0 +1,110 @@
+package alsa
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yobert/alsa"
+)
+
+// The resampler's output per chunk varies with where the chunk falls, but it always comes in whole frames
+// and adds up to the length of the input at the new rate.
+func TestStreamResamplerFrameCounts(t *testing.T) {
+	tests := []struct {
+		src, dst int
+	}{
+		{44100, 48000},
+		{48000, 44100},
+		{22050, 44100},
+		{48000, 8000},
+	}
+	const channels, chunkFrames, chunks = 2, 1000, 20
+	for _, test := range tests {
+		r := newStreamResampler(test.src, test.dst, channels)
+		in := make([]int, chunkFrames*channels)
+		total := 0
+		sizes := map[int]bool{}
+		for i := 0; i < chunks; i++ {
+			out := r.process(in)
+			if len(out)%channels != 0 {
+				t.Fatalf("%d to %d Hz: chunk %d came out as %d samples, not whole frames", test.src, test.dst, i, len(out))
+			}
+			total += len(out) / channels
+			sizes[len(out)/channels] = true
+		}
+		total += len(r.flush()) / channels
+
+		want := chunkFrames * chunks * test.dst / test.src
+		if total < want-1 || total > want+1 {
+			t.Errorf("%d to %d Hz: %d frames out, want %d", test.src, test.dst, total, want)
+		}
+		if test.dst%test.src != 0 && len(sizes) < 2 {
+			t.Errorf("%d to %d Hz: every chunk came out the same size, %v", test.src, test.dst, sizes)
+		}
+	}
+}
+
+func TestResampleLinear(t *testing.T) {
+	in := []int{0, 100, 200, 300}
+	if got := resampleLinear(in, 4, 8, 1); len(got) != 8 || got[1] != 50 || got[2] != 100 {
+		t.Errorf("upsampled to %v", got)
+	}
+	if got := resampleLinear(in, 4, 2, 1); len(got) != 2 || got[1] != 200 {
+		t.Errorf("downsampled to %v", got)
+	}
+}
+
+// Interpolating between equal samples gives the same sample, whichever way the rate goes,
+// and the streaming resampler doesn't lose or bend any of it between chunks.
+func TestResampleConstant(t *testing.T) {
+	const v = 1234
+	in := make([]int, 1000)
+	for i := range in {
+		in[i] = v
+	}
+	for _, rates := range [][2]int{{44100, 48000}, {48000, 44100}} {
+		for i, got := range resampleLinear(in, rates[0], rates[1], 2) {
+			if got != v {
+				t.Fatalf("%d to %d Hz: sample %d is %d, want %d", rates[0], rates[1], i, got, v)
+			}
+		}
+		r := newStreamResampler(rates[0], rates[1], 2)
+		var out []int
+		for i := 0; i < 10; i++ {
+			out = append(out, r.process(in)...)
+		}
+		out = append(out, r.flush()...)
+		for i, got := range out {
+			if got != v {
+				t.Fatalf("%d to %d Hz streamed: sample %d is %d, want %d", rates[0], rates[1], i, got, v)
+			}
+		}
+	}
+}
+
+func TestResampleBuffer(t *testing.T) {
+	in := sineBuffer(t, alsa.S16_LE, 2, 22050, 1000)
+
+	same, err := ResampleBuffer(in, 22050)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Format != in.Format || !bytes.Equal(same.Data, in.Data) {
+		t.Errorf("resampling to the same rate changed the buffer: %+v", same.Format)
+	}
+
+	doubled, err := ResampleBuffer(in, 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doubled.Format.Rate != 44100 || doubled.Format.Channels != 2 || doubled.Format.SampleFormat != alsa.S16_LE {
+		t.Errorf("resampled to %+v", doubled.Format)
+	}
+	if got, want := len(doubled.Data), 2*len(in.Data); got != want {
+		t.Errorf("resampled to %d bytes, want %d", got, want)
+	}
+
+	if _, err := ResampleBuffer(in, 0); err == nil {
+		t.Error("resampled to 0 Hz")
+	}
+}
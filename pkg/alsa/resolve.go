@@ -0,0 +1,79 @@
+package alsa
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-audio/audio"
+)
+
+// Capabilities are the sample rates and bit depths a device accepts.
+// An empty list means anything goes.
+type Capabilities struct {
+	Rates []int
+	Bits  []int
+}
+
+// ResolvePlaybackFormat picks the rate and bit depth to play a source at on a device with the given capabilities,
+// losing as little as possible, and lists the conversions that will take place (none means bit-perfect).
+//
+// The source's own rate and depth are used when the device has them. Otherwise going up is preferred to going down,
+// since it doesn't throw anything away: the closest rate that's a whole multiple of the source rate,
+// then the closest higher rate, and the closest higher bit depth. Only when the device has nothing higher
+// does it fall back to the closest lower one, and those conversions are marked lossy.
+func ResolvePlaybackFormat(src audio.Format, srcBits int, caps Capabilities) (targetRate, targetBits int, conversions []string) {
+	targetRate, rateLossy := resolveRate(src.SampleRate, caps.Rates)
+	if targetRate != src.SampleRate {
+		conversions = append(conversions, conversion(
+			fmt.Sprintf("resample %d Hz to %d Hz", src.SampleRate, targetRate), rateLossy))
+	}
+	targetBits, bitsLossy := resolveAbove(srcBits, caps.Bits, nil)
+	if targetBits != srcBits {
+		verb := "pad"
+		if bitsLossy {
+			verb = "truncate"
+		}
+		conversions = append(conversions, conversion(
+			fmt.Sprintf("%s %d-bit to %d-bit", verb, srcBits, targetBits), bitsLossy))
+	}
+	return targetRate, targetBits, conversions
+}
+
+func conversion(what string, lossy bool) string {
+	if lossy {
+		return what + " (lossy)"
+	}
+	return what
+}
+
+func resolveRate(rate int, rates []int) (int, bool) {
+	return resolveAbove(rate, rates, func(r int) bool { return r%rate == 0 })
+}
+
+// resolveAbove returns want if it's in choices (or there are no choices), otherwise the smallest choice above it,
+// the ones preferred says yes to first. Failing that it's the largest choice below want, which is lossy.
+func resolveAbove(want int, choices []int, preferred func(int) bool) (int, bool) {
+	if len(choices) == 0 || want <= 0 {
+		return want, false
+	}
+	sorted := append([]int(nil), choices...)
+	sort.Ints(sorted)
+	for _, c := range sorted {
+		if c == want {
+			return want, false
+		}
+	}
+	if preferred != nil {
+		for _, c := range sorted {
+			if c > want && preferred(c) {
+				return c, false
+			}
+		}
+	}
+	for _, c := range sorted {
+		if c > want {
+			return c, false
+		}
+	}
+	return sorted[len(sorted)-1], true
+}
@@ -0,0 +1,390 @@
+package alsa
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+A WAV file is a RIFF container:
+
+	"RIFF" <size> "WAVE" <chunk> <chunk> ...
+
+Every chunk is a 4 byte id, a 4 byte little endian size and the body.
+Bodies with an odd size are followed by a pad byte that isn't counted in the size.
+The chunks we care about are "fmt " and "data", but files can hold
+any number of other chunks (LIST, fact, cue , bext...) in any order.
+
+Files over 4GB don't fit the 32-bit sizes, so RF64 (and BW64, its EBU twin) swaps the "RIFF" id
+for "RF64", sets the sizes that overflow to 0xFFFFFFFF and puts the real 64-bit sizes in a
+"ds64" chunk that comes first. Only reading is supported; everything we write is plain RIFF.
+*/
+
+// WAVE format categories of the fmt chunk.
+const (
+	wavFormatPCM   = 1
+	wavFormatFloat = 3
+)
+
+const riffHeaderSize = 12
+const chunkHeaderSize = 8
+
+// rf64SizePlaceholder is the 32-bit size of a chunk whose real size is in the ds64 chunk.
+const rf64SizePlaceholder = 0xFFFFFFFF
+
+// maxFmtExtension is the most a fmt chunk can hold past the PCM fields: the 2 byte cbSize and what it counts.
+const maxFmtExtension = 2 + 0xFFFF
+
+type riffChunk struct {
+	id     string
+	offset int64 // Offset of the chunk body from the start of the file
+	size   int64 // Size declared in the chunk header
+}
+
+// wavFmt holds the fields of a "fmt " chunk.
+type wavFmt struct {
+	audioFormat   uint16
+	numChannels   uint16
+	sampleRate    uint32
+	byteRate      uint32
+	blockAlign    uint16
+	bitsPerSample uint16
+	// extension is whatever follows the PCM fields: cbSize and, for WAVE_FORMAT_EXTENSIBLE,
+	// the valid bits, channel mask and subformat. It's a string so formats can still be compared with ==.
+	extension string
+}
+
+// walkChunks returns the top level chunks of a WAV file in the order they appear.
+// Declared sizes are trusted for walking, but a chunk running past the end of the file
+// simply ends the walk, so truncated files still yield the chunks before the damage.
+func walkChunks(r io.ReadSeeker) ([]riffChunk, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	header := make([]byte, riffHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("Failed to read riff header: %v", err)
+	}
+	magic := string(header[0:4])
+	if (magic != "RIFF" && magic != "RF64" && magic != "BW64") || string(header[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("Not a RIFF/WAVE file")
+	}
+	var sizes map[string]int64
+
+	var chunks []riffChunk
+	offset := int64(riffHeaderSize)
+	chunkHeader := make([]byte, chunkHeaderSize)
+	for {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return chunks, err
+		}
+		if _, err := io.ReadFull(r, chunkHeader); err != nil {
+			if err == io.EOF {
+				return chunks, nil
+			}
+			return chunks, fmt.Errorf("Failed to read chunk header at offset %d: %v", offset, err)
+		}
+		c := riffChunk{
+			id:     string(chunkHeader[0:4]),
+			offset: offset + chunkHeaderSize,
+			size:   int64(binary.LittleEndian.Uint32(chunkHeader[4:8])),
+		}
+		if c.id == "ds64" && magic != "RIFF" {
+			var err error
+			if sizes, err = readDs64(r, c); err != nil {
+				return chunks, err
+			}
+		}
+		if size, ok := sizes[c.id]; ok && c.size == rf64SizePlaceholder {
+			c.size = size
+		}
+		chunks = append(chunks, c)
+		offset = c.offset + c.size + c.size%2
+	}
+}
+
+// readDs64 returns the 64-bit chunk sizes of an RF64 file by chunk id.
+// The ds64 chunk holds the RIFF size, the data size and the sample count,
+// followed by a table with the size of any other chunk bigger than 4GB.
+func readDs64(r io.ReadSeeker, c riffChunk) (map[string]int64, error) {
+	if c.size < 28 {
+		return nil, fmt.Errorf("ds64 chunk is too small (%d bytes)", c.size)
+	}
+	// The size comes from the file, don't allocate more than the file could hold.
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if c.offset+c.size > end {
+		return nil, fmt.Errorf("ds64 chunk declares %d bytes, the file ends %d bytes in", c.size, end-c.offset)
+	}
+	if _, err := r.Seek(c.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	body := make([]byte, c.size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("Failed to read ds64 chunk: %v", err)
+	}
+	sizes := map[string]int64{
+		"data": int64(binary.LittleEndian.Uint64(body[8:])),
+	}
+	entries := int(binary.LittleEndian.Uint32(body[24:]))
+	for i, off := 0, 28; i < entries && off+12 <= len(body); i, off = i+1, off+12 {
+		sizes[string(body[off:off+4])] = int64(binary.LittleEndian.Uint64(body[off+4:]))
+	}
+	return sizes, nil
+}
+
+// ChunkInfo describes a top level chunk of a WAV file.
+type ChunkInfo struct {
+	ID string
+	// Offset of the chunk header from the start of the file.
+	Offset int64
+	// Size of the body as declared in the header (or in ds64 for RF64 files).
+	Size int64
+	// Padded is set for odd sized chunks, which are followed by a pad byte.
+	Padded bool
+}
+
+// ListChunks returns every top level chunk of a WAV file in the order they appear.
+// If the walk runs into a damaged chunk, the chunks before it are returned along with the error.
+func ListChunks(path string) ([]ChunkInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	chunks, err := walkChunks(f)
+	infos := make([]ChunkInfo, len(chunks))
+	for i, c := range chunks {
+		infos[i] = ChunkInfo{
+			ID:     c.id,
+			Offset: c.offset - chunkHeaderSize,
+			Size:   c.size,
+			Padded: c.size%2 == 1,
+		}
+	}
+	return infos, err
+}
+
+// DataChunkOffset returns where the audio of a WAV file starts (the body of its data chunk, past the header)
+// and the size the data chunk declares, which may be more than the file holds if it was cut short.
+// Damage after the data chunk doesn't matter.
+func DataChunkOffset(path string) (offset int64, size int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	chunks, walkErr := walkChunks(f)
+	data, ok := findChunk(chunks, "data")
+	if !ok {
+		if walkErr != nil {
+			return 0, 0, errors.Wrapf(walkErr, "no data chunk found in %q", path)
+		}
+		return 0, 0, fmt.Errorf("%q has no data chunk", path)
+	}
+	return data.offset, data.size, nil
+}
+
+// findChunk returns the first chunk with the given id.
+func findChunk(chunks []riffChunk, id string) (riffChunk, bool) {
+	for _, c := range chunks {
+		if c.id == id {
+			return c, true
+		}
+	}
+	return riffChunk{}, false
+}
+
+func readFmtChunk(r io.ReadSeeker, c riffChunk) (wavFmt, error) {
+	if c.size < 16 {
+		return wavFmt{}, fmt.Errorf("fmt chunk is too small (%d bytes)", c.size)
+	}
+	if _, err := r.Seek(c.offset, io.SeekStart); err != nil {
+		return wavFmt{}, err
+	}
+	size := c.size
+	if size > 16+maxFmtExtension {
+		size = 16 + maxFmtExtension
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return wavFmt{}, fmt.Errorf("Failed to read fmt chunk: %v", err)
+	}
+	return wavFmt{
+		audioFormat:   binary.LittleEndian.Uint16(body[0:]),
+		numChannels:   binary.LittleEndian.Uint16(body[2:]),
+		sampleRate:    binary.LittleEndian.Uint32(body[4:]),
+		byteRate:      binary.LittleEndian.Uint32(body[8:]),
+		blockAlign:    binary.LittleEndian.Uint16(body[12:]),
+		bitsPerSample: binary.LittleEndian.Uint16(body[14:]),
+		extension:     string(body[16:]),
+	}, nil
+}
+
+// fmtBody is the body of the fmt chunk for f, 16 bytes plus the extension.
+func fmtBody(f wavFmt) []byte {
+	body := make([]byte, 16, 16+len(f.extension))
+	binary.LittleEndian.PutUint16(body[0:], f.audioFormat)
+	binary.LittleEndian.PutUint16(body[2:], f.numChannels)
+	binary.LittleEndian.PutUint32(body[4:], f.sampleRate)
+	binary.LittleEndian.PutUint32(body[8:], f.byteRate)
+	binary.LittleEndian.PutUint16(body[12:], f.blockAlign)
+	binary.LittleEndian.PutUint16(body[14:], f.bitsPerSample)
+	return append(body, f.extension...)
+}
+
+// writeWavHeader writes the RIFF header, the fmt chunk and the data chunk header.
+// That's the canonical 44 bytes unless the format has an extension.
+func writeWavHeader(w io.Writer, f wavFmt, dataSize uint32) error {
+	body := fmtBody(f)
+	fmtSize := uint32(len(body))
+	header := make([]byte, 0, riffHeaderSize+chunkHeaderSize+len(body)+1+chunkHeaderSize)
+	header = append(header, "RIFF"...)
+	header = appendUint32(header, 4+chunkHeaderSize+fmtSize+fmtSize%2+chunkHeaderSize+dataSize+dataSize%2)
+	header = append(header, "WAVE"...)
+	header = append(header, "fmt "...)
+	header = appendUint32(header, fmtSize)
+	header = append(header, body...)
+	if fmtSize%2 == 1 {
+		header = append(header, 0)
+	}
+	header = append(header, "data"...)
+	header = appendUint32(header, dataSize)
+	_, err := w.Write(header)
+	return err
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// pcmReader reads the samples of the data chunk of a RIFF or RF64 WAV file, a block at a time.
+type pcmReader struct {
+	r         io.ReadSeeker
+	format    wavFmt
+	data      riffChunk
+	remaining int64
+	buf       []byte
+}
+
+func newPCMReader(r io.ReadSeeker) (*pcmReader, error) {
+	chunks, err := walkChunks(r)
+	if err != nil && len(chunks) == 0 {
+		return nil, err
+	}
+	fmtChunk, ok := findChunk(chunks, "fmt ")
+	if !ok {
+		return nil, fmt.Errorf("no fmt chunk")
+	}
+	data, ok := findChunk(chunks, "data")
+	if !ok {
+		return nil, fmt.Errorf("no data chunk")
+	}
+	format, err := readFmtChunk(r, fmtChunk)
+	if err != nil {
+		return nil, err
+	}
+	// WAVE_FORMAT_EXTENSIBLE says what the samples are in the first two bytes of the subformat GUID.
+	pcm := format.audioFormat == wavFormatPCM ||
+		(format.audioFormat == wavFormatExtensible && len(format.extension) >= 12 &&
+			binary.LittleEndian.Uint16([]byte(format.extension[10:])) == wavFormatPCM)
+	if !pcm {
+		return nil, fmt.Errorf("not integer PCM (format %d)", format.audioFormat)
+	}
+	switch format.bitsPerSample {
+	case 8, 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("unsupported bit depth %d", format.bitsPerSample)
+	}
+	if format.numChannels == 0 || format.sampleRate == 0 {
+		return nil, fmt.Errorf("%d channels at %d Hz", format.numChannels, format.sampleRate)
+	}
+	p := &pcmReader{r: r, format: format, data: data}
+	return p, p.rewind()
+}
+
+// rewind goes back to the first sample.
+func (p *pcmReader) rewind() error {
+	p.remaining = p.data.size
+	_, err := p.r.Seek(p.data.offset, io.SeekStart)
+	return err
+}
+
+func (p *pcmReader) channels() int {
+	return int(p.format.numChannels)
+}
+
+func (p *pcmReader) bitDepth() int {
+	return int(p.format.bitsPerSample)
+}
+
+// duration is how long the data chunk says the audio is.
+func (p *pcmReader) duration() time.Duration {
+	frames := p.data.size / int64(p.channels()*p.bitDepth()/8)
+	return time.Duration(frames) * time.Second / time.Duration(p.format.sampleRate)
+}
+
+// read fills samples with whole frames and returns how many samples it read, 0 once the audio is over.
+// A file that ends before its data chunk does just ends the audio early.
+// 8-bit WAV samples are unsigned with silence at 128, they come back signed like the other depths.
+func (p *pcmReader) read(samples []int) (int, error) {
+	size := p.bitDepth() / 8
+	frameSize := size * p.channels()
+	n := int64(len(samples)/p.channels()) * int64(frameSize)
+	if n > p.remaining {
+		n = p.remaining - p.remaining%int64(frameSize)
+	}
+	if n <= 0 {
+		return 0, nil
+	}
+	if int64(cap(p.buf)) < n {
+		p.buf = make([]byte, n)
+	}
+	buf := p.buf[:n]
+	read, err := io.ReadFull(p.r, buf)
+	p.remaining -= int64(read)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		p.remaining = 0
+		err = nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	buf = buf[:read-read%frameSize]
+	for i := range buf[:len(buf)/size] {
+		off := i * size
+		switch size {
+		case 1:
+			samples[i] = int(buf[off]) - 128
+		case 2:
+			samples[i] = int(int16(binary.LittleEndian.Uint16(buf[off:])))
+		case 3:
+			samples[i] = unpack24(buf[off:])
+		case 4:
+			samples[i] = int(int32(binary.LittleEndian.Uint32(buf[off:])))
+		}
+	}
+	return len(buf) / size, nil
+}
+
+// skip discards up to n bytes of audio, returning io.EOF if there's none left.
+func (p *pcmReader) skip(n int64) error {
+	if p.remaining <= 0 {
+		return io.EOF
+	}
+	if n > p.remaining {
+		n = p.remaining
+	}
+	p.remaining -= n
+	_, err := p.r.Seek(n, io.SeekCurrent)
+	return err
+}
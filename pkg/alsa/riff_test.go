@@ -0,0 +1,148 @@
+package alsa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yobert/alsa"
+)
+
+// rf64Bytes builds an RF64 file holding the chunks, with the data size in the ds64 chunk.
+func rf64Bytes(chunks ...testChunk) []byte {
+	var dataSize int
+	for _, c := range chunks {
+		if c.id == "data" {
+			dataSize = len(c.body)
+		}
+	}
+	ds64 := make([]byte, 28)
+	binary.LittleEndian.PutUint64(ds64[8:], uint64(dataSize))
+	file := wavBytes(append([]testChunk{{"ds64", ds64}}, chunks...)...)
+	copy(file, "RF64")
+	binary.LittleEndian.PutUint32(file[4:], rf64SizePlaceholder)
+	data := bytes.Index(file, []byte("data"))
+	binary.LittleEndian.PutUint32(file[data+4:], rf64SizePlaceholder)
+	binary.LittleEndian.PutUint64(file[riffHeaderSize+chunkHeaderSize:], uint64(len(file)-8))
+	return file
+}
+
+func readAll(t *testing.T, p *pcmReader, block int) []int {
+	t.Helper()
+	var all []int
+	buf := make([]int, block)
+	for {
+		n, err := p.read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			return all
+		}
+		all = append(all, buf[:n]...)
+	}
+}
+
+func TestPCMReaderRF64(t *testing.T) {
+	audio := sineBuffer(t, alsa.S16_LE, 2, 8000, 1000)
+	want, _ := decodeSamples(audio)
+	for name, file := range map[string][]byte{
+		"RIFF": wavBytes(pcmFmt(2, 8000, 16), testChunk{"data", audio.Data}),
+		"RF64": rf64Bytes(pcmFmt(2, 8000, 16), testChunk{"data", audio.Data}, testChunk{"LIST", []byte("INFO")}),
+	} {
+		p, err := newPCMReader(bytes.NewReader(file))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if d := p.duration(); d != 125*time.Millisecond {
+			t.Errorf("%s: duration %v", name, d)
+		}
+		if got := readAll(t, p, 2*300); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: read %d samples, want %d", name, len(got), len(want))
+		}
+		if err := p.rewind(); err != nil {
+			t.Fatal(err)
+		}
+		if got := readAll(t, p, 2*300); len(got) != len(want) {
+			t.Errorf("%s: read %d samples after rewinding, want %d", name, len(got), len(want))
+		}
+	}
+}
+
+// A file cut short in the middle of a frame plays the whole frames it has.
+func TestPCMReaderTruncated(t *testing.T) {
+	audio := sineBuffer(t, S24_3LE, 2, 8000, 100)
+	file := wavBytes(pcmFmt(2, 8000, 24), testChunk{"data", audio.Data})
+	p, err := newPCMReader(bytes.NewReader(file[:len(file)-100]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := decodeSamples(audio)
+	got := readAll(t, p, 2*64)
+	if len(got) != 2*(100-17) || !reflect.DeepEqual(got, want[:len(got)]) {
+		t.Errorf("read %d samples of the %d left", len(got), 2*(100-17))
+	}
+}
+
+func TestPCMReaderRejectsFloat(t *testing.T) {
+	f := pcmFmt(1, 8000, 32)
+	binary.LittleEndian.PutUint16(f.body, wavFormatFloat)
+	if _, err := newPCMReader(bytes.NewReader(wavBytes(f, testChunk{"data", make([]byte, 8)}))); err == nil {
+		t.Error("read float samples as integers")
+	}
+}
+
+func TestListChunks(t *testing.T) {
+	file := writeTemp(t, "chunks.wav", wavBytes(
+		pcmFmt(1, 8000, 16),
+		testChunk{"junk", []byte{1, 2, 3}}, // odd, so a pad byte follows
+		testChunk{"data", make([]byte, 8)},
+	))
+	chunks, err := ListChunks(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ChunkInfo{
+		{ID: "fmt ", Offset: 12, Size: 16},
+		{ID: "junk", Offset: 36, Size: 3, Padded: true},
+		{ID: "data", Offset: 48, Size: 8}, // past the pad byte
+	}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("got chunks %+v, want %+v", chunks, want)
+	}
+}
+
+// The audio starts past whatever metadata comes before the data chunk.
+func TestDataChunkOffsetAfterList(t *testing.T) {
+	list := append([]byte("INFOINAM"), 5, 0, 0, 0)
+	list = append(list, "hello\x00"...)
+	data := bytes.Repeat([]byte{0xAB}, 40)
+	raw := wavBytes(pcmFmt(1, 8000, 16), testChunk{"LIST", list}, testChunk{"data", data})
+	file := writeTemp(t, "list.wav", raw)
+
+	offset, size, err := DataChunkOffset(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 70 || size != 40 {
+		t.Errorf("got data at %d, %d bytes, want 70, 40", offset, size)
+	}
+	if !bytes.Equal(raw[offset:offset+size], data) {
+		t.Error("the offset isn't where the audio starts")
+	}
+}
+
+// A ds64 chunk declaring more than the file holds is reported, not read into a buffer of that size.
+func TestDs64PastEndOfFile(t *testing.T) {
+	file := rf64Bytes(pcmFmt(1, 8000, 16), testChunk{"data", make([]byte, 8)})
+	binary.LittleEndian.PutUint32(file[riffHeaderSize+4:], 0xFFFFFFF0)
+	chunks, err := ListChunks(writeTemp(t, "huge.wav", file))
+	if err == nil {
+		t.Fatalf("listed %v", chunks)
+	}
+	if len(chunks) != 0 {
+		t.Errorf("listed %v before the ds64 chunk", chunks)
+	}
+}
@@ -0,0 +1,238 @@
+package alsa
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/yobert/alsa"
+)
+
+// S24_3LE is packed 24-bit little endian: 3 bytes per sample with no padding, which is what a lot of
+// USB interfaces use natively. yobert/alsa stops at FLOAT64_BE, so it's defined here with ALSA's value.
+// Note that yobert/alsa only handles the first 32 bits of the format mask, so it can't negotiate
+// S24_3LE with a device yet; the conversion code handles it for buffers and 24-bit files.
+// It's still in the format preferences, after the formats yobert/alsa can negotiate, so a device that
+// only takes S24_3LE fails with an error that says so.
+const S24_3LE alsa.FormatType = 32
+
+// formatTypeLast is the last format the package knows about.
+const formatTypeLast = S24_3LE
+
+// SampleSize returns how many bytes ALSA uses to store one sample of the format, or 0 for unknown formats.
+// 24-bit formats are stored in 4 byte containers, except for the packed S24_3LE.
+func SampleSize(format alsa.FormatType) int {
+	switch format {
+	case alsa.S8, alsa.U8:
+		return 1
+	case alsa.S16_LE, alsa.S16_BE, alsa.U16_LE, alsa.U16_BE:
+		return 2
+	case alsa.S24_LE, alsa.S24_BE, alsa.U24_LE, alsa.U24_BE:
+		return 4
+	case S24_3LE:
+		return 3
+	case alsa.S32_LE, alsa.S32_BE, alsa.U32_LE, alsa.U32_BE, alsa.FLOAT_LE, alsa.FLOAT_BE:
+		return 4
+	case alsa.FLOAT64_LE, alsa.FLOAT64_BE:
+		return 8
+	}
+	return 0
+}
+
+// SampleBits returns how many bits of a sample of the format carry audio,
+// which for the 24-bit formats in 4 byte containers is less than the container.
+func SampleBits(format alsa.FormatType) int {
+	switch format {
+	case alsa.S24_LE, alsa.S24_BE, alsa.U24_LE, alsa.U24_BE:
+		return 24
+	}
+	return 8 * SampleSize(format)
+}
+
+// sampleBytes is SampleSize restricted to the formats the conversion code handles.
+func sampleBytes(format alsa.FormatType) (int, error) {
+	switch format {
+	case alsa.S16_LE, alsa.S24_LE, S24_3LE, alsa.S32_LE:
+		return SampleSize(format), nil
+	}
+	return 0, fmt.Errorf("Unhandled ALSA format %v", format)
+}
+
+// bytesPerFrame returns the size of one frame (a sample for every channel) of the buffer format.
+func bytesPerFrame(format alsa.BufferFormat) int {
+	return SampleSize(format.SampleFormat) * format.Channels
+}
+
+// maxSample returns the largest positive value a sample of the format can hold.
+func maxSample(format alsa.FormatType) int {
+	switch format {
+	case alsa.S16_LE:
+		return math.MaxInt16
+	case alsa.S24_LE, S24_3LE:
+		return 1<<23 - 1
+	case alsa.S32_LE:
+		return math.MaxInt32
+	}
+	return 0
+}
+
+// clampSample keeps v within the signed range of the format,
+// so scaled samples saturate instead of wrapping around.
+func clampSample(v float64, format alsa.FormatType) int {
+	max := float64(maxSample(format))
+	min := -max - 1
+	if v > max {
+		return int(max)
+	}
+	if v < min {
+		return int(min)
+	}
+	return int(math.Round(v))
+}
+
+// decodeSamples converts the raw little endian PCM data of the buffer into signed integer samples.
+// Samples stay interleaved, so sample i belongs to channel i % channels.
+func decodeSamples(buf alsa.Buffer) ([]int, error) {
+	size, err := sampleBytes(buf.Format.SampleFormat)
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]int, len(buf.Data)/size)
+	for i := range samples {
+		off := i * size
+		switch buf.Format.SampleFormat {
+		case alsa.S16_LE:
+			samples[i] = int(int16(binary.LittleEndian.Uint16(buf.Data[off:])))
+		case alsa.S24_LE, S24_3LE:
+			samples[i] = unpack24(buf.Data[off:])
+		case alsa.S32_LE:
+			samples[i] = int(int32(binary.LittleEndian.Uint32(buf.Data[off:])))
+		}
+	}
+	return samples, nil
+}
+
+// encodeSamples is the inverse of decodeSamples.
+func encodeSamples(samples []int, format alsa.FormatType) ([]byte, error) {
+	size, err := sampleBytes(format)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, len(samples)*size)
+	for i, sample := range samples {
+		off := i * size
+		switch format {
+		case alsa.S16_LE:
+			binary.LittleEndian.PutUint16(data[off:], uint16(int16(sample)))
+		case alsa.S24_LE:
+			binary.LittleEndian.PutUint32(data[off:], uint32(int32(sample)))
+		case S24_3LE:
+			pack24(data[off:], sample)
+		case alsa.S32_LE:
+			binary.LittleEndian.PutUint32(data[off:], uint32(int32(sample)))
+		}
+	}
+	return data, nil
+}
+
+// unpack24 reads a packed little endian 24-bit sample, sign extending it.
+func unpack24(b []byte) int {
+	v := int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+	if v&0x800000 != 0 {
+		v -= 1 << 24
+	}
+	return v
+}
+
+// pack24 writes the low 24 bits of sample as 3 little endian bytes.
+func pack24(b []byte, sample int) {
+	b[0] = byte(sample)
+	b[1] = byte(sample >> 8)
+	b[2] = byte(sample >> 16)
+}
+
+// ConvertFormat returns the recording with its samples converted to another sample format,
+// for example to save a capture negotiated as S32_LE as a 24-bit file.
+func ConvertFormat(recording alsa.Buffer, format alsa.FormatType) (alsa.Buffer, error) {
+	if recording.Format.SampleFormat == format {
+		return recording, nil
+	}
+	samples, err := decodeSamples(recording)
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+	if _, err := sampleBytes(format); err != nil {
+		return alsa.Buffer{}, err
+	}
+	from, to := SampleBits(recording.Format.SampleFormat), SampleBits(format)
+	for i, sample := range samples {
+		samples[i] = scaleBits(sample, from, to)
+	}
+	data, err := encodeSamples(samples, format)
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+	converted := alsa.Buffer{Format: recording.Format, Data: data}
+	converted.Format.SampleFormat = format
+	return converted, nil
+}
+
+// standardRates are the sample rates devices commonly offer.
+var standardRates = []int{8000, 11025, 16000, 22050, 32000, 44100, 48000, 88200, 96000, 176400, 192000}
+
+// SupportedRates lists the sample rates callers can offer a user.
+// Recording and saving work at any of them; playback negotiates the device rate separately.
+func SupportedRates() []int {
+	rates := make([]int, len(standardRates))
+	copy(rates, standardRates)
+	return rates
+}
+
+// SupportedFormats lists the ALSA sample formats the package can convert.
+// It's derived from sampleBytes, so adding a format there adds it here.
+func SupportedFormats() []alsa.FormatType {
+	var formats []alsa.FormatType
+	for f := alsa.FormatTypeFirst; f <= formatTypeLast; f++ {
+		if _, err := sampleBytes(f); err == nil {
+			formats = append(formats, f)
+		}
+	}
+	return formats
+}
+
+// decodeFloats is decodeSamples scaled to the -1..1 range.
+func decodeFloats(buf alsa.Buffer) ([]float64, error) {
+	samples, err := decodeSamples(buf)
+	if err != nil {
+		return nil, err
+	}
+	scale := float64(maxSample(buf.Format.SampleFormat)) + 1
+	floats := make([]float64, len(samples))
+	for i, sample := range samples {
+		floats[i] = float64(sample) / scale
+	}
+	return floats, nil
+}
+
+// mixDown averages the channels of interleaved samples into one.
+func mixDown(samples []float64, channels int) []float64 {
+	mono := make([]float64, len(samples)/channels)
+	for i := range mono {
+		var sum float64
+		for c := 0; c < channels; c++ {
+			sum += samples[i*channels+c]
+		}
+		mono[i] = sum / float64(channels)
+	}
+	return mono
+}
+
+// encodeFloats is the inverse of decodeFloats, clamping anything outside the -1..1 range.
+func encodeFloats(floats []float64, format alsa.FormatType) ([]byte, error) {
+	scale := float64(maxSample(format)) + 1
+	samples := make([]int, len(floats))
+	for i, f := range floats {
+		samples[i] = clampSample(f*scale, format)
+	}
+	return encodeSamples(samples, format)
+}
@@ -0,0 +1,56 @@
+package alsa
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/yobert/alsa"
+)
+
+// RMS returns the root mean square level of the recording, across all channels, as a fraction of full scale.
+func RMS(buf alsa.Buffer) (float64, error) {
+	samples, err := decodeFloats(buf)
+	if err != nil {
+		return 0, err
+	}
+	if len(samples) == 0 {
+		return 0, fmt.Errorf("no samples")
+	}
+	var sum float64
+	for _, v := range samples {
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(samples))), nil
+}
+
+// MeasureSNR returns the signal to noise ratio in dB between a WAV file of a take with signal
+// (a test tone, say) and one of just the noise floor, recorded the same way.
+// Both files need the same format.
+func MeasureSNR(signal, noise string) (float64, error) {
+	signalBuf, err := loadWav(signal)
+	if err != nil {
+		return 0, err
+	}
+	noiseBuf, err := loadWav(noise)
+	if err != nil {
+		return 0, err
+	}
+	if signalBuf.Format != noiseBuf.Format {
+		return 0, fmt.Errorf("%q is %d channel %d Hz %v, %q is %d channel %d Hz %v",
+			signal, signalBuf.Format.Channels, signalBuf.Format.Rate, signalBuf.Format.SampleFormat,
+			noise, noiseBuf.Format.Channels, noiseBuf.Format.Rate, noiseBuf.Format.SampleFormat)
+	}
+
+	signalRMS, err := RMS(signalBuf)
+	if err != nil {
+		return 0, fmt.Errorf("%q: %v", signal, err)
+	}
+	noiseRMS, err := RMS(noiseBuf)
+	if err != nil {
+		return 0, fmt.Errorf("%q: %v", noise, err)
+	}
+	if noiseRMS == 0 {
+		return math.Inf(1), nil
+	}
+	return 20 * math.Log10(signalRMS/noiseRMS), nil
+}
@@ -0,0 +1,66 @@
+package alsa
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/yobert/alsa"
+)
+
+// StereoWidth widens or narrows the stereo image of the recording.
+// Left and right are converted to mid (L+R)/2 and side (L-R)/2, the side is scaled by width,
+// and the result is converted back.
+// A width of 0 collapses the recording to mono, 1 leaves it unchanged and anything above 1 widens it.
+func StereoWidth(recording alsa.Buffer, width float64) (alsa.Buffer, error) {
+	if recording.Format.Channels != 2 {
+		return alsa.Buffer{}, fmt.Errorf("stereo width needs a stereo recording, got %d channels", recording.Format.Channels)
+	}
+	if width < 0 {
+		return alsa.Buffer{}, fmt.Errorf("stereo width must not be negative, got %v", width)
+	}
+
+	samples, err := decodeSamples(recording)
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+	format := recording.Format.SampleFormat
+	for i := 0; i+1 < len(samples); i += 2 {
+		left, right := float64(samples[i]), float64(samples[i+1])
+		mid := (left + right) / 2
+		side := (left - right) / 2 * width
+		samples[i] = clampSample(mid+side, format)
+		samples[i+1] = clampSample(mid-side, format)
+	}
+
+	data, err := encodeSamples(samples, format)
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+	return alsa.Buffer{Format: recording.Format, Data: data}, nil
+}
+
+// MonoCompatibility returns how much level the recording loses, in dB, when it's folded down to mono as (L+R)/2,
+// compared to the average level of the two channels.
+// Identical channels lose nothing (0dB) and unrelated ones about 3dB.
+// Anything much below -3dB means the channels are partly out of phase and cancel each other out,
+// down to -Inf when they're exact opposites. A silent recording loses nothing.
+func MonoCompatibility(recording alsa.Buffer) (float64, error) {
+	if recording.Format.Channels != 2 {
+		return 0, fmt.Errorf("mono compatibility needs a stereo recording, got %d channels", recording.Format.Channels)
+	}
+	samples, err := decodeSamples(recording)
+	if err != nil {
+		return 0, err
+	}
+	var stereo, mono float64
+	for i := 0; i+1 < len(samples); i += 2 {
+		left, right := float64(samples[i]), float64(samples[i+1])
+		stereo += (left*left + right*right) / 2
+		mid := (left + right) / 2
+		mono += mid * mid
+	}
+	if stereo == 0 {
+		return 0, nil
+	}
+	return 10 * math.Log10(mono/stereo), nil
+}
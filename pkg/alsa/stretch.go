@@ -0,0 +1,122 @@
+package alsa
+
+import (
+	"math"
+	"time"
+
+	"github.com/yobert/alsa"
+)
+
+/*
+TimeStretch uses WSOLA (waveform similarity overlap-add).
+The output is built from windowed segments of the input laid down every half segment.
+Stretching takes the segments from input positions closer together than that, squeezing from further apart,
+so the length changes while every segment still plays at its original speed and pitch.
+Plain overlap-add makes a warble where segments that are out of phase overlap,
+so each segment is nudged (by up to stretchTolerance) to where the input looks most like
+the continuation of the previous segment.
+*/
+
+const (
+	stretchSegment   = 40 * time.Millisecond
+	stretchTolerance = 10 * time.Millisecond
+)
+
+// TimeStretch changes the length of the recording by factor without changing its pitch:
+// 2 makes it twice as long (half speed), 0.5 half as long.
+// Buffers with a format that can't be converted, or a factor that isn't positive, are returned unchanged.
+func TimeStretch(buf alsa.Buffer, factor float64) alsa.Buffer {
+	channels := buf.Format.Channels
+	rate := buf.Format.Rate
+	samples, err := decodeFloats(buf)
+	if err != nil || channels < 1 || rate < 1 || factor <= 0 {
+		return buf
+	}
+	frames := len(samples) / channels
+	segment := durationToFrames(stretchSegment, rate)
+	hop := segment / 2
+	tolerance := durationToFrames(stretchTolerance, rate)
+	if frames < segment || hop < 1 {
+		return buf
+	}
+
+	// A Hann window at 50% overlap sums to one.
+	window := make([]float64, segment)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(segment))
+	}
+
+	mono := mixDown(samples, channels)
+	outFrames := int(float64(frames)*factor + 0.5)
+	out := make([]float64, (outFrames+segment)*channels)
+	weight := make([]float64, outFrames+segment)
+
+	prev := -1
+	for start := 0; start < outFrames; start += hop {
+		pos := int(float64(start) / factor)
+		if pos > frames-segment {
+			pos = frames - segment
+		}
+		if prev >= 0 {
+			pos = similarSegment(mono, prev+hop, pos, tolerance, segment)
+		}
+		for i, w := range window {
+			for c := 0; c < channels; c++ {
+				out[(start+i)*channels+c] += samples[(pos+i)*channels+c] * w
+			}
+			weight[start+i] += w
+		}
+		prev = pos
+	}
+
+	// The ends only get one side of the overlap.
+	out = out[:outFrames*channels]
+	for f := 0; f < outFrames; f++ {
+		if weight[f] > 1e-3 {
+			for c := 0; c < channels; c++ {
+				out[f*channels+c] /= weight[f]
+			}
+		}
+	}
+
+	data, err := encodeFloats(out, buf.Format.SampleFormat)
+	if err != nil {
+		return buf
+	}
+	return alsa.Buffer{Format: buf.Format, Data: data}
+}
+
+// similarSegment returns the segment start within tolerance of nominal that best matches
+// the segment starting at natural, the way the input would have continued from the previous segment.
+func similarSegment(mono []float64, natural, nominal, tolerance, segment int) int {
+	last := len(mono) - segment
+	if natural > last {
+		return clampInt(nominal, 0, last)
+	}
+	best, bestScore := clampInt(nominal, 0, last), math.Inf(-1)
+	for cand := clampInt(nominal-tolerance, 0, last); cand <= clampInt(nominal+tolerance, 0, last); cand++ {
+		// Every other sample is plenty to find the best alignment.
+		var corr, energy float64
+		for i := 0; i < segment; i += 2 {
+			corr += mono[natural+i] * mono[cand+i]
+			energy += mono[cand+i] * mono[cand+i]
+		}
+		if energy == 0 {
+			continue
+		}
+		if score := corr / math.Sqrt(energy); score > bestScore {
+			best, bestScore = cand, score
+		}
+	}
+	return best
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,40 @@
+package alsa
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yobert/alsa"
+)
+
+func TestTimeStretchKeepsPitch(t *testing.T) {
+	in := sineBuffer(t, alsa.S16_LE, 1, 44100, 44100)
+	inFrames := len(in.Data) / 2
+	for _, factor := range []float64{2, 0.5} {
+		out := TimeStretch(in, factor)
+		if out.Format != in.Format {
+			t.Errorf("x%v: format changed to %+v", factor, out.Format)
+		}
+		frames := len(out.Data) / 2
+		want := float64(inFrames) * factor
+		if math.Abs(float64(frames)-want) > want*0.02 {
+			t.Errorf("x%v: %d frames, want about %.0f", factor, frames, want)
+		}
+		hz, confidence := DetectPitch(out)
+		if math.Abs(hz-440) > 440*0.01 || confidence < 0.9 {
+			t.Errorf("x%v: pitch %.1f Hz (confidence %.2f), want 440 Hz", factor, hz, confidence)
+		}
+	}
+}
+
+func TestTimeStretchUnchanged(t *testing.T) {
+	in := sineBuffer(t, alsa.S16_LE, 1, 44100, 1000)
+	if out := TimeStretch(in, 0); len(out.Data) != len(in.Data) {
+		t.Errorf("a factor of 0 changed the length to %d bytes", len(out.Data))
+	}
+	// Shorter than a segment.
+	short := sineBuffer(t, alsa.S16_LE, 1, 44100, 100)
+	if out := TimeStretch(short, 2); len(out.Data) != len(short.Data) {
+		t.Errorf("stretched a buffer shorter than a segment to %d bytes", len(out.Data))
+	}
+}
@@ -0,0 +1,143 @@
+package alsa
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yobert/alsa"
+)
+
+// RecordSubdevices captures from count subdevices of the device at once, each one mono,
+// and interleaves them into a single buffer with one channel per subdevice.
+// Interfaces that expose every input as a subdevice of the same PCM can then be recorded into one multichannel file.
+//
+// The kernel hands out the next free subdevice every time the PCM is opened, so the device is opened count times.
+// All the captures are prepared first and started together, but they're still separate streams:
+// the returned skew says how much later each one delivered its first chunk than the earliest one.
+// A skew under a frame (1/rate) means the channels are sample-aligned.
+// An overrun on any subdevice would break the alignment, so it fails the whole capture.
+func RecordSubdevices(dev *alsa.Device, count int, duration time.Duration, cfg AudioConfig) (alsa.Buffer, []time.Duration, error) {
+	if count < 1 {
+		return alsa.Buffer{}, nil, fmt.Errorf("need at least one subdevice, got %d", count)
+	}
+	cfg.Channels = 1
+
+	subdevices := make([]*alsa.Device, count)
+	params := make([]NegotiatedParams, count)
+	for i := range subdevices {
+		sub := *dev
+		subdevices[i] = &sub
+		var err error
+		params[i], err = prepareDevice(subdevices[i], cfg, deviceDefaults{
+			channels:     []int{1},
+			rates:        []int{44100},
+			formats:      []alsa.FormatType{alsa.S16_LE, alsa.S32_LE},
+			bufferFrames: []int{8192, 16384},
+		})
+		if err != nil {
+			return alsa.Buffer{}, nil, errors.Wrapf(err, "failed to set up subdevice %d", i)
+		}
+		defer subdevices[i].Close()
+		if params[i].Rate != params[0].Rate || params[i].Format != params[0].Format {
+			return alsa.Buffer{}, nil, fmt.Errorf("subdevice %d negotiated %d Hz %v, subdevice 0 %d Hz %v",
+				i, params[i].Rate, params[i].Format, params[0].Rate, params[0].Format)
+		}
+	}
+
+	buffers := make([]alsa.Buffer, count)
+	firstChunk := make([]time.Time, count)
+	errs := make([]error, count)
+	start := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i, sub := range subdevices {
+		buffers[i] = sub.NewBufferDuration(duration)
+		buffers[i].Format.SampleFormat = params[i].Format
+
+		wg.Add(1)
+		go func(i int, sub *alsa.Device) {
+			defer wg.Done()
+			buf := buffers[i].Data
+			chunkSize := params[i].BufferSize * sub.BytesPerFrame()
+			<-start
+			for off := 0; off < len(buf); off += chunkSize {
+				end := off + chunkSize
+				if end > len(buf) {
+					end = len(buf)
+				}
+				if err := deviceError(sub.Read(buf[off:end])); err != nil {
+					if isOverrun(err) {
+						err = &Overrun{Frame: off / sub.BytesPerFrame()}
+					}
+					errs[i] = errors.Wrapf(err, "subdevice %d", i)
+					return
+				}
+				if off == 0 {
+					firstChunk[i] = time.Now()
+				}
+			}
+		}(i, sub)
+	}
+	fmt.Printf("Recording %d subdevices for %s...\n", count, duration)
+	close(start)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return alsa.Buffer{}, nil, err
+		}
+	}
+
+	earliest := firstChunk[0]
+	for _, t := range firstChunk {
+		if t.Before(earliest) {
+			earliest = t
+		}
+	}
+	skew := make([]time.Duration, count)
+	for i, t := range firstChunk {
+		skew[i] = t.Sub(earliest)
+	}
+
+	recording, err := interleaveChannels(buffers)
+	if err != nil {
+		return alsa.Buffer{}, nil, err
+	}
+	return recording, skew, nil
+}
+
+// interleaveChannels merges mono buffers into one buffer with a channel per input, in order.
+// The result is as long as the shortest input.
+func interleaveChannels(buffers []alsa.Buffer) (alsa.Buffer, error) {
+	if len(buffers) == 0 {
+		return alsa.Buffer{}, fmt.Errorf("no buffers to interleave")
+	}
+	format := buffers[0].Format
+	size, err := sampleBytes(format.SampleFormat)
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+	frames := -1
+	for i, buf := range buffers {
+		if buf.Format.Channels != 1 {
+			return alsa.Buffer{}, fmt.Errorf("buffer %d has %d channels, only mono buffers can be interleaved", i, buf.Format.Channels)
+		}
+		if buf.Format.SampleFormat != format.SampleFormat || buf.Format.Rate != format.Rate {
+			return alsa.Buffer{}, fmt.Errorf("buffer %d is %d Hz %v, buffer 0 is %d Hz %v",
+				i, buf.Format.Rate, buf.Format.SampleFormat, format.Rate, format.SampleFormat)
+		}
+		if n := len(buf.Data) / size; frames < 0 || n < frames {
+			frames = n
+		}
+	}
+
+	channels := len(buffers)
+	data := make([]byte, frames*channels*size)
+	for f := 0; f < frames; f++ {
+		for c, buf := range buffers {
+			copy(data[(f*channels+c)*size:], buf.Data[f*size:(f+1)*size])
+		}
+	}
+	format.Channels = channels
+	return alsa.Buffer{Format: format, Data: data}, nil
+}
@@ -0,0 +1,61 @@
+package alsa
+
+import (
+	"time"
+
+	"github.com/yobert/alsa"
+)
+
+// TimestampSource says where a ReadTimestamp came from.
+type TimestampSource int
+
+const (
+	// TimestampDriver timestamps come from the driver (snd_pcm_status), taken when the period was captured.
+	TimestampDriver TimestampSource = iota
+	// TimestampWallClock timestamps are the time the read returned, backdated by the length of the chunk.
+	// They're only as accurate as the scheduling of the reading goroutine, typically within a millisecond or
+	// two but occasionally off by a lot more on a loaded machine, and include however long the data sat in the
+	// device buffer before the read.
+	TimestampWallClock
+)
+
+func (s TimestampSource) String() string {
+	if s == TimestampDriver {
+		return "driver"
+	}
+	return "wall clock"
+}
+
+// ReadTimestamp is the time the first frame of a chunk read from the device was captured.
+type ReadTimestamp struct {
+	Frame  int // Offset of the chunk in the recording.
+	Time   time.Time
+	Source TimestampSource
+}
+
+// driverTimestamp returns the driver's timestamp of the last frame of the chunk that was just read.
+// That takes the SNDRV_PCM_IOCTL_STATUS ioctl on the PCM's file descriptor, which yobert/alsa keeps to itself,
+// so for now there's never one. Once the fd is exposed (or the ioctl is added upstream) this is the only
+// thing that needs to change: read snd_pcm_status, take tstamp (or audio_tstamp if the driver sets it),
+// and backdate it by the avail frames still waiting after the chunk that was just read.
+var driverTimestamp = func(dev *alsa.Device) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// readTimestamp timestamps a read of frames frames at offset frame that returned at now.
+func readTimestamp(dev *alsa.Device, frame, frames, rate int, now time.Time) ReadTimestamp {
+	if t, ok := driverTimestamp(dev); ok {
+		return ReadTimestamp{Frame: frame, Time: t.Add(-framesToDuration(frames, rate)), Source: TimestampDriver}
+	}
+	// The read returns once the last frame arrived.
+	return ReadTimestamp{Frame: frame, Time: now.Add(-framesToDuration(frames, rate)), Source: TimestampWallClock}
+}
+
+// RecordWavTimestamped records like RecordWavWithOpts and also returns when each chunk read from the device
+// was captured, for lining the audio up with an external clock.
+// Driver timestamps are used when available, otherwise wall clock ones; check the Source of each.
+func RecordWavTimestamped(rec *alsa.Device, duration time.Duration, opts RecordOpts) (alsa.Buffer, []ReadTimestamp, error) {
+	var stamps []ReadTimestamp
+	buf, err := recordWav(rec, duration, opts, &stamps)
+	return buf, stamps, err
+}
@@ -0,0 +1,135 @@
+package alsa
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/yobert/alsa"
+)
+
+// WaveShape is the waveform PlayWave generates.
+type WaveShape int
+
+const (
+	Sine WaveShape = iota
+	Square
+	Triangle
+	// Saw ramps up and drops back at the end of every cycle.
+	Saw
+)
+
+func (w WaveShape) String() string {
+	switch w {
+	case Sine:
+		return "sine"
+	case Square:
+		return "square"
+	case Triangle:
+		return "triangle"
+	case Saw:
+		return "saw"
+	}
+	return fmt.Sprintf("WaveShape(%d)", int(w))
+}
+
+// waveValue is the value of the wave at phase (0 to 1) of its cycle, from -1 to 1.
+// Every shape starts its cycle at 0 heading up, like the sine does.
+func waveValue(shape WaveShape, phase float64) float64 {
+	switch shape {
+	case Square:
+		if phase < 0.5 {
+			return 1
+		}
+		return -1
+	case Triangle:
+		switch {
+		case phase < 0.25:
+			return 4 * phase
+		case phase < 0.75:
+			return 2 - 4*phase
+		}
+		return 4*phase - 4
+	case Saw:
+		if phase < 0.5 {
+			return 2 * phase
+		}
+		return 2*phase - 2
+	}
+	return math.Sin(2 * math.Pi * phase)
+}
+
+// PlayTone plays a sine wave of freq Hz for d on the device, at amplitude (0 to 1) of full scale.
+func PlayTone(device *alsa.Device, freq float64, d time.Duration, amplitude float64) error {
+	return PlayWave(device, Sine, freq, d, amplitude)
+}
+
+// beepDefaults set the device up the way the beep commands always have: mono if it can do it,
+// and a buffer of two short periods so the tone starts right away.
+var beepDefaults = deviceDefaults{
+	channels:     []int{1, 2},
+	rates:        []int{44100},
+	formats:      []alsa.FormatType{alsa.S16_LE, alsa.S32_LE},
+	periodFrames: defaultPeriodFrames,
+}
+
+// PlayWave plays a wave of the shape at freq Hz for d on the device, at amp (0 to 1) of full scale.
+// The device is closed once the wave has finished playing.
+func PlayWave(device *alsa.Device, shape WaveShape, freq float64, d time.Duration, amp float64) error {
+	if shape < Sine || shape > Saw {
+		return fmt.Errorf("unknown wave shape %v", shape)
+	}
+	if device.Type != alsa.PCM || !device.Play {
+		return &deviceNotPlayable{deviceName: device.Title}
+	}
+
+	params, err := prepareDevice(device, AudioConfig{}, beepDefaults)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	fmt.Printf("Negotiated parameters: %d channels, %d hz, %v, %d period size, %d buffer size\n",
+		params.Channels, params.Rate, params.Format, params.PeriodSize, params.BufferSize)
+
+	started := time.Now()
+	if err := writeWave(device.Write, params, shape, freq, d, amp); err != nil {
+		return err
+	}
+	// Closing the device drops whatever it hasn't played yet.
+	time.Sleep(time.Until(started.Add(d)))
+	fmt.Printf("Playback should be complete now.\n")
+	return nil
+}
+
+// writeWave renders the wave a period at a time in the negotiated format and hands every period to write,
+// which takes the data and the number of frames in it like alsa.Device.Write.
+func writeWave(write func(buf []byte, frames int) error, params NegotiatedParams, shape WaveShape, freq float64, d time.Duration, amp float64) error {
+	periodSize := params.PeriodSize
+	if periodSize == 0 {
+		periodSize = params.BufferSize
+	}
+	total := durationToFrames(d, params.Rate)
+	period := make([]float64, periodSize*params.Channels)
+	for frame := 0; frame < total; frame += periodSize {
+		n := total - frame
+		if n > periodSize {
+			n = periodSize
+		}
+		for i := 0; i < n; i++ {
+			cycles := freq * float64(frame+i) / float64(params.Rate)
+			v := amp * waveValue(shape, cycles-math.Floor(cycles))
+			for c := 0; c < params.Channels; c++ {
+				period[i*params.Channels+c] = v
+			}
+		}
+		data, err := encodeFloats(period[:n*params.Channels], params.Format)
+		if err != nil {
+			return err
+		}
+		if err := write(data, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
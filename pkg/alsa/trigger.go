@@ -0,0 +1,119 @@
+package alsa
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yobert/alsa"
+
+	"github.com/renan-campos/sound-utils/pkg/logging"
+)
+
+// TriggerOpts tweak when RecordOnTriggerWithOpts stops.
+type TriggerOpts struct {
+	// SilenceTimeout stops the recording once the input has stayed below the threshold this long after triggering.
+	// 0 keeps recording until ctx is done.
+	SilenceTimeout time.Duration
+}
+
+// RecordOnTrigger waits for the input to peak above threshold (in dBFS, e.g. -30) and then records until ctx is done,
+// including the preRoll of audio before the trigger so the onset isn't cut off.
+// If ctx is done before anything triggered the recording, an empty buffer is returned.
+// Fields of cfg left at their zero value use the RecordWav defaults.
+func RecordOnTrigger(ctx context.Context, rec *alsa.Device, cfg AudioConfig, threshold float64, preRoll time.Duration) (alsa.Buffer, error) {
+	return RecordOnTriggerWithOpts(ctx, rec, cfg, threshold, preRoll, TriggerOpts{})
+}
+
+func RecordOnTriggerWithOpts(ctx context.Context, rec *alsa.Device, cfg AudioConfig, threshold float64, preRoll time.Duration, opts TriggerOpts) (alsa.Buffer, error) {
+	params, err := prepareDevice(rec, cfg, deviceDefaults{
+		channels:     []int{2},
+		rates:        []int{44100},
+		formats:      []alsa.FormatType{alsa.S16_LE, alsa.S32_LE},
+		bufferFrames: []int{8192, 16384},
+	})
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+	defer rec.Close()
+
+	format := rec.BufferFormat()
+	format.SampleFormat = params.Format
+	trigger := newTriggerRecorder(format, threshold, durationToFrames(preRoll, params.Rate),
+		durationToFrames(opts.SilenceTimeout, params.Rate))
+
+	fmt.Printf("Waiting for the input to go above %.1f dBFS...\n", threshold)
+	chunk := make([]byte, params.BufferSize*rec.BytesPerFrame())
+	for ctx.Err() == nil {
+		if err := deviceError(rec.Read(chunk)); err != nil {
+			if !isOverrun(err) {
+				return alsa.Buffer{}, err
+			}
+			logging.Debugf("Capture overrun, recovering")
+			if err := rec.Prepare(); err != nil {
+				return alsa.Buffer{}, errors.Wrap(err, "failed to recover from overrun")
+			}
+			continue
+		}
+		wasTriggered := trigger.triggered
+		if trigger.add(chunk) {
+			fmt.Println("Input went quiet, recording stopped.")
+			return trigger.recording(), nil
+		}
+		if trigger.triggered && !wasTriggered {
+			fmt.Println("Triggered, recording...")
+		}
+	}
+	fmt.Println("Recording stopped.")
+	return trigger.recording(), nil
+}
+
+// triggerRecorder keeps the last preRoll frames until a chunk peaks above the threshold,
+// then keeps everything until silenceFrames frames in a row stay below it.
+type triggerRecorder struct {
+	format        alsa.BufferFormat
+	threshold     float64
+	silenceFrames int
+	preRoll       *loudestWindow // only its ring is used
+	triggered     bool
+	data          []byte
+	quietFrames   int
+}
+
+func newTriggerRecorder(format alsa.BufferFormat, threshold float64, preRollFrames, silenceFrames int) *triggerRecorder {
+	t := &triggerRecorder{format: format, threshold: threshold, silenceFrames: silenceFrames}
+	if preRollFrames > 0 {
+		t.preRoll = newLoudestWindow(format, preRollFrames)
+	}
+	return t
+}
+
+// add takes the next chunk, returning true once the input has been quiet for long enough after triggering.
+func (t *triggerRecorder) add(chunk []byte) bool {
+	loud := SamplePeak(alsa.Buffer{Format: t.format, Data: chunk}) > t.threshold
+	if !t.triggered {
+		if !loud {
+			if t.preRoll != nil {
+				t.preRoll.add(chunk)
+			}
+			return false
+		}
+		t.triggered = true
+		if t.preRoll != nil {
+			t.data = t.preRoll.window()
+		}
+	}
+	t.data = append(t.data, chunk...)
+
+	if loud {
+		t.quietFrames = 0
+		return false
+	}
+	t.quietFrames += len(chunk) / bytesPerFrame(t.format)
+	return t.silenceFrames > 0 && t.quietFrames >= t.silenceFrames
+}
+
+func (t *triggerRecorder) recording() alsa.Buffer {
+	return alsa.Buffer{Format: t.format, Data: t.data}
+}
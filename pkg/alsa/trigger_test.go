@@ -0,0 +1,72 @@
+package alsa
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/yobert/alsa"
+)
+
+// monoChunk is a chunk of mono S16_LE where sample i is value(i).
+func monoChunk(frames int, value func(i int) int) []byte {
+	chunk := make([]byte, 2*frames)
+	for i := 0; i < frames; i++ {
+		binary.LittleEndian.PutUint16(chunk[2*i:], uint16(value(i)))
+	}
+	return chunk
+}
+
+func TestTriggerRecorder(t *testing.T) {
+	format := alsa.BufferFormat{SampleFormat: alsa.S16_LE, Rate: 8000, Channels: 1}
+	const chunkFrames, preRoll, silence = 100, 250, 300
+	trigger := newTriggerRecorder(format, -30, preRoll, silence)
+
+	// Quiet input counting frames, it stays below -36dBFS.
+	frame := 0
+	for i := 0; i < 5; i++ {
+		if trigger.add(monoChunk(chunkFrames, func(int) int { frame++; return frame - 1 })) || trigger.triggered {
+			t.Fatalf("triggered by quiet chunk %d", i)
+		}
+	}
+	if trigger.add(monoChunk(chunkFrames, func(int) int { return 10000 })) || !trigger.triggered {
+		t.Fatal("a loud chunk didn't trigger the recording")
+	}
+	quiet := 0
+	for ; quiet < 10; quiet++ {
+		if trigger.add(monoChunk(chunkFrames, func(int) int { return 1 })) {
+			break
+		}
+	}
+	if quiet+1 != silence/chunkFrames {
+		t.Errorf("stopped after %d quiet chunks, want %d", quiet+1, silence/chunkFrames)
+	}
+
+	rec := trigger.recording()
+	if got, want := len(rec.Data)/2, preRoll+chunkFrames+silence; got != want {
+		t.Fatalf("recorded %d frames, want %d", got, want)
+	}
+	// The pre-roll is the end of the quiet input, in order.
+	for i := 0; i < preRoll; i++ {
+		if got, want := int(int16(binary.LittleEndian.Uint16(rec.Data[2*i:]))), frame-preRoll+i; got != want {
+			t.Fatalf("pre-roll frame %d is %d, want %d", i, got, want)
+		}
+	}
+	if got := int16(binary.LittleEndian.Uint16(rec.Data[2*preRoll:])); got != 10000 {
+		t.Errorf("the trigger chunk starts with %d, want 10000", got)
+	}
+}
+
+// Without a silence timeout, the recording goes on until it's stopped.
+func TestTriggerRecorderNoTimeout(t *testing.T) {
+	format := alsa.BufferFormat{SampleFormat: alsa.S16_LE, Rate: 8000, Channels: 1}
+	trigger := newTriggerRecorder(format, -30, 0, 0)
+	trigger.add(monoChunk(100, func(int) int { return 10000 }))
+	for i := 0; i < 100; i++ {
+		if trigger.add(monoChunk(100, func(int) int { return 0 })) {
+			t.Fatal("stopped without a silence timeout")
+		}
+	}
+	if got := len(trigger.recording().Data) / 2; got != 10100 {
+		t.Errorf("recorded %d frames, want 10100", got)
+	}
+}
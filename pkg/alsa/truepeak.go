@@ -0,0 +1,137 @@
+package alsa
+
+import (
+	"math"
+	"time"
+
+	"github.com/yobert/alsa"
+)
+
+// Oversampling of the true peak meter, and the half length of its interpolation filter in input samples.
+const (
+	truePeakOversampling = 4
+	truePeakTaps         = 12
+)
+
+// SamplePeak returns the level of the loudest sample of the recording in dBFS.
+// Silent or unconvertible recordings return -Inf.
+func SamplePeak(buf alsa.Buffer) float64 {
+	samples, err := decodeFloats(buf)
+	if err != nil {
+		return math.Inf(-1)
+	}
+	_, peak := level(samples)
+	return gainToDB(peak)
+}
+
+// TruePeak returns the highest level the recording reaches once it's converted back to analog, in dBTP.
+// A DAC draws a smooth curve through the samples, and between two samples close to full scale
+// that curve can overshoot both of them, so the true peak can be above the highest sample and clip
+// even though no sample does. The recording is oversampled 4 times, as BS.1770 suggests, to find those peaks.
+// Silent or unconvertible recordings return -Inf.
+func TruePeak(buf alsa.Buffer) float64 {
+	channels := buf.Format.Channels
+	samples, err := decodeFloats(buf)
+	if err != nil || channels < 1 {
+		return math.Inf(-1)
+	}
+	var peak float64
+	for _, p := range truePeaks(samples, channels) {
+		peak = math.Max(peak, p)
+	}
+	return gainToDB(peak)
+}
+
+// truePeaks returns the true peak of every frame as a fraction of full scale:
+// the highest level any channel reaches at the sample or on the way to the next one.
+func truePeaks(samples []float64, channels int) []float64 {
+	frames := len(samples) / channels
+	filter := truePeakFilter()
+
+	peaks := make([]float64, frames)
+	for c := 0; c < channels; c++ {
+		at := func(i int) float64 {
+			if i < 0 || i >= frames {
+				return 0
+			}
+			return samples[i*channels+c]
+		}
+		for n := 0; n < frames; n++ {
+			peaks[n] = math.Max(peaks[n], math.Abs(at(n)))
+			// The points between sample n and n+1.
+			for phase := 1; phase < truePeakOversampling; phase++ {
+				var v float64
+				for j := -truePeakTaps + 1; j <= truePeakTaps; j++ {
+					v += at(n+j) * filter[phase][j+truePeakTaps-1]
+				}
+				peaks[n] = math.Max(peaks[n], math.Abs(v))
+			}
+		}
+	}
+	return peaks
+}
+
+// limitTruePeak turns the samples down wherever their true peak goes over ceiling (in dBTP), all channels together.
+// The gain ramps down over attack before a peak, so it's fully down for every sample the interpolation
+// around the peak draws on, and comes back up over release.
+// Turning the gain down changes the curve between samples a little, so it goes over the samples again
+// until nothing is left over the ceiling.
+func limitTruePeak(samples []float64, channels, rate int, ceilingDB float64, attack, release time.Duration) {
+	ceiling := dbToGain(ceilingDB)
+	attackCoef := envelopeCoefficient(attack, rate)
+	releaseCoef := envelopeCoefficient(release, rate)
+	for pass := 0; pass < 8; pass++ {
+		peaks := truePeaks(samples, channels)
+		over := false
+		need := make([]float64, len(peaks))
+		for f := range need {
+			need[f] = 1
+			// Every sample within the reach of the filter of an overshooting point.
+			for j := f - truePeakTaps; j <= f+truePeakTaps; j++ {
+				if j >= 0 && j < len(peaks) && peaks[j] > ceiling {
+					need[f] = math.Min(need[f], ceiling/peaks[j])
+					over = true
+				}
+			}
+		}
+		if !over {
+			return
+		}
+
+		gains := make([]float64, len(need))
+		gain := 1.0
+		for f := len(need) - 1; f >= 0; f-- {
+			gain = math.Min(need[f], attackCoef*gain+(1-attackCoef))
+			gains[f] = gain
+		}
+		gain = 1
+		for f := range gains {
+			gain = math.Min(gains[f], releaseCoef*gain+(1-releaseCoef))
+			for c := 0; c < channels; c++ {
+				samples[f*channels+c] *= gain
+			}
+		}
+	}
+}
+
+// truePeakFilter returns the interpolation coefficients for each phase: a Hann windowed sinc
+// evaluated at the offsets of the 2*truePeakTaps samples around the interpolated point.
+func truePeakFilter() [truePeakOversampling][2 * truePeakTaps]float64 {
+	var filter [truePeakOversampling][2 * truePeakTaps]float64
+	for phase := 1; phase < truePeakOversampling; phase++ {
+		frac := float64(phase) / truePeakOversampling
+		for j := -truePeakTaps + 1; j <= truePeakTaps; j++ {
+			t := frac - float64(j)
+			window := 0.5 + 0.5*math.Cos(math.Pi*t/truePeakTaps)
+			filter[phase][j+truePeakTaps-1] = sinc(t) * window
+		}
+	}
+	return filter
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
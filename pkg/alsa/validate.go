@@ -0,0 +1,188 @@
+package alsa
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// WavReport compares the sizes the header of a WAV file declares with what's really in the file.
+type WavReport struct {
+	// DataOffset is where the audio starts, past the data chunk header.
+	DataOffset int64
+	// DeclaredDataSize is the size in the data chunk header, ActualDataSize the whole frames of audio the file holds.
+	// They differ when a recording was cut short, or crashed before the encoder wrote the real size.
+	DeclaredDataSize int64
+	ActualDataSize   int64
+	// DeclaredRIFFSize is the size in the RIFF header, ActualRIFFSize what the length of the file calls for.
+	// RF64 files keep their real sizes in the ds64 chunk, so theirs aren't compared.
+	DeclaredRIFFSize int64
+	ActualRIFFSize   int64
+	RF64             bool
+	// ChunksAfterData is set when real chunks follow the data chunk. Their sizes pin down where the data ends,
+	// so ActualDataSize is the declared size, and RepairWav leaves such files alone.
+	ChunksAfterData bool
+}
+
+// Valid reports whether the header sizes match the file.
+func (r WavReport) Valid() bool {
+	return r.DeclaredDataSize == r.ActualDataSize && (r.RF64 || r.DeclaredRIFFSize == r.ActualRIFFSize)
+}
+
+// Err returns a *DataSizeMismatch when the data chunk doesn't hold what it declares, nil otherwise.
+// There's no duration to go by, so its Expected is -1.
+func (r WavReport) Err() error {
+	if r.DeclaredDataSize == r.ActualDataSize {
+		return nil
+	}
+	return &DataSizeMismatch{Declared: r.DeclaredDataSize, Expected: -1, Actual: r.ActualDataSize}
+}
+
+// ValidateWav checks the sizes in the header of a WAV file against the file itself.
+// A mismatch isn't an error, it's in the report; errors are for files that can't be read as WAV at all.
+func ValidateWav(path string) (WavReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return WavReport{}, errors.Wrapf(err, "failed to open %q", path)
+	}
+	defer f.Close()
+	return validateWav(f, path)
+}
+
+func validateWav(f *os.File, path string) (WavReport, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return WavReport{}, err
+	}
+	chunks, walkErr := walkChunks(f)
+	fmtChunk, ok := findChunk(chunks, "fmt ")
+	if !ok {
+		if walkErr != nil {
+			return WavReport{}, errors.Wrapf(walkErr, "no fmt chunk found in %q", path)
+		}
+		return WavReport{}, fmt.Errorf("%q has no fmt chunk", path)
+	}
+	header, err := readFmtChunk(f, fmtChunk)
+	if err != nil {
+		return WavReport{}, err
+	}
+	if header.blockAlign == 0 {
+		return WavReport{}, fmt.Errorf("%q has a block align of 0", path)
+	}
+	data, ok := findChunk(chunks, "data")
+	if !ok {
+		return WavReport{}, fmt.Errorf("%q has no data chunk", path)
+	}
+
+	riff := make([]byte, riffHeaderSize)
+	if _, err := f.ReadAt(riff, 0); err != nil {
+		return WavReport{}, err
+	}
+	report := WavReport{
+		DataOffset:       data.offset,
+		DeclaredDataSize: data.size,
+		ActualDataSize:   data.size,
+		DeclaredRIFFSize: int64(binary.LittleEndian.Uint32(riff[4:8])),
+		ActualRIFFSize:   info.Size() - 8,
+		RF64:             string(riff[0:4]) != "RIFF",
+	}
+
+	// When other chunks follow, the data chunk must have ended where the next one starts.
+	// When it's the last one, the audio is whatever is left of the file, less the pad byte and any partial frame.
+	// A size that's too small (a crashed recording leaves 0) makes the walk carry on into the audio,
+	// which shows up as chunks with garbage ids or a walk that fails.
+	if chunks[len(chunks)-1].id != "data" && chunksAfterAreValid(chunks, walkErr) {
+		report.ChunksAfterData = true
+	} else {
+		remaining := info.Size() - data.offset
+		if data.size%2 == 1 && remaining == data.size+1 {
+			remaining = data.size
+		}
+		report.ActualDataSize = remaining - remaining%int64(header.blockAlign)
+	}
+	return report, nil
+}
+
+// chunksAfterAreValid reports whether the chunks after the data chunk look like real chunks.
+func chunksAfterAreValid(chunks []riffChunk, walkErr error) bool {
+	if walkErr != nil {
+		return false
+	}
+	seen := false
+	for _, c := range chunks {
+		if seen && !isFourCC(c.id) {
+			return false
+		}
+		seen = seen || c.id == "data"
+	}
+	return true
+}
+
+// isFourCC reports whether a chunk id is the printable ASCII chunk ids are made of.
+func isFourCC(id string) bool {
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x20 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// RepairWav rewrites the header sizes of a WAV file to match the audio really in it, so players and editors
+// stop choking on a file from a recording that was cut short. A partial frame at the end is dropped.
+// Only the data chunk being the last one can be repaired, which is the case for anything this package records;
+// files with chunks after it are refused.
+func RepairWav(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %q", path)
+	}
+	defer f.Close()
+
+	report, err := validateWav(f, path)
+	if err != nil {
+		return err
+	}
+	if report.Valid() {
+		return nil
+	}
+	if report.RF64 {
+		return fmt.Errorf("%q is RF64, repairing it isn't supported", path)
+	}
+	if report.ChunksAfterData {
+		return fmt.Errorf("%q has chunks after the data chunk, repairing it isn't supported", path)
+	}
+
+	size := report.ActualDataSize
+	end := report.DataOffset + size + size%2
+	if end-8 > math.MaxUint32 {
+		return fmt.Errorf("%q holds too much audio for a WAV file (%d bytes)", path, size)
+	}
+	if report.DeclaredDataSize != size {
+		// Drop the partial frame, or add the pad byte an odd sized chunk needs.
+		if err := f.Truncate(end); err != nil {
+			return errors.Wrapf(err, "failed to resize %q", path)
+		}
+		if err := writeUint32At(f, report.DataOffset-4, uint32(size)); err != nil {
+			return err
+		}
+	} else {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		end = info.Size()
+	}
+	return writeUint32At(f, 4, uint32(end-8))
+}
+
+func writeUint32At(w io.WriterAt, offset int64, v uint32) error {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	_, err := w.WriteAt(b, offset)
+	return err
+}
@@ -0,0 +1,94 @@
+package alsa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// cutShort returns a WAV file of 400 bytes of 16-bit stereo audio whose header declares dataSize bytes
+// of audio and a RIFF size of riffSize, like a recording that crashed before finishing its header.
+// extra bytes of a partial frame follow the audio.
+func cutShort(t *testing.T, dataSize, riffSize uint32, extra int) (string, []byte) {
+	t.Helper()
+	audio := make([]byte, 400+extra)
+	for i := range audio {
+		audio[i] = byte(i)
+	}
+	data := wavBytes(pcmFmt(2, 8000, 16), testChunk{"data", audio})
+	data = data[:len(data)-len(audio)%2] // no pad byte, the recording stopped mid-frame
+	binary.LittleEndian.PutUint32(data[4:], riffSize)
+	binary.LittleEndian.PutUint32(data[len(data)-len(audio)-4:], dataSize)
+	return writeTemp(t, "cut.wav", data), audio[:400]
+}
+
+func TestValidateWav(t *testing.T) {
+	tests := []struct {
+		name               string
+		dataSize, riffSize uint32
+		extra              int
+	}{
+		{"data size too big", 100000, 100036, 0},
+		{"zero sizes", 0, 0, 0},
+		{"partial frame", 403, 439, 3},
+		{"only the RIFF size", 400, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, audio := cutShort(t, tt.dataSize, tt.riffSize, tt.extra)
+			report, err := ValidateWav(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Valid() {
+				t.Fatalf("corrupted header passed: %+v", report)
+			}
+			if report.DeclaredDataSize != int64(tt.dataSize) || report.ActualDataSize != 400 {
+				t.Errorf("got declared %d and actual %d data bytes, want %d and 400",
+					report.DeclaredDataSize, report.ActualDataSize, tt.dataSize)
+			}
+			var mismatch *DataSizeMismatch
+			if err := report.Err(); tt.dataSize == 400 {
+				if err != nil {
+					t.Errorf("data size mismatch %v with the right data size", err)
+				}
+			} else if !errors.As(err, &mismatch) || mismatch.Declared != int64(tt.dataSize) || mismatch.Actual != 400 || mismatch.Expected != -1 {
+				t.Errorf("got %v, want a DataSizeMismatch", err)
+			}
+
+			if err := RepairWav(file); err != nil {
+				t.Fatal(err)
+			}
+			if report, err := ValidateWav(file); err != nil || !report.Valid() {
+				t.Fatalf("repaired file isn't valid: %+v, %v", report, err)
+			}
+			loaded, err := loadWav(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(loaded.Data, audio) {
+				t.Errorf("repaired file holds %d bytes of audio, want the 400 recorded", len(loaded.Data))
+			}
+		})
+	}
+}
+
+func TestRepairWavRefusesChunksAfterData(t *testing.T) {
+	data := wavBytes(pcmFmt(2, 8000, 16), testChunk{"data", make([]byte, 400)}, testChunk{"LIST", []byte("INFO")})
+	binary.LittleEndian.PutUint32(data[4:], 0)
+	file := writeTemp(t, "trailing.wav", data)
+	report, err := ValidateWav(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid() || !report.ChunksAfterData {
+		t.Fatalf("got report %+v, want an invalid RIFF size with chunks after the data", report)
+	}
+	if err := RepairWav(file); err == nil {
+		t.Error("repaired a file with chunks after the data")
+	}
+	if !bytes.Equal(readFile(t, file), data) {
+		t.Error("the refused repair changed the file")
+	}
+}
@@ -5,8 +5,13 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"sort"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-audio/audio"
@@ -17,32 +22,139 @@ import (
 	"github.com/renan-campos/sound-utils/pkg/logging"
 )
 
+// PlayOpts tweak how PlayWavWithOpts sets up the device.
+type PlayOpts struct {
+	// NativeFormat negotiates the sample format matching the bit depth of the file first,
+	// so a 16-bit file plays bit-perfect on a device that accepts S16_LE.
+	// If the device rejects it, the usual S32_LE, S16_LE preference is used.
+	// Ignored when Config lists its own formats.
+	NativeFormat bool
+	Config       AudioConfig
+	// Resilient keeps playing past parts of the file that can't be read: the damaged period is
+	// skipped and played as silence. PlayWavResilient returns how much was skipped.
+	Resilient bool
+	// Downmix mixes files with more channels than the device down to the device's channel count.
+	// There's a row for every output channel holding the weight of each of the file's channels,
+	// in the order they're stored in the file.
+	// When nil, 3.0, 4.0, 5.0, 5.1 and 7.1 files use the ITU-R BS.775 downmix to stereo or mono
+	// (center and surrounds at -3dB, LFE dropped). Other files need a Downmix to play on fewer channels.
+	Downmix [][]float64
+	// Paced holds every write back until the audio before it would have finished playing,
+	// so devices that accept data faster than real time (null or mock devices) take as long as hardware would.
+	Paced bool
+	// ChannelGains multiplies the samples of each channel played on the device by its own gain,
+	// clamping rather than wrapping when that overflows. A single gain applies to every channel.
+	ChannelGains []float64
+	// Control pauses and resumes the playback. Pausing can go on indefinitely,
+	// so the device is no longer closed automatically a few seconds after the file should have ended.
+	Control *PlaybackControl
+}
+
 func PlayWav(device *alsa.Device, wavFileName string) error {
+	return PlayWavContext(context.Background(), device, wavFileName)
+}
+
+// PlayWavContext plays the file until it ends or ctx is done, whichever comes first.
+// Stopping early closes the device and returns ctx.Err(); what was already sent to the device still plays out.
+func PlayWavContext(ctx context.Context, device *alsa.Device, wavFileName string) error {
+	_, err := playWav(ctx, device, wavFileName, PlayOpts{}, 1)
+	return err
+}
+
+func PlayWavWithOpts(device *alsa.Device, wavFileName string, opts PlayOpts) error {
+	_, err := playWav(context.Background(), device, wavFileName, opts, 1)
+	return err
+}
+
+// PlayWavResilient plays the file like PlayWavWithOpts with opts.Resilient set,
+// returning how much of it couldn't be read and was played as silence.
+func PlayWavResilient(device *alsa.Device, wavFileName string, opts PlayOpts) (time.Duration, error) {
+	opts.Resilient = true
+	return playWav(context.Background(), device, wavFileName, opts, 1)
+}
+
+// PlayWavWithVolume plays the file with the samples of every channel scaled by its gain in channelGains,
+// or all of them by the same gain if there's only one.
+func PlayWavWithVolume(device *alsa.Device, wavFileName string, channelGains []float64) error {
+	return PlayWavWithOpts(device, wavFileName, PlayOpts{ChannelGains: channelGains})
+}
+
+// PlayWavLoop plays the file repeats times back to back without reopening the device, so there's no gap between them.
+// With repeats of 0 or less it loops until playback fails.
+func PlayWavLoop(device *alsa.Device, wavFileName string, repeats int) error {
+	_, err := playWav(context.Background(), device, wavFileName, PlayOpts{}, repeats)
+	return err
+}
+
+// playbackDevice is the part of a device playWav needs.
+type playbackDevice interface {
+	negotiator
+	Write(buf []byte, frames int) error
+}
+
+// playWav plays the file repeats times, or forever if repeats is 0 or less.
+// It returns how much of the file was skipped as unreadable, which is only ever more than 0 with opts.Resilient.
+func playWav(ctx context.Context, device playbackDevice, wavFileName string, opts PlayOpts, repeats int) (time.Duration, error) {
 	var err error
 
 	f, err := os.Open(wavFileName)
 	if err != nil {
-		return errors.Wrapf(err, "failed to open %q", wavFileName)
-	}
-	wavDecoder := wav.NewDecoder(f)
-	if !wavDecoder.IsValidFile() {
-		return fmt.Errorf("%q is not a valid wav file", wavFileName)
+		return 0, errors.Wrapf(err, "failed to open %q", wavFileName)
 	}
-
-	if err = device.Open(); err != nil {
-		return err
+	defer f.Close()
+	channelMask := readChannelMask(f)
+	// The go-audio decoder doesn't know RF64, so read the samples straight from the data chunk.
+	pcm, err := newPCMReader(f)
+	if err != nil {
+		return 0, errors.Wrapf(err, "%q is not a valid wav file", wavFileName)
 	}
-
-	dur, err := wavDecoder.Duration()
+	dur := pcm.duration()
+	bitDepth := pcm.bitDepth()
+	srcRate := int(pcm.format.sampleRate)
+
+	params, err := prepareDevice(device, opts.Config, deviceDefaults{
+		// Note:
+		// When playing a wav file:
+		// The number of channels should be what the file specifies.
+		channels: []int{pcm.channels(), 2},
+
+		// Note:
+		// When playing a wav file:
+		// The sample rate should be greater than or equal to what the file specifies,
+		// anything else gets resampled.
+		rates: playbackRates(srcRate),
+
+		// Note:
+		// When playing a wav file:
+		// The format should be what the wav format will be.
+		// In the case of wav, the codec library will have int.
+		// But the ratio between sample rate and bytes per second
+		// of the file I was reading was 1 byte per sample.
+		// This means that the data format will be S8_LE (assuming little endian)
+		// If this is the case, the data should be set to it or higher,
+		// and the buffer data needs to adapt to what it was set to.
+		formats: playbackFormats(bitDepth, opts.NativeFormat),
+
+		periodFrames: defaultPeriodFrames,
+	})
 	if err != nil {
-		return errors.Wrapf(err, "failed to determine duration of %q", wavFileName)
+		return 0, err
 	}
+	channels, rate, format := params.Channels, params.Rate, params.Format
+	periodSize, bufferSize := params.PeriodSize, params.BufferSize
 
 	// Cleanup device when done or force cleanup 3 seconds after the duration of the wav file.
 	wg := sync.WaitGroup{}
 	wg.Add(1)
 	defer wg.Wait()
-	childCtx, cancel := context.WithDeadline(context.Background(), time.Now().Add(dur).Add(3*time.Second))
+	// Playback that can pause or loops forever has no deadline.
+	var childCtx context.Context
+	var cancel context.CancelFunc
+	if opts.Control != nil || repeats <= 0 {
+		childCtx, cancel = context.WithCancel(context.Background())
+	} else {
+		childCtx, cancel = context.WithDeadline(context.Background(), time.Now().Add(time.Duration(repeats)*dur).Add(3*time.Second))
+	}
 	defer cancel()
 	go func(ctx context.Context) {
 		defer device.Close()
@@ -51,269 +163,778 @@ func PlayWav(device *alsa.Device, wavFileName string) error {
 		wg.Done()
 	}(childCtx)
 
-	wavFormat := wavDecoder.Format()
-	// Note:
-	// When playing a wav file:
-	// The number of channels should be what the file specifies.
-	channels, err := device.NegotiateChannels(wavFormat.NumChannels, 2)
-	if err != nil {
-		return err
-	}
-
-	// Note:
-	// When playing a wav file:
-	// The sample rate should be that or higher than what the file specifieds.
-	// The sample rate should be greater than or equal to what the file specifies.
-	// Only supporting outputs of 44.1 kHz, as these are the only outputs I have!
-	rate, err := device.NegotiateRate(44100)
-	if err != nil {
-		return err
-	}
+	logging.Debugf("Negotiated parameters: %d channels, %d hz, %v, %d period size, %d buffer size",
+		channels, rate, format, periodSize, bufferSize)
 
-	// Note:
-	// When playing a wav file:
-	// The format should be what the wav format will be.
-	// In the case of wav, the codec library will have int.
-	// But the ratio between sample rate and bytes per second
-	// of the file I was reading was 1 byte per sample.
-	// This means that the data format will be S8_LE (assuming little endian)
-	// If this is the case, the data should be set to it or higher,
-	// and the buffer data needs to adapt to what it was set to.
-	format, err := device.NegotiateFormat(alsa.S32_LE, alsa.S16_LE)
-	if err != nil {
-		return err
+	// Multichannel files say which speaker each channel belongs to,
+	// which doesn't necessarily match the order ALSA expects.
+	var routing []int
+	if pcm.channels() == channels {
+		routing = channelRouting(channelMask, pcm.channels(), channels)
 	}
 
-	// A 50ms period is a sensible value to test low-ish latency.
-	// We adjust the buffer so it's of minimal size (period * 2) since it appear ALSA won't
-	// start playback until the buffer has been filled to a certain degree and the automatic
-	// buffer size can be quite large.
-	// Some devices only accept even periods while others want powers of 2.
-	wantPeriodSize := 2048 // 46ms @ 44100Hz
-
-	periodSize, err := device.NegotiatePeriodSize(wantPeriodSize)
-	if err != nil {
-		return err
+	gains := opts.ChannelGains
+	if len(gains) == 1 {
+		gains = make([]float64, channels)
+		for c := range gains {
+			gains[c] = opts.ChannelGains[0]
+		}
 	}
-
-	bufferSize, err := device.NegotiateBufferSize(2 * periodSize * channels)
-	if err != nil {
-		return err
+	if gains != nil && len(gains) != channels {
+		return 0, fmt.Errorf("%d channel gains for %d channels", len(gains), channels)
 	}
 
-	if err = device.Prepare(); err != nil {
-		return err
+	srcChannels := pcm.channels()
+	var matrix [][]float64
+	if srcChannels > channels {
+		matrix = opts.Downmix
+		if matrix == nil {
+			matrix = defaultDownmix(srcChannels, channels)
+		}
+		if matrix == nil {
+			return 0, fmt.Errorf("no standard downmix of a %d channel file to %d channels, set PlayOpts.Downmix",
+				srcChannels, channels)
+		}
+		if !downmixFits(matrix, srcChannels, channels) {
+			return 0, fmt.Errorf("downmix needs %d rows of %d weights for a %d channel file on %d channels",
+				channels, srcChannels, srcChannels, channels)
+		}
 	}
 
-	logging.Debugf("Negotiated parameters: %d channels, %d hz, %v, %d period size, %d buffer size\n",
-		channels, rate, format, periodSize, bufferSize)
-
-	inbuf := audio.IntBuffer{
-		Format: wavFormat,
-		Data:   make([]int, int(float64(periodSize)*float64(wavFormat.NumChannels)*float64(wavFormat.SampleRate)/float64(rate))),
-	}
+	inbuf := make([]int, int(float64(periodSize)*float64(pcm.channels())*float64(srcRate)/float64(rate)))
 
-	for !wavDecoder.EOF() {
-		nSamples, err := wavDecoder.PCMBuffer(&inbuf)
-		if err != nil {
-			return errors.Wrap(err, "failed to fill buffer with wav data")
-		}
-		if nSamples == 0 {
-			break
+	started := time.Now()
+	var framesWritten, skipped, failures int
+	var passes, passFrames int
+	// nextPass starts the file over when there are repeats to go, reporting whether it did.
+	// A file without any audio isn't repeated, that would never end.
+	nextPass := func() (bool, error) {
+		if passes++; (repeats > 0 && passes >= repeats) || passFrames == 0 {
+			return false, nil
 		}
-
+		passFrames = 0
+		return true, pcm.rewind()
+	}
+	// writeSamples converts samples of the file's bit depth, on srcChannels, to the device's format and channels and plays them.
+	writeSamples := func(samples []int) error {
 		frames := bytes.Buffer{}
-		for i, sample := range inbuf.Data {
+		var out int // output channel of the next sample
+		for i, sample := range samples {
 			var copies int
 			switch {
-			case wavFormat.NumChannels < channels:
+			case srcChannels < channels:
 				// Wav file is mono, output is stereo
 				// Double the samples written out the the buffer.
 				copies = 2
-			case wavFormat.NumChannels == channels:
+			case srcChannels == channels:
 				// Wav file and output have the same number of channels
 				copies = 1
-			case wavFormat.NumChannels > channels:
+			case srcChannels > channels:
 				// Wav file is stereo, output is mono
 				// In this case... skip every odd sample!
 				if i%2 == 0 {
 					continue
 				}
 			}
-			if wavFormat.SampleRate == rate/2 {
-				// Duplicate this sample as the next sample.
-				copies *= 2
-			}
 			for ; copies > 0; copies-- {
-				switch format {
-				case alsa.S16_LE:
-					// If the wav format is 32_LE, the PCM value must be converted to 16_LE.
-					// The simplest way is to rightshift 16 bits.
-					// However, could there be a smoother way?
-					// Yes! With bit coefficients! I'll do this later.
-					var err error
-					switch wavDecoder.BitDepth {
-					case 32:
-						err = binary.Write(&frames, binary.LittleEndian, int16(scale32To16(sample)))
-					case 16:
-						err = binary.Write(&frames, binary.LittleEndian, int16(sample))
-					case 8:
-						err = binary.Write(&frames, binary.LittleEndian, int16(scale8To16(sample)))
-					default:
-						return fmt.Errorf("Can't play this yet")
-					}
-
-					if err != nil {
-						fmt.Println(err)
-					}
-				case alsa.S32_LE:
-					switch wavDecoder.BitDepth {
-					case 32:
-						if err := binary.Write(&frames, binary.LittleEndian, int32(sample)); err != nil {
-							fmt.Println(err)
-						}
-					case 16:
-						// If the wav format is 16_LE, the PCM value must be converted to int32
-						// The simplest way would be to leftshift it 16 bits.
-						// However, could the be a smoother way?
-						// There sure is pal.
-						if err := binary.Write(&frames, binary.LittleEndian, int32(scale16To32(sample))); err != nil {
-							fmt.Println(err)
-						}
-					case 8:
-						if err := binary.Write(&frames, binary.LittleEndian, int32(scale8To32(sample))); err != nil {
-							fmt.Println(err)
-						}
-					}
-
-					// TODO What about when the number of channels arent the same?
-					// If the wav file is mono but the speakers are stereo, just double the samples.
-					// TODO What about when the sample frequency isn't the same?
-					// When the sample size of the wav file is half of that of the speaker
-					// 22050Hz vs 44100Hz
-					// There are less samples than what is played.
-					// We could duplicate the samples.
-				default:
-					return fmt.Errorf("Unhandled sample format: %v", format)
+				v := sample
+				if gains != nil {
+					v = gainSample(sample, gains[out%channels], bitDepth)
+				}
+				out++
+				if err := writePlaybackSample(&frames, v, bitDepth, format); err != nil {
+					return err
 				}
 			}
 		}
 
-		if err := device.Write(frames.Bytes(), periodSize); err != nil {
+		// Resampling and the end of the file both make for periods of other sizes.
+		// Downsampling can even leave a chunk without a whole frame, and the device can't take an empty write.
+		nFrames := frames.Len() / (channels * SampleSize(format))
+		if nFrames == 0 {
+			return nil
+		}
+		if err := device.Write(frames.Bytes(), nFrames); err != nil {
 			return err
 		}
+		if opts.Paced {
+			framesWritten += nFrames
+			played := time.Duration(framesWritten) * time.Second / time.Duration(rate)
+			time.Sleep(time.Until(started.Add(played)))
+		}
+		return nil
+	}
+	var silence []byte
+	var resampler *streamResampler
+	for {
+		if err := ctx.Err(); err != nil {
+			fmt.Printf("Playback stopped.\n")
+			return 0, err
+		}
+		if opts.Control != nil && opts.Control.Paused() {
+			if silence == nil {
+				silence = make([]byte, periodSize*channels*SampleSize(format))
+			}
+			if err := device.Write(silence, periodSize); err != nil {
+				return 0, err
+			}
+			if opts.Paced {
+				framesWritten += periodSize
+				played := time.Duration(framesWritten) * time.Second / time.Duration(rate)
+				time.Sleep(time.Until(started.Add(played)))
+			}
+			continue
+		}
+
+		nSamples, err := pcm.read(inbuf)
+		if err != nil {
+			if !opts.Resilient {
+				return 0, errors.Wrap(err, "failed to fill buffer with wav data")
+			}
+			// Give up on files that are damaged all the way through.
+			if failures++; failures > maxConsecutiveReadFailures {
+				return 0, errors.Wrapf(err, "failed to read %d periods in a row", failures)
+			}
+			logging.Debugf("Failed to read wav data, skipping a period: %v", err)
+			periodBytes := int64(len(inbuf) * bitDepth / 8)
+			if err := pcm.skip(periodBytes); err == io.EOF {
+				again, err := nextPass()
+				if err != nil {
+					return 0, err
+				}
+				if again {
+					continue
+				}
+				break
+			}
+			for i := range inbuf {
+				inbuf[i] = 0
+			}
+			nSamples = len(inbuf)
+			skipped += nSamples / pcm.channels()
+		} else {
+			failures = 0
+		}
+		if nSamples == 0 {
+			again, err := nextPass()
+			if err != nil {
+				return 0, err
+			}
+			if again {
+				continue
+			}
+			break
+		}
+		passFrames += nSamples / pcm.channels()
+		if opts.Control != nil {
+			opts.Control.advance(nSamples / pcm.channels())
+		}
+
+		samples := remapChannels(inbuf[:nSamples], matrix, routing, channels, bitDepth)
+		if matrix != nil {
+			srcChannels = channels
+		}
+		if srcRate != rate {
+			if resampler == nil {
+				resampler = newStreamResampler(srcRate, rate, srcChannels)
+			}
+			samples = resampler.process(samples)
+		}
+		if err := writeSamples(samples); err != nil {
+			return 0, err
+		}
+	}
+	// The resampler holds back the end of the file until it's flushed.
+	if resampler != nil {
+		if err := writeSamples(resampler.flush()); err != nil {
+			return 0, err
+		}
 	}
 	// Wait for playback to complete.
 	fmt.Printf("Playback should be complete now.\n")
 
+	return time.Duration(skipped) * time.Second / time.Duration(srcRate), nil
+}
+
+// writePlaybackSample converts a sample of the file's bit depth to the negotiated format and appends it to frames.
+func writePlaybackSample(frames *bytes.Buffer, sample, bitDepth int, format alsa.FormatType) error {
+	switch format {
+	case alsa.S16_LE:
+		// If the wav format is 32_LE, the PCM value must be converted to 16_LE.
+		// The simplest way is to rightshift 16 bits.
+		// However, could there be a smoother way?
+		// Yes! With bit coefficients! I'll do this later.
+		var err error
+		switch bitDepth {
+		case 32:
+			err = binary.Write(frames, binary.LittleEndian, int16(scale32To16(sample)))
+		case 24:
+			err = binary.Write(frames, binary.LittleEndian, int16(scale24To16(sample)))
+		case 16:
+			err = binary.Write(frames, binary.LittleEndian, int16(sample))
+		case 8:
+			err = binary.Write(frames, binary.LittleEndian, int16(scale8To16(sample)))
+		default:
+			return fmt.Errorf("Can't play this yet")
+		}
+
+		if err != nil {
+			fmt.Println(err)
+		}
+	case alsa.S24_LE, S24_3LE:
+		switch bitDepth {
+		case 32:
+			sample = scale32To24(sample)
+		case 16:
+			sample = scale16To24(sample)
+		case 8:
+			sample = scale8To24(sample)
+		}
+		if format == S24_3LE {
+			packed := make([]byte, 3)
+			pack24(packed, sample)
+			frames.Write(packed)
+		} else {
+			// S24_LE keeps the 24 bits in the low three bytes of a 4 byte container.
+			if err := binary.Write(frames, binary.LittleEndian, int32(sample)); err != nil {
+				fmt.Println(err)
+			}
+		}
+	case alsa.S32_LE:
+		switch bitDepth {
+		case 32:
+			if err := binary.Write(frames, binary.LittleEndian, int32(sample)); err != nil {
+				fmt.Println(err)
+			}
+		case 24:
+			if err := binary.Write(frames, binary.LittleEndian, int32(scale24To32(sample))); err != nil {
+				fmt.Println(err)
+			}
+		case 16:
+			// If the wav format is 16_LE, the PCM value must be converted to int32
+			// The simplest way would be to leftshift it 16 bits.
+			// However, could the be a smoother way?
+			// There sure is pal.
+			if err := binary.Write(frames, binary.LittleEndian, int32(scale16To32(sample))); err != nil {
+				fmt.Println(err)
+			}
+		case 8:
+			if err := binary.Write(frames, binary.LittleEndian, int32(scale8To32(sample))); err != nil {
+				fmt.Println(err)
+			}
+		default:
+			return fmt.Errorf("Can't play this yet")
+		}
+
+		// TODO What about when the number of channels arent the same?
+		// If the wav file is mono but the speakers are stereo, just double the samples.
+	default:
+		return fmt.Errorf("Unhandled sample format: %v", format)
+	}
 	return nil
 }
 
-func RecordWav(rec *alsa.Device, duration time.Duration, channels, rate int) (alsa.Buffer, error) {
-	var err error
+// maxConsecutiveReadFailures is how many periods in a row resilient playback skips before giving up.
+const maxConsecutiveReadFailures = 16
 
-	if err = rec.Open(); err != nil {
-		return alsa.Buffer{}, err
+// playbackRates returns the rates to negotiate for a file of the given rate, in order of preference:
+// the file's own rate, then the standard rates above it (whole multiples first, as ResolvePlaybackFormat does),
+// then the ones below it, closest first. Only the ones below lose anything in the resampling.
+func playbackRates(rate int) []int {
+	if rate <= 0 {
+		return []int{44100}
 	}
-	defer rec.Close()
+	rates := []int{rate}
+	for _, r := range standardRates {
+		if r > rate && r%rate == 0 {
+			rates = append(rates, r)
+		}
+	}
+	for _, r := range standardRates {
+		if r > rate && r%rate != 0 {
+			rates = append(rates, r)
+		}
+	}
+	for i := len(standardRates) - 1; i >= 0; i-- {
+		if r := standardRates[i]; r < rate {
+			rates = append(rates, r)
+		}
+	}
+	return rates
+}
 
-	_, err = rec.NegotiateChannels(channels)
-	if err != nil {
-		return alsa.Buffer{}, err
+// playbackFormats returns the sample formats to negotiate, in order of preference.
+func playbackFormats(bitDepth int, native bool) []alsa.FormatType {
+	if bitDepth == 24 {
+		// Devices that take S24_LE always get it, it's as exact as S32_LE for half the padding.
+		return []alsa.FormatType{alsa.S24_LE, S24_3LE, alsa.S32_LE, alsa.S16_LE}
+	}
+	formats := []alsa.FormatType{alsa.S32_LE, alsa.S16_LE}
+	if !native {
+		return formats
 	}
+	switch bitDepth {
+	case 16:
+		return []alsa.FormatType{alsa.S16_LE, alsa.S32_LE}
+	case 32:
+		return []alsa.FormatType{alsa.S32_LE, alsa.S16_LE}
+	}
+	return formats
+}
 
-	_, err = rec.NegotiateRate(rate)
-	if err != nil {
-		return alsa.Buffer{}, err
+// RecordOpts tweak how RecordWavWithOpts sets up the device.
+type RecordOpts struct {
+	Config AudioConfig
+	// Strict treats any overrun as a hard error, so a successful capture is guaranteed to be
+	// sample-continuous. The frames captured before the overrun are returned along with an *Overrun.
+	// By default the device is re-prepared and capture carries on, leaving a gap in the audio.
+	Strict bool
+	// ChannelFallback records with whatever channel count the device can do when it can't do Config.Channels,
+	// rather than failing, trying the counts closest to Config.Channels first.
+	// The buffer's Format.Channels says how many channels were captured.
+	ChannelFallback bool
+	// Upmix copies the captured channels round to fill the channel count asked for after a fallback,
+	// so a mono capture comes back as stereo with the same audio on both sides.
+	Upmix bool
+	// OnLevel is called with the RMS and peak level of every period as it's captured, as fractions of full scale,
+	// for driving a level meter. It's called from the recording loop, so it needs to return quickly.
+	OnLevel func(rms, peak float64)
+}
+
+func RecordWav(rec *alsa.Device, duration time.Duration, channels, rate int) (alsa.Buffer, error) {
+	return RecordWavWithOpts(rec, duration, RecordOpts{
+		Config: AudioConfig{Channels: channels, Rates: []int{rate}},
+	})
+}
+
+func RecordWavWithOpts(rec *alsa.Device, duration time.Duration, opts RecordOpts) (alsa.Buffer, error) {
+	return recordWav(rec, duration, opts, nil)
+}
+
+// prepareRecording opens and sets up the device for capture, falling back to another channel count if opts allow.
+func prepareRecording(rec negotiator, opts RecordOpts) (NegotiatedParams, error) {
+	defaults := deviceDefaults{
+		channels:     []int{2},
+		rates:        []int{44100},
+		formats:      []alsa.FormatType{alsa.S16_LE, alsa.S32_LE, S24_3LE},
+		bufferFrames: []int{8192, 16384},
+	}
+	params, err := prepareDevice(rec, opts.Config, defaults)
+	var unsupported *unsupportedChannels
+	if err != nil && opts.ChannelFallback && opts.Config.Channels > 0 && errors.As(err, &unsupported) {
+		cfg := opts.Config
+		cfg.Channels = 0
+		defaults.channels = fallbackChannels(opts.Config.Channels)
+		var fallbackErr error
+		if params, fallbackErr = prepareDevice(rec, cfg, defaults); fallbackErr == nil {
+			fmt.Printf("Device can't capture %d channels (%v), capturing %d instead\n",
+				opts.Config.Channels, err, params.Channels)
+			err = nil
+		}
 	}
+	return params, err
+}
 
-	_, err = rec.NegotiateFormat(alsa.S16_LE, alsa.S32_LE)
-	if err != nil {
-		return alsa.Buffer{}, err
+// fallbackChannels returns the usual channel counts other than channels, closest to it first
+// and the smaller of two as close.
+func fallbackChannels(channels int) []int {
+	var counts []int
+	for _, c := range []int{1, 2, 4, 6, 8} {
+		if c != channels {
+			counts = append(counts, c)
+		}
 	}
+	distance := func(c int) int {
+		if c < channels {
+			return channels - c
+		}
+		return c - channels
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		return distance(counts[i]) < distance(counts[j])
+	})
+	return counts
+}
 
-	bufferSize, err := rec.NegotiateBufferSize(8192, 16384)
+// recordWav does the recording for RecordWavWithOpts, adding a timestamp for every read to stamps if it isn't nil.
+func recordWav(rec *alsa.Device, duration time.Duration, opts RecordOpts, stamps *[]ReadTimestamp) (alsa.Buffer, error) {
+	params, err := prepareRecording(rec, opts)
 	if err != nil {
 		return alsa.Buffer{}, err
 	}
+	defer rec.Close()
+	bufferSize := params.BufferSize
 
-	if err = rec.Prepare(); err != nil {
-		return alsa.Buffer{}, err
+	// Upmixes the capture if it fell back to fewer channels.
+	finish := func(buf alsa.Buffer) alsa.Buffer {
+		if !opts.Upmix || buf.Format.Channels >= opts.Config.Channels {
+			return buf
+		}
+		fmt.Printf("Upmixing %d channels to %d\n", buf.Format.Channels, opts.Config.Channels)
+		return upmix(buf, opts.Config.Channels)
 	}
 
 	buf := rec.NewBufferDuration(duration)
+	// yobert/alsa only recognizes its own formats, take the one that was negotiated.
+	buf.Format.SampleFormat = params.Format
 
 	fmt.Printf("Negotiated parameters: %v, %d frame buffer, %d bytes/frame\n",
 		buf.Format, bufferSize, rec.BytesPerFrame())
 
-	fmt.Printf("Recording for %s (%d frames, %d bytes)...\n", duration, len(buf.Data)/rec.BytesPerFrame(), len(buf.Data))
-	err = rec.Read(buf.Data)
-	if err != nil {
+	frameSize := rec.BytesPerFrame()
+	fmt.Printf("Recording for %s (%d frames, %d bytes)...\n", duration, len(buf.Data)/frameSize, len(buf.Data))
+
+	// Read a device buffer's worth at a time so an overrun only costs the chunk it happened in.
+	n, err := capture(rec, buf.Data, bufferSize*frameSize, frameSize, opts.Strict, func(off, end int) {
+		if stamps != nil {
+			*stamps = append(*stamps, readTimestamp(rec, off/frameSize, (end-off)/frameSize, params.Rate, time.Now()))
+		}
+		if opts.OnLevel != nil {
+			meter(buf.Format, buf.Data[off:end], params.PeriodSize*frameSize, opts.OnLevel)
+		}
+	})
+	if _, overrun := err.(*Overrun); err != nil && !overrun {
 		return alsa.Buffer{}, err
 	}
-	fmt.Println("Recording stopped.")
-	return buf, nil
+	buf.Data = buf.Data[:n]
+	if err == nil {
+		fmt.Println("Recording stopped.")
+	}
+	return finish(buf), err
 }
 
-func SaveWav(recording alsa.Buffer, file string) error {
-	of, err := os.Create(file)
+// captureDevice is the part of a device capture uses.
+type captureDevice interface {
+	Read(buf []byte) error
+	Prepare() error
+}
+
+// capture fills data from the device chunkSize bytes at a time, calling done with the range of every chunk read.
+// Overruns are recovered from by preparing the device again, which leaves a gap in the audio.
+// When strict is set an overrun stops the capture instead, returning how many bytes were captured and an *Overrun.
+func capture(rec captureDevice, data []byte, chunkSize, frameSize int, strict bool, done func(off, end int)) (int, error) {
+	for off := 0; off < len(data); {
+		end := off + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		if err := deviceError(rec.Read(data[off:end])); err != nil {
+			if !isOverrun(err) {
+				return off, err
+			}
+			overrun := &Overrun{Frame: off / frameSize}
+			if strict {
+				return off, overrun
+			}
+			logging.Debugf("%v, recovering", overrun)
+			if err := rec.Prepare(); err != nil {
+				return off, errors.Wrapf(err, "failed to recover from %v", overrun)
+			}
+			continue
+		}
+		done(off, end)
+		off = end
+	}
+	return len(data), nil
+}
+
+// RecordWavToFile records straight into a WAV file, a period at a time, so long recordings don't have to fit in memory.
+// Overruns are recovered from like RecordWav does, leaving a gap in the audio.
+// If recording fails part way, the file is closed with what was captured up to then.
+func RecordWavToFile(rec *alsa.Device, duration time.Duration, channels, rate int, file string) error {
+	params, err := prepareRecording(rec, RecordOpts{
+		Config: AudioConfig{Channels: channels, Rates: []int{rate}},
+	})
 	if err != nil {
 		return err
 	}
-	defer of.Close()
+	defer rec.Close()
+	return recordToFile(rec, params, duration, file)
+}
 
-	var sampleBytes int
-	switch recording.Format.SampleFormat {
-	case alsa.S32_LE:
-		sampleBytes = 4
-	case alsa.S16_LE:
-		sampleBytes = 2
-	default:
-		return fmt.Errorf("Unhandled ALSA format %v", recording.Format.SampleFormat)
+// recordToFile is RecordWavToFile on a device that's already been prepared.
+func recordToFile(rec captureDevice, params NegotiatedParams, duration time.Duration, file string) error {
+	of, err := CreateFile(file, false)
+	if err != nil {
+		return err
 	}
+	defer of.Close()
+
+	format := alsa.BufferFormat{SampleFormat: params.Format, Rate: params.Rate, Channels: params.Channels}
+	bits := SampleBits(params.Format)
+	enc := wav.NewEncoder(of, params.Rate, bits, params.Channels, wavFormatPCM)
 
-	// normal uncompressed WAV format (I think)
-	// https://web.archive.org/web/20080113195252/http://www.borg.com/~jglatt/tech/wave.htm
-	wavformat := 1
+	frameSize := bytesPerFrame(format)
+	chunkFrames := params.PeriodSize
+	if chunkFrames == 0 {
+		chunkFrames = params.BufferSize
+	}
+	chunk := make([]byte, chunkFrames*frameSize)
+	frames := durationToFrames(duration, params.Rate)
+	fmt.Printf("Negotiated parameters: %v, %d frame buffer, %d bytes/frame\n", format, params.BufferSize, frameSize)
+	fmt.Printf("Recording to %s for %s (%d frames)...\n", file, duration, frames)
+
+	for done := 0; done < frames; {
+		n := frames - done
+		if n > chunkFrames {
+			n = chunkFrames
+		}
+		if err = deviceError(rec.Read(chunk[:n*frameSize])); err != nil {
+			if !isOverrun(err) {
+				break
+			}
+			overrun := &Overrun{Frame: done}
+			logging.Debugf("%v, recovering", overrun)
+			if err = rec.Prepare(); err != nil {
+				err = errors.Wrapf(err, "failed to recover from %v", overrun)
+				break
+			}
+			continue
+		}
+		var samples []int
+		if samples, err = decodeSamples(alsa.Buffer{Format: format, Data: chunk[:n*frameSize]}); err != nil {
+			break
+		}
+		if err = enc.Write(&audio.IntBuffer{
+			Format:         &audio.Format{NumChannels: params.Channels, SampleRate: params.Rate},
+			Data:           samples,
+			SourceBitDepth: bits,
+		}); err != nil {
+			err = errors.Wrapf(err, "failed to write %q", file)
+			break
+		}
+		done += n
+	}
 
-	enc := wav.NewEncoder(of, recording.Format.Rate, sampleBytes*8, recording.Format.Channels, wavformat)
+	// Close writes the sizes into the header, so the file is readable even if recording stopped early.
+	if closeErr := enc.Close(); closeErr != nil && err == nil {
+		err = errors.Wrapf(closeErr, "failed to finish %q", file)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Recording stopped, saved to %s\n", file)
+	return nil
+}
 
-	sampleCount := len(recording.Data) / sampleBytes
-	data := make([]int, sampleCount)
+// isOverrun reports whether a failed read was the device signalling an xrun, which the kernel reports as EPIPE.
+// The error has to have gone through deviceError.
+func isOverrun(err error) bool {
+	return errors.Is(err, syscall.EPIPE)
+}
 
-	format := &audio.Format{
-		NumChannels: recording.Format.Channels,
-		SampleRate:  recording.Format.Rate,
+// validateRecording catches captures that would encode to a useless WAV file.
+func validateRecording(recording alsa.Buffer) error {
+	if recording.Format.Channels < 1 {
+		return fmt.Errorf("invalid recording: %d channels", recording.Format.Channels)
+	}
+	if recording.Format.Rate < 1 {
+		return fmt.Errorf("invalid recording: %d hz sample rate", recording.Format.Rate)
+	}
+	if _, err := sampleBytes(recording.Format.SampleFormat); err != nil {
+		return errors.Wrap(err, "invalid recording")
+	}
+	if len(recording.Data) == 0 {
+		return fmt.Errorf("invalid recording: no audio data was captured")
 	}
+	frameSize := bytesPerFrame(recording.Format)
+	if len(recording.Data)%frameSize != 0 {
+		return fmt.Errorf("invalid recording: %d bytes of data is not a whole number of %d byte frames",
+			len(recording.Data), frameSize)
+	}
+	return nil
+}
 
-	// Convert into the format go-audio/wav wants
-	var off int
-	switch recording.Format.SampleFormat {
-	case alsa.S32_LE:
-		inc := binary.Size(uint32(0))
-		for i := 0; i < sampleCount; i++ {
-			data[i] = int(binary.LittleEndian.Uint32(recording.Data[off:]))
-			off += inc
+// SaveOpts tweak how SaveWavWithOpts encodes a recording.
+type SaveOpts struct {
+	// Float writes 32-bit IEEE float samples (WAVE format 3) normalized to -1..1
+	// instead of integer PCM, for tools that process audio without requantizing.
+	Float bool
+	// MkdirAll creates any missing parent directories of the file.
+	// Otherwise a missing directory fails with a *DirectoryNotFound.
+	MkdirAll bool
+	// BlockAlign and ByteRate, when set, replace the values computed for the fmt chunk,
+	// for players that insist on their own idea of them. The data is written as usual,
+	// so a value that doesn't match it is written anyway but warned about.
+	BlockAlign int
+	ByteRate   int
+}
+
+func SaveWav(recording alsa.Buffer, file string) error {
+	return SaveWavWithOpts(recording, file, SaveOpts{})
+}
+
+func SaveWavWithOpts(recording alsa.Buffer, file string, opts SaveOpts) error {
+	if err := validateRecording(recording); err != nil {
+		return err
+	}
+	if recording.Format.SampleFormat == alsa.S24_LE {
+		// WAV files pack 24-bit samples into 3 bytes.
+		var err error
+		if recording, err = ConvertFormat(recording, S24_3LE); err != nil {
+			return err
 		}
-	case alsa.S16_LE:
-		inc := binary.Size(uint16(0))
-		for i := 0; i < sampleCount; i++ {
-			data[i] = int(binary.LittleEndian.Uint16(recording.Data[off:]))
-			off += inc
+	}
+
+	size, err := sampleBytes(recording.Format.SampleFormat)
+	if err != nil {
+		return err
+	}
+
+	// The capture is already little endian interleaved PCM, which is exactly what goes in a WAV data chunk.
+	// Writing it out directly skips converting every sample to an int and back through the encoder,
+	// which took seconds for hour long recordings.
+	data := recording.Data
+	blockAlign := bytesPerFrame(recording.Format)
+	header := wavFmt{
+		// normal uncompressed WAV format (I think)
+		// https://web.archive.org/web/20080113195252/http://www.borg.com/~jglatt/tech/wave.htm
+		audioFormat:   wavFormatPCM,
+		numChannels:   uint16(recording.Format.Channels),
+		sampleRate:    uint32(recording.Format.Rate),
+		byteRate:      uint32(recording.Format.Rate * blockAlign),
+		blockAlign:    uint16(blockAlign),
+		bitsPerSample: uint16(size * 8),
+	}
+
+	if opts.Float {
+		if data, err = encodeFloat32(recording); err != nil {
+			return err
 		}
-	default:
-		return fmt.Errorf("Unhandled ALSA format %v", recording.Format.SampleFormat)
+		blockAlign = 4 * recording.Format.Channels
+		header.audioFormat = wavFormatFloat
+		header.byteRate = uint32(recording.Format.Rate * blockAlign)
+		header.blockAlign = uint16(blockAlign)
+		header.bitsPerSample = 32
 	}
 
-	intBuf := &audio.IntBuffer{Data: data, Format: format, SourceBitDepth: sampleBytes * 8}
+	if err := overrideAlignment(&header, opts); err != nil {
+		return err
+	}
 
-	if err := enc.Write(intBuf); err != nil {
+	of, err := CreateFile(file, opts.MkdirAll)
+	if err != nil {
 		return err
 	}
+	defer of.Close()
 
-	if err := enc.Close(); err != nil {
+	if err := writeWavHeader(of, header, uint32(len(data))); err != nil {
+		return err
+	}
+	if _, err := of.Write(data); err != nil {
 		return err
 	}
+	if len(data)%2 == 1 {
+		if _, err := of.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+
 	fmt.Printf("Saved recording to %s\n", file)
 	return nil
 }
+
+// overrideAlignment applies the BlockAlign and ByteRate overrides to the header.
+func overrideAlignment(header *wavFmt, opts SaveOpts) error {
+	if opts.BlockAlign != 0 {
+		if opts.BlockAlign < 0 || opts.BlockAlign > math.MaxUint16 {
+			return fmt.Errorf("block align %d out of range", opts.BlockAlign)
+		}
+		if opts.BlockAlign != int(header.blockAlign) {
+			logging.Warnf("block align %d doesn't match the %d byte frames of the data", opts.BlockAlign, header.blockAlign)
+		}
+		header.blockAlign = uint16(opts.BlockAlign)
+	}
+	if opts.ByteRate != 0 {
+		if opts.ByteRate < 0 || int64(opts.ByteRate) > math.MaxUint32 {
+			return fmt.Errorf("byte rate %d out of range", opts.ByteRate)
+		}
+		if opts.ByteRate != int(header.byteRate) {
+			logging.Warnf("byte rate %d doesn't match the %d bytes/s of the data", opts.ByteRate, header.byteRate)
+		}
+		header.byteRate = uint32(opts.ByteRate)
+	}
+	return nil
+}
+
+// CreateFile creates the file, returning a *DirectoryNotFound when its directory is missing
+// or creating the directory first when mkdirAll is set.
+func CreateFile(file string, mkdirAll bool) (*os.File, error) {
+	dir := filepath.Dir(file)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if !mkdirAll {
+			return nil, &DirectoryNotFound{Dir: dir}
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, errors.Wrapf(err, "failed to create %q", dir)
+		}
+	}
+	return os.Create(file)
+}
+
+// loadWav reads a PCM WAV file into a buffer, the inverse of SaveWav.
+func loadWav(file string) (alsa.Buffer, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return alsa.Buffer{}, errors.Wrapf(err, "failed to open %q", file)
+	}
+	defer f.Close()
+
+	chunks, err := walkChunks(f)
+	if err != nil {
+		return alsa.Buffer{}, errors.Wrapf(err, "failed to read the chunks of %q", file)
+	}
+	fmtChunk, ok := findChunk(chunks, "fmt ")
+	if !ok {
+		return alsa.Buffer{}, fmt.Errorf("%q has no fmt chunk", file)
+	}
+	dataChunk, ok := findChunk(chunks, "data")
+	if !ok {
+		return alsa.Buffer{}, fmt.Errorf("%q has no data chunk", file)
+	}
+	header, err := readFmtChunk(f, fmtChunk)
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+	if header.audioFormat != wavFormatPCM {
+		return alsa.Buffer{}, fmt.Errorf("%q is not integer PCM (format %d)", file, header.audioFormat)
+	}
+	format, err := wavBitsFormat(int(header.bitsPerSample))
+	if err != nil {
+		return alsa.Buffer{}, err
+	}
+
+	if _, err := f.Seek(dataChunk.offset, io.SeekStart); err != nil {
+		return alsa.Buffer{}, err
+	}
+	data := make([]byte, dataChunk.size)
+	n, err := io.ReadFull(f, data)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return alsa.Buffer{}, errors.Wrapf(err, "failed to read the audio of %q", file)
+	}
+	// Keep whatever whole frames a truncated file still has.
+	if header.blockAlign > 0 {
+		n -= n % int(header.blockAlign)
+	}
+	return alsa.Buffer{
+		Format: alsa.BufferFormat{
+			SampleFormat: format,
+			Rate:         int(header.sampleRate),
+			Channels:     int(header.numChannels),
+		},
+		Data: data[:n],
+	}, nil
+}
+
+// encodeFloat32 converts the recording to little endian 32-bit float samples.
+func encodeFloat32(recording alsa.Buffer) ([]byte, error) {
+	floats, err := decodeFloats(recording)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 4*len(floats))
+	for i, f := range floats {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(float32(f)))
+	}
+	return data, nil
+}
@@ -0,0 +1,292 @@
+package alsa
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+	"github.com/yobert/alsa"
+)
+
+func TestSaveWavRoundTrip(t *testing.T) {
+	for _, format := range []alsa.FormatType{alsa.S16_LE, S24_3LE, alsa.S32_LE} {
+		t.Run(format.String(), func(t *testing.T) {
+			recording := sineBuffer(t, format, 2, 44100, 4410)
+			file := filepath.Join(t.TempDir(), "out.wav")
+			if err := SaveWav(recording, file); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := loadWav(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !BuffersEqual(loaded, recording) {
+				t.Error("the saved recording doesn't load back the same")
+			}
+		})
+	}
+}
+
+// saveWavEncoder is how SaveWav used to work: every sample converted to an int and written through the go-audio encoder.
+func TestRecordToFileReadError(t *testing.T) {
+	dev := &fakeCapture{fail: map[int]syscall.Errno{3: syscall.EBADFD}}
+	params := NegotiatedParams{Channels: 1, Rate: 100, Format: alsa.S16_LE, PeriodSize: 10, BufferSize: 20}
+	file := filepath.Join(t.TempDir(), "out.wav")
+	if err := recordToFile(dev, params, time.Second, file); !errors.Is(err, syscall.EBADFD) {
+		t.Fatalf("got error %v, want EBADFD", err)
+	}
+	// The file is finished with the two periods read before the error.
+	loaded, err := loadWav(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Data) != 40 {
+		t.Errorf("got %d bytes of audio, want 40", len(loaded.Data))
+	}
+}
+
+func TestRecordToFileWriteError(t *testing.T) {
+	dev := &fakeCapture{}
+	params := NegotiatedParams{Channels: 1, Rate: 100, Format: alsa.S16_LE, PeriodSize: 10, BufferSize: 20}
+	if err := recordToFile(dev, params, time.Second, "/dev/full"); !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("got error %v, want ENOSPC", err)
+	}
+	if dev.reads != 1 {
+		t.Errorf("read %d periods, recording should stop at the first failed write", dev.reads)
+	}
+}
+
+// fakePlayback takes writes the way yobert/alsa does, which can't take an empty one.
+type fakePlayback struct {
+	*fakeNegotiator
+	frames int
+	data   []byte
+}
+
+func (f *fakePlayback) Write(buf []byte, frames int) error {
+	if len(buf) == 0 || frames == 0 {
+		return errors.New("empty write")
+	}
+	f.frames += frames
+	f.data = append(f.data, buf...)
+	return nil
+}
+
+// A file resampled on the way to the device plays all the way to its last frame, however the periods fall.
+func TestPlayWavResampledPlaysToTheEnd(t *testing.T) {
+	tests := []struct {
+		name              string
+		fileRate, devRate int
+		frames            int
+	}{
+		// The resampler holds back the frames after the last one it has read until it's flushed.
+		{"upsampled", 8000, 48000, 4 * defaultPeriodFrames},
+		// A file frame past the last whole period is less than a frame at the device's rate.
+		{"downsampled", 48000, 8000, 6*defaultPeriodFrames + 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "tone.wav")
+			if err := SaveWav(sineBuffer(t, alsa.S16_LE, 2, test.fileRate, test.frames), file); err != nil {
+				t.Fatal(err)
+			}
+			device := &fakePlayback{fakeNegotiator: &fakeNegotiator{
+				channels: []int{2},
+				rates:    []int{test.devRate},
+				formats:  []alsa.FormatType{alsa.S16_LE},
+			}}
+			if _, err := playWav(context.Background(), device, file, PlayOpts{}, 1); err != nil {
+				t.Fatal(err)
+			}
+			want := test.frames * test.devRate / test.fileRate
+			if device.frames < want-1 || device.frames > want+1 {
+				t.Errorf("played %d frames, want %d", device.frames, want)
+			}
+		})
+	}
+}
+
+// Files with more channels than the device play downmixed, or not at all, never with channels missing.
+func TestPlayWavDownmix(t *testing.T) {
+	// Only the center of 5.1 has anything on it.
+	center := alsa.Buffer{
+		Format: alsa.BufferFormat{SampleFormat: alsa.S16_LE, Rate: 44100, Channels: 6},
+		Data:   make([]byte, 100*6*2),
+	}
+	for f := 0; f < 100; f++ {
+		binary.LittleEndian.PutUint16(center.Data[(f*6+2)*2:], 10000)
+	}
+	file := filepath.Join(t.TempDir(), "center.wav")
+	if err := SaveWav(center, file); err != nil {
+		t.Fatal(err)
+	}
+	device := func(channels int) *fakePlayback {
+		return &fakePlayback{fakeNegotiator: &fakeNegotiator{
+			channels: []int{channels},
+			rates:    []int{44100},
+			formats:  []alsa.FormatType{alsa.S16_LE},
+		}}
+	}
+
+	stereo := device(2)
+	if _, err := playWav(context.Background(), stereo, file, PlayOpts{}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if stereo.frames != 100 {
+		t.Fatalf("played %d frames, want 100", stereo.frames)
+	}
+	for i := 0; i < 2*stereo.frames; i++ {
+		if got := int16(binary.LittleEndian.Uint16(stereo.data[2*i:])); got != 7071 {
+			t.Fatalf("sample %d is %d, want the center at -3dB", i, got)
+		}
+	}
+
+	if _, err := playWav(context.Background(), device(4), file, PlayOpts{}, 1); err == nil {
+		t.Error("played 5.1 on 4 channels without a downmix")
+	}
+	ragged := PlayOpts{Downmix: [][]float64{{1, 0, 1, 0, 1, 0}, {0, 1, 1}}}
+	if _, err := playWav(context.Background(), device(2), file, ragged, 1); err == nil {
+		t.Error("played with a ragged downmix")
+	}
+}
+
+// Channel gains scale each channel of the interleaved frames on their way to the device.
+func TestPlayWavChannelGains(t *testing.T) {
+	samples := make([]int, 2*100)
+	for i := range samples {
+		samples[i] = 1000
+	}
+	data, err := encodeSamples(samples, alsa.S16_LE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "flat.wav")
+	if err := SaveWav(alsa.Buffer{Format: alsa.BufferFormat{SampleFormat: alsa.S16_LE, Rate: 44100, Channels: 2}, Data: data}, file); err != nil {
+		t.Fatal(err)
+	}
+	device := func() *fakePlayback {
+		return &fakePlayback{fakeNegotiator: &fakeNegotiator{
+			channels: []int{2},
+			rates:    []int{44100},
+			formats:  []alsa.FormatType{alsa.S16_LE},
+		}}
+	}
+
+	tests := []struct {
+		gains       []float64
+		left, right int16
+	}{
+		{[]float64{0.5, 2}, 500, 2000},
+		{[]float64{0.25}, 250, 250},  // one gain for every channel
+		{[]float64{40, 0}, 32767, 0}, // clamped at full scale
+	}
+	for _, tt := range tests {
+		d := device()
+		if _, err := playWav(context.Background(), d, file, PlayOpts{ChannelGains: tt.gains}, 1); err != nil {
+			t.Fatal(err)
+		}
+		if d.frames != 100 {
+			t.Fatalf("gains %v: played %d frames, want 100", tt.gains, d.frames)
+		}
+		for f := 0; f < d.frames; f++ {
+			left := int16(binary.LittleEndian.Uint16(d.data[4*f:]))
+			right := int16(binary.LittleEndian.Uint16(d.data[4*f+2:]))
+			if left != tt.left || right != tt.right {
+				t.Fatalf("gains %v: frame %d is %d, %d, want %d, %d", tt.gains, f, left, right, tt.left, tt.right)
+			}
+		}
+	}
+
+	if _, err := playWav(context.Background(), device(), file, PlayOpts{ChannelGains: []float64{1, 1, 1}}, 1); err == nil {
+		t.Error("played with 3 gains on 2 channels")
+	}
+}
+
+// The file's own rate comes first, then rates it divides evenly, which resample best,
+// then the rest above it, closest first, and only then rates that lose detail, closest first.
+func TestPlaybackRates(t *testing.T) {
+	tests := []struct {
+		rate int
+		want []int
+	}{
+		{44100, []int{44100, 88200, 176400, 48000, 96000, 192000, 32000, 22050, 16000, 11025, 8000}},
+		{48000, []int{48000, 96000, 192000, 88200, 176400, 44100, 32000, 22050, 16000, 11025, 8000}},
+		{8000, []int{8000, 16000, 32000, 48000, 96000, 192000, 11025, 22050, 44100, 88200, 176400}},
+		{192000, []int{192000, 176400, 96000, 88200, 48000, 44100, 32000, 22050, 16000, 11025, 8000}},
+		{12345, []int{12345, 16000, 22050, 32000, 44100, 48000, 88200, 96000, 176400, 192000, 11025, 8000}},
+		{0, []int{44100}},
+	}
+	for _, tt := range tests {
+		if got := playbackRates(tt.rate); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d Hz: got %v, want %v", tt.rate, got, tt.want)
+		}
+	}
+}
+
+func saveWavEncoder(recording alsa.Buffer, file string) error {
+	of, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer of.Close()
+	samples, err := decodeSamples(recording)
+	if err != nil {
+		return err
+	}
+	bits := SampleBits(recording.Format.SampleFormat)
+	enc := wav.NewEncoder(of, recording.Format.Rate, bits, recording.Format.Channels, wavFormatPCM)
+	format := &audio.Format{NumChannels: recording.Format.Channels, SampleRate: recording.Format.Rate}
+	if err := enc.Write(&audio.IntBuffer{Data: samples, Format: format, SourceBitDepth: bits}); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// SaveWav writes the capture out directly now, which must give the same file the encoder did.
+func TestSaveWavMatchesEncoder(t *testing.T) {
+	for _, format := range []alsa.FormatType{alsa.S16_LE, alsa.S32_LE} {
+		t.Run(format.String(), func(t *testing.T) {
+			recording := sineBuffer(t, format, 2, 44100, 4410)
+			dir := t.TempDir()
+			if err := SaveWav(recording, filepath.Join(dir, "direct.wav")); err != nil {
+				t.Fatal(err)
+			}
+			if err := saveWavEncoder(recording, filepath.Join(dir, "encoder.wav")); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(readFile(t, filepath.Join(dir, "direct.wav")), readFile(t, filepath.Join(dir, "encoder.wav"))) {
+				t.Error("SaveWav and the encoder wrote different files")
+			}
+		})
+	}
+}
+
+// Compare the two with go test -bench SaveWav, on three minutes of stereo 16-bit audio.
+func benchmarkSave(b *testing.B, save func(alsa.Buffer, string) error) {
+	recording := sineBuffer(b, alsa.S16_LE, 2, 44100, int(3*time.Minute/time.Second)*44100)
+	file := filepath.Join(b.TempDir(), "out.wav")
+	b.SetBytes(int64(len(recording.Data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := save(recording, file); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSaveWav(b *testing.B) {
+	benchmarkSave(b, SaveWav)
+}
+
+func BenchmarkSaveWavEncoder(b *testing.B) {
+	benchmarkSave(b, saveWavEncoder)
+}
@@ -63,29 +63,24 @@ package audiostream
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
+	"sync"
 	"time"
 
-	"github.com/go-audio/audio"
 	"github.com/go-audio/wav"
 	"github.com/yobert/alsa"
+
+	alsautil "github.com/renan-campos/sound-utils/pkg/alsa"
 )
 
 type AudioStreamStatus string
 
 const (
-	statusRecording AudioStreamStatus = "recording"
-	statusStandby   AudioStreamStatus = "standby"
-	statusOff       AudioStreamStatus = "off"
-	statusError     AudioStreamStatus = "error"
-)
-
-// ALSA Device constants
-const (
-	numChannels  = 1
-	sampleRate   = 44100
-	sampleFormat = alsa.S16_LE
-	bitDepth     = 16
+	StatusRecording AudioStreamStatus = "recording"
+	StatusStandby   AudioStreamStatus = "standby"
+	StatusOff       AudioStreamStatus = "off"
+	StatusError     AudioStreamStatus = "error"
 )
 
 type DeviceConfig struct {
@@ -95,6 +90,22 @@ type DeviceConfig struct {
 	BufferSize  int
 }
 
+// BytesPerFrame is the size of one frame: a sample for every channel.
+func (c DeviceConfig) BytesPerFrame() int {
+	return alsautil.SampleSize(c.FrameFormat) * c.NumChannels
+}
+
+// FrameBytesPerSecond is the rate at which the device produces data.
+func (c DeviceConfig) FrameBytesPerSecond() int {
+	return c.BytesPerFrame() * c.FrameRate
+}
+
+// captureSource is what the data mover reads from, the device once it's set up.
+type captureSource interface {
+	Read(buf []byte) error
+	Close()
+}
+
 type AudioStream struct {
 	device       *alsa.Device
 	deviceConfig DeviceConfig
@@ -104,25 +115,64 @@ type AudioStream struct {
 	dmStatus     chan AudioStreamStatus
 	fmDone       chan struct{}
 	dmDone       chan struct{}
+	fmFlush      chan chan error
+	tee          *tee
+	lock         *sync.Mutex
+	idle         *idleTimer
+	changes      chan AudioStreamStatus
+	clip         *clipDetector
+	headroom     *headroomMeter
+	marks        *marker
+	errs         chan error
+	liveHeader   bool
+
+	source captureSource
+	// newSource stands in for setting up the device when it's set, so the stream can run without one.
+	newSource func(DeviceConfig) (captureSource, error)
 }
 
 func NewAudioStream() AudioStream {
 	return AudioStream{
 		device:   nil,
 		fileName: "",
-		status:   statusOff,
+		status:   StatusOff,
 		fmStatus: make(chan AudioStreamStatus, 1),
 		dmStatus: make(chan AudioStreamStatus, 1),
 		fmDone:   make(chan struct{}, 1),
 		dmDone:   make(chan struct{}, 1),
+		fmFlush:  make(chan chan error),
+		tee:      &tee{},
+		lock:     &sync.Mutex{},
+		idle:     &idleTimer{},
+		changes:  make(chan AudioStreamStatus, 8),
+		clip:     newClipDetector(),
+		headroom: &headroomMeter{},
+		marks:    &marker{},
+		errs:     make(chan error, 1),
 	}
 }
 
 func (a *AudioStream) SetDevice(device *alsa.Device, config DeviceConfig) error {
-	if a.status != statusOff {
-		return fmt.Errorf("AudioStream must be off to change files")
+	if a.status != StatusOff {
+		return fmt.Errorf("AudioStream must be off to change devices")
+	}
+	switch {
+	case config.NumChannels <= 0:
+		return fmt.Errorf("invalid device config: %d channels", config.NumChannels)
+	case config.FrameRate <= 0:
+		return fmt.Errorf("invalid device config: %d Hz frame rate", config.FrameRate)
+	case config.BufferSize <= 0:
+		return fmt.Errorf("invalid device config: %d frame buffer", config.BufferSize)
+	}
+	supported := false
+	for _, f := range alsautil.SupportedFormats() {
+		supported = supported || f == config.FrameFormat
+	}
+	if !supported {
+		return fmt.Errorf("invalid device config: can't record %v", config.FrameFormat)
 	}
 	a.device = device
+	a.deviceConfig = config
 	return nil
 }
 
@@ -130,107 +180,211 @@ func (a *AudioStream) GetDevice(device *alsa.Device) *alsa.Device {
 	return a.device
 }
 
+// SetFileName sets the file to record to. On standby the current file is finished and the next recording goes to the new one.
 func (a *AudioStream) SetFileName(fileName string) error {
-	if a.status != statusStandby || a.status != statusOff {
-		return fmt.Errorf("AudioStream must be off or on standby to change files")
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	switch a.status {
+	case StatusOff:
+		a.fileName = fileName
+		return nil
+	case StatusStandby:
+		// The file mover already has the old file open, start it over on the new one.
+		a.stopMovers()
+		a.fileName = fileName
+		if err := a.startMovers(); err != nil {
+			a.source.Close()
+			a.tee.close()
+			a.setStatus(StatusOff)
+			return err
+		}
+		return nil
+	}
+	return fmt.Errorf("AudioStream must be off or on standby to change files")
+}
+
+// SetLiveHeader makes the file mover update the header sizes every time it writes to the file, as Flush does,
+// so other programs can read the file while it's being recorded and always see a valid WAV.
+// It takes effect the next time the stream is turned on.
+func (a *AudioStream) SetLiveHeader(live bool) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.status != StatusOff {
+		return fmt.Errorf("AudioStream must be off to change the header mode")
 	}
-	a.fileName = fileName
+	a.liveHeader = live
 	return nil
 }
 
 func (a *AudioStream) GetFileName() string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
 	return a.fileName
 }
 
+// Monitor returns a channel that receives a copy of every chunk captured while recording,
+// holding up to depth chunks. A monitor that doesn't keep up misses chunks, the file doesn't.
+// The channel is closed when the stream is turned off; after turning it back on, ask for a new one.
+func (a *AudioStream) Monitor(depth int) <-chan []byte {
+	return a.tee.add(depth)
+}
+
+// SetIdleTimeout makes the stream turn itself off, closing the device, once it has sat in standby
+// for the given time. The countdown starts over on every Record or Standby and never runs while recording.
+// A timeout of 0 disables it.
+func (a *AudioStream) SetIdleTimeout(timeout time.Duration) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.idle.timeout = timeout
+	if a.status == StatusStandby {
+		a.idle.reset(a.idleOff)
+	}
+}
+
+// StatusChanges returns a channel that receives the new status each time the stream changes state,
+// including when it turns itself off after being idle. Changes are dropped if nobody is reading.
+func (a *AudioStream) StatusChanges() <-chan AudioStreamStatus {
+	return a.changes
+}
+
+func (a *AudioStream) setStatus(status AudioStreamStatus) {
+	a.status = status
+	select {
+	case a.changes <- status:
+	default:
+	}
+}
+
+func (a *AudioStream) idleOff(generation int) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if !a.idle.current(generation) || a.status != StatusStandby {
+		return
+	}
+	a.off()
+}
+
+// WarnOnClip enables clip warnings: while recording, any chunk with a sample above thresholdDB (in dBFS, e.g. -0.1)
+// sends a warning to ClipWarnings, at most once a second.
+func (a *AudioStream) WarnOnClip(thresholdDB float64) {
+	a.clip.set(thresholdDB)
+}
+
+// ClipWarnings returns the channel clip warnings are sent to. Warnings are dropped if nobody is reading.
+func (a *AudioStream) ClipWarnings() <-chan ClipWarning {
+	return a.clip.warnings
+}
+
 func (a *AudioStream) Record() error {
-	a.dmStatus <- statusRecording
-	a.fmStatus <- statusRecording
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.status != StatusStandby {
+		return fmt.Errorf("AudioStream must be on standby to record, it's %s", a.status)
+	}
+	a.idle.stop()
+	a.marks.start(time.Now())
+	a.dmStatus <- StatusRecording
+	a.fmStatus <- StatusRecording
+	a.setStatus(StatusRecording)
 	return nil
 }
 
 func (a *AudioStream) Standby() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
 	switch a.status {
-	case statusStandby:
-		a.dmStatus <- statusStandby
-		a.fmStatus <- statusStandby
+	case StatusStandby:
+		a.dmStatus <- StatusStandby
+		a.fmStatus <- StatusStandby
 		// TODO probably want to flush the framebuffer...
+		a.idle.reset(a.idleOff)
 		return nil
-	case statusOff:
-		if err := a.startDevice(); err != nil {
+	case StatusOff:
+		source, err := a.openSource()
+		if err != nil {
 			return err
 		}
-
-		frameBuffer, ringBuffer := a.setupBuffers()
-
-		a.startDataMover(frameBuffer, ringBuffer)
-		a.startFileMover(ringBuffer)
-
-		a.status = statusStandby
+		a.source = source
+		if err := a.startMovers(); err != nil {
+			a.source.Close()
+			return err
+		}
+		a.setStatus(StatusStandby)
+		a.idle.reset(a.idleOff)
 		return nil
-	case statusRecording:
-		a.dmStatus <- statusStandby
-		a.fmStatus <- statusStandby
-		a.status = statusStandby
+	case StatusRecording:
+		a.dmStatus <- StatusStandby
+		a.fmStatus <- StatusStandby
+		a.setStatus(StatusStandby)
+		a.idle.reset(a.idleOff)
 		return nil
 	}
 	return fmt.Errorf("Unknown stream status")
 }
 
 func (a *AudioStream) Off() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.off()
+}
+
+func (a *AudioStream) off() error {
+	a.idle.stop()
 	switch a.status {
-	case statusStandby:
-		a.dmStatus <- statusOff
-		a.fmStatus <- statusOff
-		a.device.Close()
-		a.status = statusOff
+	case StatusStandby, StatusRecording, StatusError:
+		a.stopMovers()
+		a.source.Close()
+		a.tee.close()
+		a.setStatus(StatusOff)
 		return nil
-	case statusRecording:
-		a.dmStatus <- statusOff
-		a.fmStatus <- statusOff
-		<-a.fmDone
-		<-a.dmDone
-		a.device.Close()
-		a.status = statusOff
-		return nil
-	case statusOff:
+	case StatusOff:
 		return nil
 	}
 	return fmt.Errorf("Unknown stream status")
 }
 
-func (a *AudioStream) startDevice() error {
-	if err := a.device.Open(); err != nil {
-		return err
-	}
-
-	_, err := a.device.NegotiateChannels(a.deviceConfig.NumChannels)
+// startMovers starts the data and file movers on new buffers, the file mover creating the file.
+func (a *AudioStream) startMovers() error {
+	frameBuffer, ringBuffer, err := a.setupBuffers()
 	if err != nil {
 		return err
 	}
+	a.startDataMover(frameBuffer, ringBuffer)
+	a.startFileMover(ringBuffer)
+	return nil
+}
 
-	_, err = a.device.NegotiateRate(a.deviceConfig.FrameRate)
-	if err != nil {
-		return err
-	}
+// stopMovers stops capturing, then waits for the file mover to write out what's left and finish the file.
+func (a *AudioStream) stopMovers() {
+	a.dmStatus <- StatusOff
+	<-a.dmDone
+	a.fmStatus <- StatusOff
+	<-a.fmDone
+}
 
-	_, err = a.device.NegotiateFormat(a.deviceConfig.FrameFormat)
-	if err != nil {
-		return err
+// openSource sets up the device to capture from.
+func (a *AudioStream) openSource() (captureSource, error) {
+	if a.newSource != nil {
+		return a.newSource(a.deviceConfig)
 	}
-
-	_, err = a.device.NegotiateBufferSize(a.deviceConfig.BufferSize)
-	if err != nil {
-		return err
+	if err := a.startDevice(); err != nil {
+		return nil, err
 	}
+	return a.device, nil
+}
 
-	if err = a.device.Prepare(); err != nil {
-		return err
+func (a *AudioStream) startDevice() error {
+	cfg := alsautil.AudioConfig{
+		Channels:     a.deviceConfig.NumChannels,
+		Rates:        []int{a.deviceConfig.FrameRate},
+		Formats:      []alsa.FormatType{a.deviceConfig.FrameFormat},
+		BufferFrames: a.deviceConfig.BufferSize,
 	}
-
-	return nil
+	_, err := alsautil.PrepareDevice(a.device, cfg)
+	return err
 }
 
-func (a *AudioStream) setupBuffers() (*alsa.Buffer, *RingBuffer) {
+func (a *AudioStream) setupBuffers() (*alsa.Buffer, *RingBuffer, error) {
 	// The frame buffer will hold 2 seconds
 	// For 44.1kHz at 2 bytes per sample, that's 176400 bytes
 	// The ring buffer will hold 40 seconds
@@ -238,7 +392,14 @@ func (a *AudioStream) setupBuffers() (*alsa.Buffer, *RingBuffer) {
 	// The write size will be 8 seconds
 	// For 44.1kHz at 2 bytes that's 705600 bytes
 	// 40 seconds is 20 times the frame buffer. 5 seconds is 1/5 of the ring buffer
-	frameBuffer := a.device.NewBufferDuration(2 * time.Second)
+	frameBuffer := alsa.Buffer{
+		Format: alsa.BufferFormat{
+			SampleFormat: a.deviceConfig.FrameFormat,
+			Rate:         a.deviceConfig.FrameRate,
+			Channels:     a.deviceConfig.NumChannels,
+		},
+		Data: make([]byte, 2*a.deviceConfig.FrameBytesPerSecond()),
+	}
 	frameBufferSize := len(frameBuffer.Data)
 
 	ringBufferSpec := RingBufferSpec{
@@ -246,9 +407,12 @@ func (a *AudioStream) setupBuffers() (*alsa.Buffer, *RingBuffer) {
 		WriteSize: frameBufferSize,
 		ReadSize:  frameBufferSize * 4,
 	}
-	ringBuffer := NewRingBuffer(ringBufferSpec)
+	ringBuffer, err := NewRingBuffer(ringBufferSpec)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return &frameBuffer, &ringBuffer
+	return &frameBuffer, &ringBuffer, nil
 }
 
 func (a *AudioStream) startDataMover(frameBuffer *alsa.Buffer, ringBuffer *RingBuffer) {
@@ -259,18 +423,23 @@ func (a *AudioStream) startDataMover(frameBuffer *alsa.Buffer, ringBuffer *RingB
 			select {
 			case status := <-a.dmStatus:
 				switch status {
-				case statusRecording:
+				case StatusRecording:
 					recording = true
-				case statusStandby:
+				case StatusStandby:
 					recording = false
-				case statusOff:
+				case StatusOff:
 					recording = false
 					die = true
 				}
 			default:
 				if recording {
-					a.device.Read(frameBuffer.Data)
+					a.source.Read(frameBuffer.Data)
+					now := time.Now()
+					a.clip.check(*frameBuffer, now)
+					a.headroom.add(*frameBuffer, now)
+					a.marks.read(*frameBuffer, now)
 					ringBuffer.Write(frameBuffer.Data)
+					a.tee.send(frameBuffer.Data)
 				}
 				if die {
 					a.dmDone <- struct{}{}
@@ -282,15 +451,16 @@ func (a *AudioStream) startDataMover(frameBuffer *alsa.Buffer, ringBuffer *RingB
 }
 
 func (a *AudioStream) startFileMover(ringBuffer *RingBuffer) {
+	live := a.liveHeader
+	fileName := a.fileName
 	go func() {
 		var recording, die bool
-		fp, err := os.Create(a.fileName)
+		fp, err := alsautil.CreateFile(fileName, false)
 		if err != nil {
-			// In the future, crashes can be prevented by having an error channel.
-			// Then the user just needs to turn the audio stream off, correct the issue and move on.
-			// For now, I'll just exit ungracefully.
-			fmt.Printf("Failed to create file %s: %v", a.fileName, err)
-			os.Exit(1)
+			err = &FileError{Op: "create", File: fileName, Err: err}
+			a.fail(err)
+			a.failedFileMover(ringBuffer, err)
+			return
 		}
 		defer fp.Close()
 
@@ -298,52 +468,94 @@ func (a *AudioStream) startFileMover(ringBuffer *RingBuffer) {
 		// https://web.archive.org/web/20080113195252/http://www.borg.com/~jglatt/tech/wave.htm
 		wavFormat := 1
 
+		bitDepth := alsautil.SampleBits(a.deviceConfig.FrameFormat)
 		enc := wav.NewEncoder(fp, a.deviceConfig.FrameRate, bitDepth, a.deviceConfig.NumChannels, wavFormat)
+		bufferFormat := alsa.BufferFormat{
+			SampleFormat: a.deviceConfig.FrameFormat,
+			Rate:         a.deviceConfig.FrameRate,
+			Channels:     a.deviceConfig.NumChannels,
+		}
+
+		write := func(data []byte) error {
+			// Convert into the format go-audio/wav wants
+			frames := len(data) / a.deviceConfig.BytesPerFrame() * a.deviceConfig.BytesPerFrame()
+			pcm := alsautil.BufferToPCM(alsa.Buffer{Format: bufferFormat, Data: data[:frames]})
+			intBuf := pcm.AsIntBuffer()
+			intBuf.SourceBitDepth = bitDepth
+
+			return enc.Write(intBuf)
+		}
 
 		for {
 			select {
 			case status := <-a.fmStatus:
 				switch status {
-				case statusRecording:
+				case StatusRecording:
 					recording = true
-				case statusStandby:
+				case StatusStandby:
 					recording = false
-				case statusOff:
+				case StatusOff:
 					recording = false
 					die = true
 				}
+			case reply := <-a.fmFlush:
+				// Write out everything captured so far before fixing up the header.
+				var err error
+				for data, read := ringBuffer.ReadNoBlock(); read && err == nil; data, read = ringBuffer.ReadNoBlock() {
+					err = write(data)
+				}
+				// And the start of the next read, which isn't full yet.
+				if data, read := ringBuffer.ReadPartial(); read && err == nil {
+					err = write(data)
+				}
+				if err == nil {
+					err = flushHeader(fp, enc)
+				}
+				reply <- err
 			default:
 				if recording {
 					data, read := ringBuffer.ReadNoBlock()
 					if read {
-
-						format := &audio.Format{
-							NumChannels: a.deviceConfig.NumChannels,
-							SampleRate:  a.deviceConfig.FrameRate,
-						}
-
-						// Convert into the format go-audio/wav wants
-						var off int
-						sampleCount := len(data) / (bitDepth / 8)
-						wavData := make([]int, sampleCount)
-
-						inc := binary.Size(uint16(0))
-						for i := 0; i < sampleCount; i++ {
-							wavData[i] = int(binary.LittleEndian.Uint16(data[off:]))
-							off += inc
+						err := write(data)
+						if err != nil {
+							err = &FileError{Op: "write to", File: fileName, Err: err}
+						} else if live {
+							if err = flushHeader(fp, enc); err != nil {
+								err = &FileError{Op: "update the header of", File: fileName, Err: err}
+							}
 						}
-
-						intBuf := &audio.IntBuffer{Data: wavData, Format: format, SourceBitDepth: bitDepth}
-
-						err := enc.Write(intBuf)
 						if err != nil {
-							fmt.Printf("Failed to write to file %s: %v", a.fileName, err)
-							os.Exit(1)
+							a.fail(err)
+							// Leave what was written as valid a file as possible.
+							enc.Close()
+							a.failedFileMover(ringBuffer, err)
+							return
 						}
 					}
 				}
 				if die {
+					// The data mover has stopped, write out the rest of the recording.
+					ringBuffer.Close()
+					for data, read := ringBuffer.Drain(); read; data, read = ringBuffer.Drain() {
+						if err := write(data); err != nil {
+							a.fail(&FileError{Op: "write to", File: fileName, Err: err})
+							break
+						}
+					}
+					if enc.WrittenBytes == 0 {
+						// Nothing was recorded, don't leave an empty file behind.
+						a.marks.take()
+						fp.Close()
+						if info, err := os.Stat(fileName); err == nil && info.Mode().IsRegular() {
+							os.Remove(fileName)
+						}
+						a.fmDone <- struct{}{}
+						return
+					}
 					enc.Close()
+					if err := appendCues(fp, a.marks.take()); err != nil {
+						a.fail(&FileError{Op: "write the marks to", File: fileName, Err: err})
+					}
 					a.fmDone <- struct{}{}
 					return
 				}
@@ -351,3 +563,45 @@ func (a *AudioStream) startFileMover(ringBuffer *RingBuffer) {
 		}
 	}()
 }
+
+// Flush writes everything captured so far to the file and updates the sizes in its header without closing it,
+// so the file is a valid WAV up to this point. Recordings that crash after a flush only lose what came after,
+// and other programs can read the file while it's still being recorded.
+func (a *AudioStream) Flush() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.status == StatusOff {
+		return fmt.Errorf("AudioStream must be on to flush")
+	}
+	reply := make(chan error)
+	a.fmFlush <- reply
+	return <-reply
+}
+
+// flushHeader writes the sizes of what the encoder has written so far into the header, the way
+// closing the encoder would, and syncs the file. The encoder writes a canonical 44 byte header.
+func flushHeader(fp *os.File, enc *wav.Encoder) error {
+	if enc.WrittenBytes == 0 {
+		// Nothing recorded yet, not even the header.
+		return nil
+	}
+	sizes := []struct {
+		offset int64
+		size   uint32
+	}{
+		{4, uint32(enc.WrittenBytes - 8)},
+		{40, uint32(enc.WrittenBytes - 44)},
+	}
+	for _, s := range sizes {
+		if _, err := fp.Seek(s.offset, io.SeekStart); err != nil {
+			return err
+		}
+		if err := binary.Write(fp, binary.LittleEndian, s.size); err != nil {
+			return err
+		}
+	}
+	if _, err := fp.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	return fp.Sync()
+}
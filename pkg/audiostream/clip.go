@@ -0,0 +1,57 @@
+package audiostream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yobert/alsa"
+
+	alsautil "github.com/renan-campos/sound-utils/pkg/alsa"
+)
+
+// ClipWarning says a captured chunk peaked above the clip warning threshold.
+type ClipWarning struct {
+	PeakDB float64
+	At     time.Time
+}
+
+// clipWarningInterval is the least time between two warnings, so a loud passage doesn't flood the channel.
+const clipWarningInterval = time.Second
+
+// clipDetector checks every chunk the data mover captures against the threshold.
+type clipDetector struct {
+	lock        sync.Mutex
+	enabled     bool
+	thresholdDB float64
+	last        time.Time
+	warnings    chan ClipWarning
+}
+
+func newClipDetector() *clipDetector {
+	return &clipDetector{warnings: make(chan ClipWarning, 1)}
+}
+
+func (d *clipDetector) set(thresholdDB float64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.enabled = true
+	d.thresholdDB = thresholdDB
+}
+
+func (d *clipDetector) check(chunk alsa.Buffer, now time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if !d.enabled || now.Sub(d.last) < clipWarningInterval {
+		return
+	}
+	peak := alsautil.SamplePeak(chunk)
+	if peak <= d.thresholdDB {
+		return
+	}
+	d.last = now
+	select {
+	case d.warnings <- ClipWarning{PeakDB: peak, At: now}:
+	default:
+		// Nobody's listening, don't hold up the capture.
+	}
+}
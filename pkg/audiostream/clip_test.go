@@ -0,0 +1,48 @@
+package audiostream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClipWarning(t *testing.T) {
+	d := newClipDetector()
+	now := time.Now()
+	d.check(s16Chunk(0, 32767, -32768), now)
+	select {
+	case w := <-d.warnings:
+		t.Fatalf("warned with clip warnings off: %+v", w)
+	default:
+	}
+
+	d.set(-1)
+	d.check(s16Chunk(0, 32767, -100), now)
+	select {
+	case w := <-d.warnings:
+		if w.PeakDB < -0.01 || !w.At.Equal(now) {
+			t.Errorf("got warning %+v, want one at 0dB", w)
+		}
+	default:
+		t.Fatal("no warning for a full scale chunk")
+	}
+
+	// Another clip within the interval is left out.
+	d.check(s16Chunk(32767), now.Add(clipWarningInterval/2))
+	select {
+	case w := <-d.warnings:
+		t.Errorf("warned again within the interval: %+v", w)
+	default:
+	}
+}
+
+func TestClipWarningBelowThreshold(t *testing.T) {
+	d := newClipDetector()
+	d.set(-1)
+	// Half scale is 6dB down.
+	d.check(s16Chunk(0, 16384, -16384), time.Now())
+	select {
+	case w := <-d.warnings:
+		t.Errorf("warned about a chunk at half scale: %+v", w)
+	default:
+	}
+}
@@ -0,0 +1,63 @@
+package audiostream
+
+import (
+	"testing"
+
+	"github.com/yobert/alsa"
+
+	alsautil "github.com/renan-campos/sound-utils/pkg/alsa"
+)
+
+func TestDeviceConfigBytes(t *testing.T) {
+	tests := []struct {
+		format   alsa.FormatType
+		channels int
+		frame    int
+	}{
+		{alsa.S16_LE, 1, 2},
+		{alsa.S16_LE, 2, 4},
+		{alsa.S24_LE, 1, 4}, // in a 4 byte container
+		{alsa.S24_LE, 2, 8},
+		{alsautil.S24_3LE, 1, 3},
+		{alsautil.S24_3LE, 2, 6},
+		{alsa.S32_LE, 1, 4},
+		{alsa.S32_LE, 2, 8},
+	}
+	for _, tt := range tests {
+		c := DeviceConfig{NumChannels: tt.channels, FrameRate: 44100, FrameFormat: tt.format}
+		if got := c.BytesPerFrame(); got != tt.frame {
+			t.Errorf("%v, %d channels: %d bytes a frame, want %d", tt.format, tt.channels, got, tt.frame)
+		}
+		if got := c.FrameBytesPerSecond(); got != tt.frame*44100 {
+			t.Errorf("%v, %d channels: %d bytes a second, want %d", tt.format, tt.channels, got, tt.frame*44100)
+		}
+	}
+}
+
+func TestSetDeviceValidates(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *DeviceConfig)
+	}{
+		{"no channels", func(c *DeviceConfig) { c.NumChannels = 0 }},
+		{"unsupported format", func(c *DeviceConfig) { c.FrameFormat = alsa.U8 }},
+		{"zero rate", func(c *DeviceConfig) { c.FrameRate = 0 }},
+		{"no buffer", func(c *DeviceConfig) { c.BufferSize = 0 }},
+	}
+	for _, tt := range tests {
+		a := NewAudioStream()
+		config := testConfig
+		tt.modify(&config)
+		if err := a.SetDevice(nil, config); err == nil {
+			t.Errorf("%s: took %+v", tt.name, config)
+		}
+		if a.deviceConfig != (DeviceConfig{}) {
+			t.Errorf("%s: kept the refused config %+v", tt.name, a.deviceConfig)
+		}
+	}
+
+	a := NewAudioStream()
+	if err := a.SetDevice(nil, testConfig); err != nil {
+		t.Errorf("refused %+v: %v", testConfig, err)
+	}
+}
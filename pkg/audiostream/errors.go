@@ -0,0 +1,62 @@
+package audiostream
+
+import "fmt"
+
+// FileError is sent on the Errors channel when the file mover can't create or write the recording.
+// Op says what it was doing.
+type FileError struct {
+	Op   string
+	File string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("failed to %s %s: %v", e.Op, e.File, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// Errors returns the channel file errors are sent to. After an error the stream stops writing
+// and its status goes to StatusError; turn it Off, fix the problem and start it again.
+func (a *AudioStream) Errors() <-chan error {
+	return a.errs
+}
+
+// fail reports an error from the file mover and puts the stream in the error status.
+func (a *AudioStream) fail(err error) {
+	select {
+	case a.errs <- err:
+	default:
+		// There's already an error nobody has read.
+	}
+	// The lock can be held by someone waiting on the file mover, so don't make it wait for the lock.
+	go func() {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		if a.status != StatusOff {
+			a.setStatus(StatusError)
+		}
+	}()
+}
+
+// failedFileMover stands in for the file mover after it failed, answering until the stream is turned off.
+// Nothing reads the ring buffer anymore, so it's closed to drop what the data mover captures from now on,
+// and what's already in it is thrown away so a write waiting for room doesn't hold the data mover up.
+func (a *AudioStream) failedFileMover(ringBuffer *RingBuffer, err error) {
+	ringBuffer.Close()
+	for {
+		select {
+		case status := <-a.fmStatus:
+			if status == StatusOff {
+				a.fmDone <- struct{}{}
+				return
+			}
+		case reply := <-a.fmFlush:
+			reply <- err
+		default:
+			ringBuffer.ReadNoBlock()
+		}
+	}
+}
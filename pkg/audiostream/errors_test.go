@@ -0,0 +1,85 @@
+package audiostream
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	alsautil "github.com/renan-campos/sound-utils/pkg/alsa"
+)
+
+func waitForError(t *testing.T, a *AudioStream) error {
+	t.Helper()
+	select {
+	case err := <-a.Errors():
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("no error reported")
+	}
+	return nil
+}
+
+func offWithin(t *testing.T, a *AudioStream, d time.Duration) {
+	t.Helper()
+	off := make(chan error)
+	go func() {
+		off <- a.Off()
+	}()
+	select {
+	case err := <-off:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(d):
+		t.Fatal("Off hung")
+	}
+}
+
+func TestFileErrorCreate(t *testing.T) {
+	a, _ := newTestStream(t, &nullSource{delay: time.Millisecond})
+	if err := a.SetFileName(filepath.Join(t.TempDir(), "missing", "out.wav")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Standby(); err != nil {
+		t.Fatal(err)
+	}
+	err := waitForError(t, a)
+	var fileErr *FileError
+	var notFound *alsautil.DirectoryNotFound
+	if !errors.As(err, &fileErr) || fileErr.Op != "create" || !errors.As(err, &notFound) {
+		t.Errorf("got %v, want a FileError creating the file in a missing directory", err)
+	}
+	if err := a.Flush(); err == nil {
+		t.Error("flushed a file that was never created")
+	}
+	offWithin(t, a, time.Second)
+}
+
+// Once writing fails nothing empties the ring buffer, the data mover mustn't get stuck waiting for room in it.
+func TestFileErrorWriteDoesNotHangOff(t *testing.T) {
+	a, _ := newTestStream(t, &nullSource{delay: time.Millisecond})
+	if err := a.SetFileName("/dev/full"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Standby(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Record(); err != nil {
+		t.Fatal(err)
+	}
+	err := waitForError(t, a)
+	var fileErr *FileError
+	if !errors.As(err, &fileErr) || fileErr.Op != "write to" {
+		t.Errorf("got %v, want a FileError writing the file", err)
+	}
+	// Long enough to fill the ring buffer several times over.
+	time.Sleep(100 * time.Millisecond)
+	a.lock.Lock()
+	status := a.status
+	a.lock.Unlock()
+	if status != StatusError {
+		t.Errorf("status %s after a file error", status)
+	}
+	offWithin(t, a, time.Second)
+}
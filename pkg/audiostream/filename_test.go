@@ -0,0 +1,76 @@
+package audiostream
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSetFileName(t *testing.T) {
+	tests := []struct {
+		status AudioStreamStatus
+		// into puts a stream recording to its first file in the status under test.
+		into func(t *testing.T, a *AudioStream)
+		ok   bool
+	}{
+		{StatusOff, func(*testing.T, *AudioStream) {}, true},
+		{StatusStandby, func(t *testing.T, a *AudioStream) {
+			if err := a.Standby(); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+		{StatusRecording, func(t *testing.T, a *AudioStream) {
+			if err := a.Standby(); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.Record(); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{StatusError, func(t *testing.T, a *AudioStream) {
+			if err := a.SetFileName("/dev/full"); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.Standby(); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.Record(); err != nil {
+				t.Fatal(err)
+			}
+			waitForError(t, a)
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			a, first := newTestStream(t, &nullSource{delay: time.Millisecond})
+			defer offWithin(t, a, time.Second)
+			tt.into(t, a)
+			before := a.GetFileName()
+			second := filepath.Join(filepath.Dir(first), "second.wav")
+			err := a.SetFileName(second)
+			if !tt.ok {
+				if err == nil {
+					t.Errorf("changed files while %s", tt.status)
+				}
+				if got := a.GetFileName(); got != before {
+					t.Errorf("file name %q after a refused change, want %q", got, before)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := a.GetFileName(); got != second {
+				t.Errorf("file name %q, want %q", got, second)
+			}
+			record(t, a, 50*time.Millisecond)
+			if len(wavData(t, second)) == 0 {
+				t.Error("nothing recorded to the new file")
+			}
+			if _, err := os.Stat(first); !os.IsNotExist(err) {
+				t.Errorf("the first file was left behind: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package audiostream
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-audio/wav"
+)
+
+// gatedSource is a nullSource that holds up every read after the first few until it's opened.
+type gatedSource struct {
+	nullSource
+	reads   int
+	waiting chan struct{} // gets a value when a read is held up
+	open    chan struct{}
+}
+
+func newGatedSource(reads int) *gatedSource {
+	return &gatedSource{
+		nullSource: nullSource{delay: time.Millisecond},
+		reads:      reads,
+		waiting:    make(chan struct{}, 1),
+		open:       make(chan struct{}),
+	}
+}
+
+func (s *gatedSource) Read(buf []byte) error {
+	if s.readCount() >= s.reads {
+		select {
+		case s.waiting <- struct{}{}:
+		default:
+		}
+		<-s.open
+	}
+	return s.nullSource.Read(buf)
+}
+
+// Flush writes out the chunks that don't make up a whole read of the ring buffer yet too,
+// leaving a file that holds every frame captured so far.
+func TestFlushWritesEverythingCaptured(t *testing.T) {
+	// A read of the ring buffer is 4 chunks of the data mover.
+	const chunks = 6
+	src := newGatedSource(chunks)
+	a, file := newTestStream(t, src)
+	if err := a.Standby(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Record(); err != nil {
+		t.Fatal(err)
+	}
+	// The data mover has put the last chunk in the ring buffer once it's waiting on the next.
+	select {
+	case <-src.waiting:
+	case <-time.After(time.Second):
+		t.Fatal("the data mover never caught up with the source")
+	}
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wav.NewDecoder(f).IsValidFile() {
+		t.Error("the flushed file isn't a valid WAV")
+	}
+	f.Close()
+	data := wavData(t, file)
+	if want := chunks * testChunkSamples * 2; len(data) != want {
+		t.Errorf("flushed %d bytes of samples, want %d", len(data), want)
+	}
+	var checker watermarkChecker
+	if gaps := checker.check(data); len(gaps) > 0 {
+		t.Errorf("flushed data has gaps: %v", gaps)
+	}
+
+	// Nothing is written twice once the read fills up.
+	close(src.open)
+	time.Sleep(50 * time.Millisecond)
+	offWithin(t, a, time.Second)
+	checker = watermarkChecker{}
+	data = wavData(t, file)
+	if gaps := checker.check(data); len(gaps) > 0 {
+		t.Errorf("recording has gaps after the flush: %v", gaps)
+	}
+	if len(data) <= chunks*testChunkSamples*2 {
+		t.Errorf("recorded %d bytes of samples, nothing after the flush", len(data))
+	}
+}
@@ -0,0 +1,58 @@
+package audiostream
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/yobert/alsa"
+
+	alsautil "github.com/renan-campos/sound-utils/pkg/alsa"
+)
+
+// headroomWindow is how far back the peak Headroom measures from goes.
+const headroomWindow = 3 * time.Second
+
+type chunkPeak struct {
+	db float64
+	at time.Time
+}
+
+// headroomMeter keeps the peaks of the chunks captured within the last headroomWindow.
+type headroomMeter struct {
+	lock  sync.Mutex
+	peaks []chunkPeak
+}
+
+func (m *headroomMeter) add(chunk alsa.Buffer, now time.Time) {
+	peak := alsautil.SamplePeak(chunk)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.peaks = append(m.expire(now), chunkPeak{db: peak, at: now})
+}
+
+// expire drops the peaks that have fallen out of the window.
+func (m *headroomMeter) expire(now time.Time) []chunkPeak {
+	i := 0
+	for i < len(m.peaks) && now.Sub(m.peaks[i].at) > headroomWindow {
+		i++
+	}
+	return m.peaks[i:]
+}
+
+func (m *headroomMeter) headroom(now time.Time) float64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.peaks = m.expire(now)
+	peak := math.Inf(-1)
+	for _, p := range m.peaks {
+		peak = math.Max(peak, p.db)
+	}
+	return -peak
+}
+
+// Headroom returns how far below full scale, in dB, the loudest sample captured in the last few seconds was.
+// 0 means the input is clipping. Before anything has been captured (or after a few seconds of standby) it's +Inf.
+func (a *AudioStream) Headroom() float64 {
+	return a.headroom.headroom(time.Now())
+}
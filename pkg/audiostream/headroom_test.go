@@ -0,0 +1,28 @@
+package audiostream
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHeadroom(t *testing.T) {
+	var m headroomMeter
+	start := time.Now()
+	if h := m.headroom(start); !math.IsInf(h, 1) {
+		t.Errorf("headroom before anything was captured is %v, want +Inf", h)
+	}
+
+	m.add(s16Chunk(0, 32767), start)                  // 0dB
+	m.add(s16Chunk(0, 16384), start.Add(time.Second)) // -6dB
+	if h := m.headroom(start.Add(2 * time.Second)); math.Abs(h) > 0.01 {
+		t.Errorf("headroom with a full scale peak in the window is %.2fdB, want 0", h)
+	}
+	// The full scale chunk has dropped out of the window, the half scale one hasn't.
+	if h := m.headroom(start.Add(headroomWindow + time.Second/2)); math.Abs(h-6.02) > 0.01 {
+		t.Errorf("headroom is %.2fdB, want 6.02", h)
+	}
+	if h := m.headroom(start.Add(2*headroomWindow + time.Second)); !math.IsInf(h, 1) {
+		t.Errorf("headroom once everything expired is %v, want +Inf", h)
+	}
+}
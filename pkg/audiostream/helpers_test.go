@@ -0,0 +1,110 @@
+package audiostream
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-audio/wav"
+	"github.com/yobert/alsa"
+)
+
+// nullSource stands in for a capture device, filling every read with a watermark.
+type nullSource struct {
+	lock   sync.Mutex
+	delay  time.Duration // How long a read takes, like waiting on a device.
+	next   uint16
+	reads  int
+	closed bool
+}
+
+func (s *nullSource) Read(buf []byte) error {
+	time.Sleep(s.delay)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.next = watermarkChunk(buf, s.next)
+	s.reads++
+	return nil
+}
+
+func (s *nullSource) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+}
+
+func (s *nullSource) readCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.reads
+}
+
+// testConfig captures 100 frames a second of mono S16_LE, so a chunk of the data mover is 200 samples.
+var testConfig = DeviceConfig{NumChannels: 1, FrameRate: 100, FrameFormat: alsa.S16_LE, BufferSize: 64}
+
+const testChunkSamples = 200
+
+// newTestStream returns a stream that captures from src into a file in a temporary directory.
+func newTestStream(t *testing.T, src captureSource) (*AudioStream, string) {
+	t.Helper()
+	a := NewAudioStream()
+	if err := a.SetDevice(nil, testConfig); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "out.wav")
+	if err := a.SetFileName(file); err != nil {
+		t.Fatal(err)
+	}
+	a.newSource = func(DeviceConfig) (captureSource, error) {
+		return src, nil
+	}
+	return &a, file
+}
+
+// wavData returns the 16-bit samples of a WAV file as little endian bytes.
+func wavData(t *testing.T, file string) []byte {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	buf, err := wav.NewDecoder(f).FullPCMBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 2*len(buf.Data))
+	for i, sample := range buf.Data {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(sample))
+	}
+	return data
+}
+
+func record(t *testing.T, a *AudioStream, d time.Duration) {
+	t.Helper()
+	if err := a.Standby(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Record(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(d)
+	if err := a.Off(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// s16Chunk is a chunk of mono S16_LE holding the samples, like the data mover captures.
+func s16Chunk(samples ...int16) alsa.Buffer {
+	data := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(s))
+	}
+	return alsa.Buffer{
+		Format: alsa.BufferFormat{SampleFormat: alsa.S16_LE, Rate: testConfig.FrameRate, Channels: 1},
+		Data:   data,
+	}
+}
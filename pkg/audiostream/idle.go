@@ -0,0 +1,36 @@
+package audiostream
+
+import "time"
+
+// idleTimer turns a stream off after it sits in standby for too long.
+// It's only touched with the stream's lock held. Each arming gets a new generation so
+// a timer that already fired but lost the race for the lock knows it's been superseded.
+type idleTimer struct {
+	timeout    time.Duration
+	timer      *time.Timer
+	generation int
+}
+
+// reset (re)starts the countdown, a zero timeout leaves it disarmed.
+func (t *idleTimer) reset(fire func(generation int)) {
+	t.stop()
+	if t.timeout <= 0 {
+		return
+	}
+	generation := t.generation
+	t.timer = time.AfterFunc(t.timeout, func() {
+		fire(generation)
+	})
+}
+
+func (t *idleTimer) stop() {
+	t.generation++
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+func (t *idleTimer) current(generation int) bool {
+	return t.timer != nil && generation == t.generation
+}
@@ -0,0 +1,82 @@
+package audiostream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordNeedsStandby(t *testing.T) {
+	a, _ := newTestStream(t, &nullSource{delay: time.Millisecond})
+	if err := a.Record(); err == nil {
+		t.Fatal("recorded while off")
+	}
+	// The data mover never got the bogus status, so turning off doesn't wait on it.
+	if err := a.Standby(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Record(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Record(); err == nil {
+		t.Error("recorded while recording")
+	}
+	off := make(chan error)
+	go func() {
+		off <- a.Off()
+	}()
+	select {
+	case err := <-off:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Off hung")
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	src := &nullSource{delay: time.Millisecond}
+	a, _ := newTestStream(t, src)
+	a.SetIdleTimeout(20 * time.Millisecond)
+	changes := a.StatusChanges()
+	if err := a.Standby(); err != nil {
+		t.Fatal(err)
+	}
+	if status := <-changes; status != StatusStandby {
+		t.Fatalf("got status %s, want standby", status)
+	}
+	select {
+	case status := <-changes:
+		if status != StatusOff {
+			t.Fatalf("got status %s, want off", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream didn't turn off after the idle timeout")
+	}
+	src.lock.Lock()
+	defer src.lock.Unlock()
+	if !src.closed {
+		t.Error("idle timeout left the source open")
+	}
+}
+
+func TestIdleTimeoutNotWhileRecording(t *testing.T) {
+	a, _ := newTestStream(t, &nullSource{delay: time.Millisecond})
+	a.SetIdleTimeout(20 * time.Millisecond)
+	if err := a.Standby(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Record(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	a.lock.Lock()
+	status := a.status
+	a.lock.Unlock()
+	if status != StatusRecording {
+		t.Errorf("status %s while recording past the idle timeout", status)
+	}
+	if err := a.Off(); err != nil {
+		t.Fatal(err)
+	}
+}
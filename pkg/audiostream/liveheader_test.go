@@ -0,0 +1,65 @@
+package audiostream
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetLiveHeader(t *testing.T) {
+	a, file := newTestStream(t, &nullSource{delay: time.Millisecond})
+	if err := a.SetLiveHeader(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Standby(); err != nil {
+		t.Fatal(err)
+	}
+	defer offWithin(t, a, time.Second)
+	if err := a.SetLiveHeader(false); err == nil {
+		t.Error("changed the header mode on standby")
+	}
+	if err := a.Record(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The header catches up with the file after every write, without waiting for the file to be finished.
+	// A read can land between a write and its header update, so look until one doesn't.
+	deadline := time.Now().Add(time.Second)
+	for {
+		data, err := os.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		if len(data) > 44 && int(binary.LittleEndian.Uint32(data[40:])) == len(data)-44 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the header never matched the %d bytes in the file", len(data))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// The header mode is guarded like the file name, setting it while another goroutine turns the stream on and off is safe.
+func TestSetLiveHeaderConcurrent(t *testing.T) {
+	a, _ := newTestStream(t, &nullSource{delay: time.Millisecond})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			a.SetLiveHeader(i%2 == 0)
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		if err := a.Standby(); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Off(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,143 @@
+package audiostream
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yobert/alsa"
+
+	alsautil "github.com/renan-campos/sound-utils/pkg/alsa"
+)
+
+// cueMark is a labelled position in the recording, in frames from the start of the file.
+type cueMark struct {
+	frame int64
+	label string
+}
+
+// marker follows how many frames the data mover has captured into the file, so a mark can be placed
+// at the frame being captured when it's made rather than the one the file mover happens to be writing.
+type marker struct {
+	lock        sync.Mutex
+	rate        int
+	frames      int64     // frames captured while recording
+	lastRead    time.Time // when the last read finished, or recording started
+	chunkFrames int       // frames in a read
+	marks       []cueMark
+}
+
+// start is called when recording starts, the next read begins capturing now.
+func (m *marker) start(now time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.lastRead = now
+}
+
+// read is called by the data mover after every read while recording.
+func (m *marker) read(chunk alsa.Buffer, now time.Time) {
+	frameSize := alsautil.SampleSize(chunk.Format.SampleFormat) * chunk.Format.Channels
+	if frameSize == 0 {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.rate = chunk.Format.Rate
+	m.chunkFrames = len(chunk.Data) / frameSize
+	m.frames += int64(m.chunkFrames)
+	m.lastRead = now
+}
+
+// mark places a mark at the frame the device is capturing at now: everything read so far
+// plus however far into the read in progress it has got.
+func (m *marker) mark(label string, now time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	frame := m.frames
+	if m.rate > 0 {
+		elapsed := int64(now.Sub(m.lastRead).Seconds() * float64(m.rate))
+		if elapsed > int64(m.chunkFrames) {
+			elapsed = int64(m.chunkFrames)
+		}
+		frame += elapsed
+	}
+	m.marks = append(m.marks, cueMark{frame: frame, label: label})
+}
+
+// take returns the marks made so far and starts over for the next file.
+func (m *marker) take() []cueMark {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	marks := m.marks
+	m.marks = nil
+	m.frames = 0
+	return marks
+}
+
+// Mark bookmarks the moment being recorded with a label. The marks are written to the file as cue points
+// (a cue chunk, and the labels in a LIST adtl chunk) when the stream is turned off.
+func (a *AudioStream) Mark(label string) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.status != StatusRecording {
+		return fmt.Errorf("AudioStream must be recording to mark it")
+	}
+	a.marks.mark(label, time.Now())
+	return nil
+}
+
+// appendCues adds the cue and LIST adtl chunks for the marks to the end of a finished WAV file and fixes up its RIFF size.
+func appendCues(fp *os.File, marks []cueMark) error {
+	if len(marks) == 0 {
+		return nil
+	}
+	if _, err := fp.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+
+	cue := []interface{}{[4]byte{'c', 'u', 'e', ' '}, uint32(4 + 24*len(marks)), uint32(len(marks))}
+	for i, m := range marks {
+		cue = append(cue,
+			uint32(i+1),                 // ID
+			uint32(m.frame),             // position
+			[4]byte{'d', 'a', 't', 'a'}, // chunk the cue is in
+			uint32(0),                   // chunk start
+			uint32(0),                   // block start
+			uint32(m.frame),             // sample offset
+		)
+	}
+
+	var labels []interface{}
+	listSize := 4
+	for i, m := range marks {
+		text := append([]byte(m.label), 0)
+		size := 4 + len(text)
+		if len(text)%2 == 1 {
+			text = append(text, 0)
+		}
+		labels = append(labels, [4]byte{'l', 'a', 'b', 'l'}, uint32(size), uint32(i+1), text)
+		listSize += 8 + 4 + len(text)
+	}
+	list := append([]interface{}{[4]byte{'L', 'I', 'S', 'T'}, uint32(listSize), [4]byte{'a', 'd', 't', 'l'}}, labels...)
+
+	for _, v := range append(cue, list...) {
+		if err := binary.Write(fp, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	end, err := fp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := fp.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+	if err := binary.Write(fp, binary.LittleEndian, uint32(end-8)); err != nil {
+		return err
+	}
+	return fp.Sync()
+}
@@ -0,0 +1,80 @@
+package audiostream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	alsautil "github.com/renan-campos/sound-utils/pkg/alsa"
+)
+
+func TestMarks(t *testing.T) {
+	a, file := newTestStream(t, &nullSource{delay: time.Millisecond})
+	if err := a.Mark("too early"); err == nil {
+		t.Error("marked a stream that's off")
+	}
+	if err := a.Standby(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Record(); err != nil {
+		t.Fatal(err)
+	}
+	for _, label := range []string{"one", "two"} {
+		time.Sleep(20 * time.Millisecond)
+		if err := a.Mark(label); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := a.Off(); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, err := alsautil.ListChunks(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := wavData(t, file)
+	body := map[string][]byte{}
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range chunks {
+		body[c.ID] = contents[c.Offset+8 : c.Offset+8+c.Size]
+	}
+	if size := binary.LittleEndian.Uint32(contents[4:]); int(size) != len(contents)-8 {
+		t.Errorf("RIFF size %d for a %d byte file", size, len(contents))
+	}
+
+	cue := body["cue "]
+	if len(cue) != 4+2*24 || binary.LittleEndian.Uint32(cue) != 2 {
+		t.Fatalf("cue chunk %x, want 2 points", cue)
+	}
+	first := binary.LittleEndian.Uint32(cue[4+4:])
+	second := binary.LittleEndian.Uint32(cue[4+24+4:])
+	if first > second || int(second) > len(data)/2 {
+		t.Errorf("cue points at frames %d and %d of %d", first, second, len(data)/2)
+	}
+	if list := body["LIST"]; !bytes.Contains(list, []byte("one\x00")) || !bytes.Contains(list, []byte("two\x00")) {
+		t.Errorf("LIST chunk %q is missing the labels", list)
+	}
+}
+
+func TestAppendCuesReportsErrors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "out.wav")
+	if err := os.WriteFile(file, make([]byte, 44), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fp, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	if err := appendCues(fp, []cueMark{{frame: 1, label: "read only"}}); err == nil {
+		t.Error("wrote the marks to a read only file")
+	}
+}
@@ -1,6 +1,9 @@
 package audiostream
 
-import "sync"
+import (
+	"fmt"
+	"sync"
+)
 
 type RingBuffer struct {
 	data      []byte
@@ -8,33 +11,103 @@ type RingBuffer struct {
 	readIdx   int
 	writeSize int
 	readSize  int
+	peeked    int // bytes at readIdx ReadPartial already handed out
+	fill      FillMode
+	frameSize int
+	written   int64
+	dropouts  []Dropout
+	closed    bool
 	rSem      chan struct{}
 	wSem      chan struct{}
 	rLock     sync.Mutex
 }
 
+// FillMode is what a write shorter than the write size gets padded with.
+type FillMode int
+
+const (
+	// FillZero pads with silence.
+	FillZero FillMode = iota
+	// FillHoldLast repeats the last frame written, which is less jarring than silence on a brief underrun.
+	FillHoldLast
+	// FillMarkDropout pads with silence and records where the padding went, see Dropouts.
+	FillMarkDropout
+)
+
+// Dropout is a stretch of padding, in bytes from the start of everything written to the ring buffer.
+type Dropout struct {
+	Offset int64
+	Length int
+}
+
+// Padding describes the padding a write added.
+type Padding struct {
+	Mode  FillMode
+	Bytes int
+}
+
 type RingBufferSpec struct {
 	DataSize  int
 	WriteSize int
 	ReadSize  int
+	// Fill is the padding used for short writes.
+	Fill FillMode
+	// FrameSize is the size of the frame FillHoldLast repeats. Defaults to 1 byte.
+	FrameSize int
 }
 
-func NewRingBuffer(spec RingBufferSpec) RingBuffer {
+// NewRingBuffer checks the sizes fit together: the reads and writes wrap around at the end of the data,
+// so DataSize has to be a multiple of both WriteSize and ReadSize.
+func NewRingBuffer(spec RingBufferSpec) (RingBuffer, error) {
+	if spec.WriteSize <= 0 || spec.ReadSize <= 0 || spec.DataSize <= 0 {
+		return RingBuffer{}, fmt.Errorf("ring buffer sizes must be positive, got data %d, write %d, read %d",
+			spec.DataSize, spec.WriteSize, spec.ReadSize)
+	}
+	if spec.DataSize%spec.WriteSize != 0 || spec.DataSize%spec.ReadSize != 0 {
+		return RingBuffer{}, fmt.Errorf("ring buffer data size %d is not a multiple of the write size %d and read size %d",
+			spec.DataSize, spec.WriteSize, spec.ReadSize)
+	}
 	data := make([]byte, spec.DataSize)
+	frameSize := spec.FrameSize
+	if frameSize < 1 {
+		frameSize = 1
+	}
 	return RingBuffer{
 		data:      data,
 		writeIdx:  0,
 		readIdx:   0,
 		writeSize: spec.WriteSize,
 		readSize:  spec.ReadSize,
+		fill:      spec.Fill,
+		frameSize: frameSize,
 		rSem:      make(chan struct{}, spec.DataSize/spec.ReadSize),
 		wSem:      make(chan struct{}, spec.DataSize/spec.WriteSize),
-	}
+	}, nil
+}
+
+// Dropouts returns where short writes were padded when the fill mode is FillMarkDropout.
+func (rb *RingBuffer) Dropouts() []Dropout {
+	rb.rLock.Lock()
+	defer rb.rLock.Unlock()
+	dropouts := make([]Dropout, len(rb.dropouts))
+	copy(dropouts, rb.dropouts)
+	return dropouts
 }
 
-func (rb *RingBuffer) Write(buff []byte) {
+// Write always stores writeSize bytes. Longer buffers are cut, shorter ones are padded according to the fill mode.
+// Writes after Close are dropped.
+func (rb *RingBuffer) Write(buff []byte) Padding {
+	rb.rLock.Lock()
+	closed := rb.closed
+	rb.rLock.Unlock()
+	if closed {
+		return Padding{}
+	}
 
 	rb.wSem <- struct{}{}
+	// ReadPartial reads up to the write position while writes are going on.
+	rb.rLock.Lock()
+	defer rb.rLock.Unlock()
 
 	if len(buff) > rb.writeSize {
 		buff = buff[:rb.writeSize]
@@ -43,8 +116,20 @@ func (rb *RingBuffer) Write(buff []byte) {
 		rb.data[rb.writeIdx] = b
 		rb.writeIdx++
 	}
-	for i := 0; i < rb.writeSize-len(buff); i++ {
-		rb.data[rb.writeIdx] = 0
+	padding := Padding{Mode: rb.fill, Bytes: rb.writeSize - len(buff)}
+	var lastFrame []byte
+	if padding.Bytes > 0 && rb.fill == FillHoldLast {
+		lastFrame = make([]byte, rb.frameSize)
+		for i := range lastFrame {
+			lastFrame[i] = rb.data[(rb.writeIdx-rb.frameSize+i+len(rb.data))%len(rb.data)]
+		}
+	}
+	for i := 0; i < padding.Bytes; i++ {
+		if lastFrame != nil {
+			rb.data[rb.writeIdx] = lastFrame[i%len(lastFrame)]
+		} else {
+			rb.data[rb.writeIdx] = 0
+		}
 		rb.writeIdx++
 	}
 	if rb.writeIdx%rb.readSize == 0 {
@@ -56,12 +141,16 @@ func (rb *RingBuffer) Write(buff []byte) {
 	// In this ring buffer, we don't want writes to be blocked.
 	// That means that if the write pointer has reached the read pointer
 	// its time to move the read pointer up a read chunk.
-	rb.rLock.Lock()
-	defer rb.rLock.Unlock()
+	if padding.Bytes > 0 && rb.fill == FillMarkDropout {
+		rb.dropouts = append(rb.dropouts, Dropout{Offset: rb.written + int64(len(buff)), Length: padding.Bytes})
+	}
+	rb.written += int64(rb.writeSize)
 	if rb.writeIdx == rb.readIdx {
-		rb.readIdx += rb.readSize
+		rb.readIdx = (rb.readIdx + rb.readSize) % len(rb.data)
+		rb.peeked = 0
 		<-rb.rSem
 	}
+	return padding
 }
 
 func (rb *RingBuffer) ReadNoBlock() ([]byte, bool) {
@@ -86,6 +175,55 @@ func (rb *RingBuffer) ReadNoBlock() ([]byte, bool) {
 	if rb.readIdx == len(rb.data) {
 		rb.readIdx = 0
 	}
+	buff = buff[rb.peeked:]
+	rb.peeked = 0
+
+	return buff, true
+}
 
+// ReadPartial returns what was written past the last full read that hasn't been handed out yet,
+// without waiting for the read to fill up. The next ReadNoBlock or Drain leaves out what it returned.
+func (rb *RingBuffer) ReadPartial() ([]byte, bool) {
+	rb.rLock.Lock()
+	defer rb.rLock.Unlock()
+	return rb.readPartial()
+}
+
+// readPartial is ReadPartial for callers holding rLock.
+func (rb *RingBuffer) readPartial() ([]byte, bool) {
+	n := (rb.writeIdx - rb.readIdx + len(rb.data)) % len(rb.data)
+	if n > rb.readSize {
+		n = rb.readSize
+	}
+	if n <= rb.peeked {
+		return nil, false
+	}
+	buff := make([]byte, n-rb.peeked)
+	for i := range buff {
+		buff[i] = rb.data[(rb.readIdx+rb.peeked+i)%len(rb.data)]
+	}
+	rb.peeked = n
 	return buff, true
 }
+
+// Close says nothing more will be written, so Drain can hand out the last partial read.
+func (rb *RingBuffer) Close() {
+	rb.rLock.Lock()
+	defer rb.rLock.Unlock()
+	rb.closed = true
+}
+
+// Drain is ReadNoBlock for emptying a closed ring buffer: once the full reads are gone,
+// it returns whatever was written past the last read size boundary, which ReadNoBlock never does.
+func (rb *RingBuffer) Drain() ([]byte, bool) {
+	if buff, read := rb.ReadNoBlock(); read {
+		return buff, true
+	}
+	rb.rLock.Lock()
+	defer rb.rLock.Unlock()
+	if !rb.closed {
+		return nil, false
+	}
+	// Nothing's written after Close, so there's never more than the partial read left.
+	return rb.readPartial()
+}
@@ -0,0 +1,171 @@
+package audiostream
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// A writer that laps the reader pushes the read position forward, which has to wrap around
+// like every other move of it does.
+func TestRingBufferOverrunAtEnd(t *testing.T) {
+	ringBuffer, err := NewRingBuffer(RingBufferSpec{DataSize: 16, WriteSize: 4, ReadSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var next uint16
+	chunk := make([]byte, 4)
+	write := func(n int) {
+		for i := 0; i < n; i++ {
+			next = watermarkChunk(chunk, next)
+			ringBuffer.Write(chunk)
+		}
+	}
+	var checker watermarkChecker
+	var gaps []gap
+	reads := 0
+	read := func() {
+		for data, ok := ringBuffer.ReadNoBlock(); ok; data, ok = ringBuffer.ReadNoBlock() {
+			gaps = append(gaps, checker.check(data)...)
+			reads++
+		}
+	}
+
+	// Leave the reader at the last read of the data, then lap it there.
+	write(3)
+	read()
+	write(4)
+	read()
+
+	if reads != 6 {
+		t.Errorf("got %d reads, want 6", reads)
+	}
+	// The overrun drops the oldest chunk, the fourth one written.
+	if want := (gap{offset: 6, expected: 6, got: 8}); len(gaps) != 1 || gaps[0] != want {
+		t.Errorf("got gaps %v, want %v", gaps, want)
+	}
+}
+
+// underrun writes a full chunk and then a short one through a ring buffer with the fill mode,
+// returning what comes out and the padding of the short write.
+func underrun(t *testing.T, fill FillMode) ([]byte, Padding, *RingBuffer) {
+	t.Helper()
+	ringBuffer, err := NewRingBuffer(RingBufferSpec{DataSize: 16, WriteSize: 4, ReadSize: 8, Fill: fill, FrameSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ringBuffer.Write([]byte{1, 2, 3, 4})
+	padding := ringBuffer.Write([]byte{5})
+	data, ok := ringBuffer.ReadNoBlock()
+	if !ok {
+		t.Fatal("nothing to read after two writes")
+	}
+	return data, padding, &ringBuffer
+}
+
+func TestRingBufferFillZero(t *testing.T) {
+	data, padding, ringBuffer := underrun(t, FillZero)
+	if want := []byte{1, 2, 3, 4, 5, 0, 0, 0}; !bytes.Equal(data, want) {
+		t.Errorf("read %v, want %v", data, want)
+	}
+	if want := (Padding{Mode: FillZero, Bytes: 3}); padding != want {
+		t.Errorf("padding %+v, want %+v", padding, want)
+	}
+	if dropouts := ringBuffer.Dropouts(); len(dropouts) != 0 {
+		t.Errorf("FillZero marked dropouts %v", dropouts)
+	}
+}
+
+func TestRingBufferFillHoldLast(t *testing.T) {
+	data, padding, _ := underrun(t, FillHoldLast)
+	// The last whole frame written is {4, 5}.
+	if want := []byte{1, 2, 3, 4, 5, 4, 5, 4}; !bytes.Equal(data, want) {
+		t.Errorf("read %v, want %v", data, want)
+	}
+	if want := (Padding{Mode: FillHoldLast, Bytes: 3}); padding != want {
+		t.Errorf("padding %+v, want %+v", padding, want)
+	}
+}
+
+func TestRingBufferFillMarkDropout(t *testing.T) {
+	data, padding, ringBuffer := underrun(t, FillMarkDropout)
+	if want := []byte{1, 2, 3, 4, 5, 0, 0, 0}; !bytes.Equal(data, want) {
+		t.Errorf("read %v, want %v", data, want)
+	}
+	if want := (Padding{Mode: FillMarkDropout, Bytes: 3}); padding != want {
+		t.Errorf("padding %+v, want %+v", padding, want)
+	}
+	ringBuffer.Write([]byte{6, 7})
+	want := []Dropout{{Offset: 5, Length: 3}, {Offset: 10, Length: 2}}
+	if dropouts := ringBuffer.Dropouts(); !reflect.DeepEqual(dropouts, want) {
+		t.Errorf("dropouts %v, want %v", dropouts, want)
+	}
+}
+
+// Drain hands out the full reads and then the partial one, but only once the ring buffer is closed.
+func TestRingBufferDrain(t *testing.T) {
+	ringBuffer, err := NewRingBuffer(RingBufferSpec{DataSize: 16, WriteSize: 2, ReadSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ringBuffer.Write([]byte{1, 2})
+	ringBuffer.Write([]byte{3, 4})
+	ringBuffer.Write([]byte{5, 6})
+
+	if data, ok := ringBuffer.Drain(); !ok || !bytes.Equal(data, []byte{1, 2, 3, 4}) {
+		t.Fatalf("drained %v, %v, want the full read", data, ok)
+	}
+	if data, ok := ringBuffer.Drain(); ok {
+		t.Fatalf("drained %v before Close", data)
+	}
+
+	ringBuffer.Close()
+	if padding := ringBuffer.Write([]byte{7, 8}); padding != (Padding{}) {
+		t.Errorf("write after Close padded %+v", padding)
+	}
+	if data, ok := ringBuffer.Drain(); !ok || !bytes.Equal(data, []byte{5, 6}) {
+		t.Errorf("drained %v, %v, want the partial read", data, ok)
+	}
+	if data, ok := ringBuffer.Drain(); ok {
+		t.Errorf("drained %v after everything was read", data)
+	}
+}
+
+// A reader waiting on Drain for the end of the data gets it once the writer closes the ring buffer.
+func TestRingBufferDrainWakesOnClose(t *testing.T) {
+	ringBuffer, err := NewRingBuffer(RingBufferSpec{DataSize: 16, WriteSize: 2, ReadSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ringBuffer.Write([]byte{1, 2})
+
+	closed := make(chan struct{})
+	drained := make(chan []byte)
+	go func() {
+		for {
+			if data, ok := ringBuffer.Drain(); ok {
+				select {
+				case <-closed:
+				default:
+					t.Error("drained before Close")
+				}
+				drained <- data
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	close(closed)
+	ringBuffer.Close()
+	select {
+	case data := <-drained:
+		if !bytes.Equal(data, []byte{1, 2}) {
+			t.Errorf("drained %v, want [1 2]", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the reader never got the data after Close")
+	}
+}
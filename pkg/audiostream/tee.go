@@ -0,0 +1,43 @@
+package audiostream
+
+import "sync"
+
+// tee fans the captured chunks out to any number of monitors, such as a level meter or a live playback.
+// The file always gets every frame through the ring buffer. Monitors get a copy of each chunk when
+// they're keeping up, and miss chunks when they aren't, so a slow monitor never stalls the capture.
+type tee struct {
+	lock     sync.Mutex
+	monitors []chan []byte
+}
+
+func (t *tee) add(depth int) <-chan []byte {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	monitor := make(chan []byte, depth)
+	t.monitors = append(t.monitors, monitor)
+	return monitor
+}
+
+// close closes the monitors, so ranging over them ends, and forgets them.
+func (t *tee) close() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, monitor := range t.monitors {
+		close(monitor)
+	}
+	t.monitors = nil
+}
+
+func (t *tee) send(chunk []byte) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, monitor := range t.monitors {
+		data := make([]byte, len(chunk))
+		copy(data, chunk)
+		select {
+		case monitor <- data:
+		default:
+			// Monitor is behind, drop the chunk for it.
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package audiostream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlowMonitorDoesNotStallFile(t *testing.T) {
+	src := &nullSource{delay: time.Millisecond}
+	a, file := newTestStream(t, src)
+	// Nobody reads this one until the stream is off.
+	monitor := a.Monitor(1)
+	record(t, a, 200*time.Millisecond)
+
+	chunks := 0
+	for range monitor {
+		chunks++
+	}
+	if chunks > 1 {
+		t.Errorf("monitor holding 1 chunk got %d", chunks)
+	}
+
+	data := wavData(t, file)
+	var checker watermarkChecker
+	if gaps := checker.check(data); len(gaps) > 0 {
+		t.Errorf("file lost frames: %v", gaps)
+	}
+	if want := src.readCount() * testChunkSamples * 2; len(data) != want {
+		t.Errorf("file has %d bytes of audio, %d were captured", len(data), want)
+	}
+}
+
+func TestMonitorClosedOnOff(t *testing.T) {
+	a, _ := newTestStream(t, &nullSource{delay: time.Millisecond})
+	monitor := a.Monitor(100)
+	record(t, a, 50*time.Millisecond)
+
+	done := make(chan int)
+	go func() {
+		chunks := 0
+		for range monitor {
+			chunks++
+		}
+		done <- chunks
+	}()
+	select {
+	case chunks := <-done:
+		if chunks == 0 {
+			t.Error("monitor got nothing while recording")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("monitor still open after Off")
+	}
+}
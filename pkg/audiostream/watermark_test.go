@@ -0,0 +1,126 @@
+package audiostream
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+)
+
+/*
+Watermarking checks the capture pipeline for lost frames.
+Instead of audio, the source fills every 16-bit sample with a counter that goes up by one,
+wrapping around at 65535. Anything that comes out the other end with a jump in the counter
+lost (or repeated) data, and the offset of the jump says where.
+*/
+
+// gap is a break in a watermarked stream, offset is in samples from the start of the stream.
+type gap struct {
+	offset   int
+	expected uint16
+	got      uint16
+}
+
+func (g gap) String() string {
+	return fmt.Sprintf("sample %d: expected %d, got %d (%d samples missing)",
+		g.offset, g.expected, g.got, int(g.got-g.expected))
+}
+
+// watermarkChunk fills the chunk with little endian 16-bit counter values starting at next,
+// returning the value the next chunk should start at.
+func watermarkChunk(chunk []byte, next uint16) uint16 {
+	for off := 0; off+1 < len(chunk); off += 2 {
+		binary.LittleEndian.PutUint16(chunk[off:], next)
+		next++
+	}
+	return next
+}
+
+// watermarkChecker follows a watermarked stream across chunks.
+type watermarkChecker struct {
+	next    uint16
+	offset  int
+	started bool
+}
+
+// check returns the breaks in the counter within the chunk and between it and the previous one.
+// The checker picks up the new count after a break, so every loss is reported once.
+func (c *watermarkChecker) check(chunk []byte) []gap {
+	var gaps []gap
+	for off := 0; off+1 < len(chunk); off += 2 {
+		got := binary.LittleEndian.Uint16(chunk[off:])
+		if c.started && got != c.next {
+			gaps = append(gaps, gap{offset: c.offset, expected: c.next, got: got})
+		}
+		c.started = true
+		c.next = got + 1
+		c.offset++
+	}
+	return gaps
+}
+
+// checkRingBuffer pushes writes watermarked chunks of the spec's write size through a ring buffer,
+// reading whenever a read is ready like the file mover does, and returns where the output broke.
+func checkRingBuffer(t *testing.T, spec RingBufferSpec, writes int) []gap {
+	t.Helper()
+	ringBuffer, err := NewRingBuffer(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var checker watermarkChecker
+	var gaps []gap
+	var next uint16
+	chunk := make([]byte, spec.WriteSize)
+	for i := 0; i < writes; i++ {
+		next = watermarkChunk(chunk, next)
+		ringBuffer.Write(chunk)
+		for data, read := ringBuffer.ReadNoBlock(); read; data, read = ringBuffer.ReadNoBlock() {
+			gaps = append(gaps, checker.check(data)...)
+		}
+	}
+	return gaps
+}
+
+func TestWatermarkChecker(t *testing.T) {
+	chunk := make([]byte, 20)
+	next := watermarkChunk(chunk, 65530)
+	var checker watermarkChecker
+	if gaps := checker.check(chunk); len(gaps) > 0 {
+		t.Errorf("gaps across the wrap around: %v", gaps)
+	}
+	watermarkChunk(chunk, next+3)
+	gaps := checker.check(chunk)
+	if want := []gap{{offset: 10, expected: next, got: next + 3}}; len(gaps) != 1 || gaps[0] != want[0] {
+		t.Errorf("got gaps %v, want %v", gaps, want)
+	}
+}
+
+// With a reader that keeps up, the ring buffer passes on every frame.
+func TestRingBufferWatermark(t *testing.T) {
+	specs := []RingBufferSpec{
+		{DataSize: 16, WriteSize: 2, ReadSize: 4},
+		{DataSize: 40, WriteSize: 4, ReadSize: 8},
+		{DataSize: 400, WriteSize: 20, ReadSize: 80},
+	}
+	for _, spec := range specs {
+		if gaps := checkRingBuffer(t, spec, 1000); len(gaps) > 0 {
+			t.Errorf("%+v: %d gaps, the first at %v", spec, len(gaps), gaps[0])
+		}
+	}
+}
+
+// Every frame the source hands the data mover ends up in the file, in order.
+func TestRecordingWatermark(t *testing.T) {
+	src := &nullSource{delay: time.Millisecond}
+	a, file := newTestStream(t, src)
+	record(t, a, 200*time.Millisecond)
+
+	data := wavData(t, file)
+	var checker watermarkChecker
+	if gaps := checker.check(data); len(gaps) > 0 {
+		t.Errorf("%d gaps, the first at %v", len(gaps), gaps[0])
+	}
+	if want := src.readCount() * testChunkSamples * 2; len(data) != want {
+		t.Errorf("file has %d bytes of audio, %d were captured", len(data), want)
+	}
+}
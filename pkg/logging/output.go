@@ -2,17 +2,109 @@ package logging
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"sync"
 )
 
-func Stderr(format string, a ...interface{}) {
-	fmt.Fprintf(os.Stderr, "Error: "+format+"\n", a...)
+// Level is how important a message is. Messages below the level set with SetLevel aren't shown.
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+func (l Level) String() string {
+	switch l {
+	case Debug:
+		return "debug"
+	case Info:
+		return "info"
+	case Warn:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// lock guards the level and the outputs, and keeps messages from different goroutines from interleaving.
+var lock sync.Mutex
+
+var level = Info
+
+var (
+	output      io.Writer = os.Stdout
+	errorOutput io.Writer = os.Stderr
+)
+
+// SetOutput sends debug and info messages to w instead of stdout.
+func SetOutput(w io.Writer) {
+	lock.Lock()
+	defer lock.Unlock()
+	output = w
+}
+
+// SetErrorOutput sends warnings and errors to w instead of stderr.
+func SetErrorOutput(w io.Writer) {
+	lock.Lock()
+	defer lock.Unlock()
+	errorOutput = w
+}
+
+// SetLevel hides the messages below l.
+func SetLevel(l Level) {
+	lock.Lock()
+	defer lock.Unlock()
+	level = l
 }
 
+// DisplayDebug shows debug messages whatever the level, as it did before there were levels.
+// Set it before anything is logged; SetLevel(Debug) does the same and is safe to call at any time.
 var DisplayDebug bool
 
-func Debugf(format string, a ...interface{}) {
-	if DisplayDebug {
-		fmt.Fprintf(os.Stdout, format, a...)
+// logf writes the message if l is shown, warnings and errors to the error output with a prefix.
+// Every message goes on its own line, so formats don't end with a newline.
+func logf(l Level, format string, a ...interface{}) {
+	lock.Lock()
+	defer lock.Unlock()
+	if l < level && !(l == Debug && DisplayDebug) {
+		return
+	}
+	w, prefix := output, ""
+	switch l {
+	case Warn:
+		w, prefix = errorOutput, "Warning: "
+	case Error:
+		w, prefix = errorOutput, "Error: "
 	}
+	fmt.Fprintf(w, prefix+format+"\n", a...)
+}
+
+// Stderr is Errorf, from before there were levels.
+func Stderr(format string, a ...interface{}) {
+	Errorf(format, a...)
+}
+
+// Debugf reports details that only help when something's being tracked down.
+func Debugf(format string, a ...interface{}) {
+	logf(Debug, format, a...)
+}
+
+// Infof reports progress worth seeing on a normal run.
+func Infof(format string, a ...interface{}) {
+	logf(Info, format, a...)
+}
+
+// Warnf reports something that didn't go as asked but didn't stop anything either.
+func Warnf(format string, a ...interface{}) {
+	logf(Warn, format, a...)
+}
+
+// Errorf reports something that failed.
+func Errorf(format string, a ...interface{}) {
+	logf(Error, format, a...)
 }
@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+// capture sends both outputs to buffers for the test, putting the level and outputs back afterwards.
+func capture(t *testing.T) (out, errOut *bytes.Buffer) {
+	t.Helper()
+	out, errOut = &bytes.Buffer{}, &bytes.Buffer{}
+	lock.Lock()
+	savedLevel, savedOutput, savedErrorOutput, savedDebug := level, output, errorOutput, DisplayDebug
+	output, errorOutput = out, errOut
+	lock.Unlock()
+	t.Cleanup(func() {
+		lock.Lock()
+		defer lock.Unlock()
+		level, output, errorOutput, DisplayDebug = savedLevel, savedOutput, savedErrorOutput, savedDebug
+	})
+	return out, errOut
+}
+
+func logAll() {
+	Debugf("debug %d", 1)
+	Infof("info %d", 2)
+	Warnf("warn %d", 3)
+	Errorf("error %d", 4)
+}
+
+func TestLevels(t *testing.T) {
+	tests := []struct {
+		level       Level
+		out, errOut string
+	}{
+		{Debug, "debug 1\ninfo 2\n", "Warning: warn 3\nError: error 4\n"},
+		{Info, "info 2\n", "Warning: warn 3\nError: error 4\n"},
+		{Warn, "", "Warning: warn 3\nError: error 4\n"},
+		{Error, "", "Error: error 4\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			out, errOut := capture(t)
+			SetLevel(tt.level)
+			logAll()
+			if out.String() != tt.out || errOut.String() != tt.errOut {
+				t.Errorf("got output %q and error output %q, want %q and %q", out, errOut, tt.out, tt.errOut)
+			}
+		})
+	}
+}
+
+func TestDisplayDebug(t *testing.T) {
+	out, _ := capture(t)
+	SetLevel(Error)
+	DisplayDebug = true
+	logAll()
+	if want := "debug 1\n"; out.String() != want {
+		t.Errorf("got output %q, want %q", out, want)
+	}
+}
+
+// Changing the level while other goroutines log is safe, and their messages don't interleave.
+func TestConcurrentLogging(t *testing.T) {
+	out, _ := capture(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				Infof("message")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				SetLevel(Level(j % 2))
+			}
+		}()
+	}
+	wg.Wait()
+	for _, line := range bytes.Split(bytes.TrimSuffix(out.Bytes(), []byte("\n")), []byte("\n")) {
+		if string(line) != "message" {
+			t.Fatalf("got line %q", line)
+		}
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	capture(t)
+	var out, errOut bytes.Buffer
+	SetOutput(&out)
+	SetErrorOutput(&errOut)
+	SetLevel(Debug)
+	logAll()
+	if want := "debug 1\ninfo 2\n"; out.String() != want {
+		t.Errorf("got output %q, want %q", out.String(), want)
+	}
+	if want := "Warning: warn 3\nError: error 4\n"; errOut.String() != want {
+		t.Errorf("got error output %q, want %q", errOut.String(), want)
+	}
+}
+
+// Swapping the outputs while other goroutines log is safe, every message lands in one of them whole.
+func TestConcurrentSetOutput(t *testing.T) {
+	_, errOut := capture(t)
+	buffers := []*bytes.Buffer{{}, {}}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				Warnf("message")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				SetOutput(buffers[j%2])
+				SetErrorOutput(buffers[(j+1)%2])
+			}
+		}()
+	}
+	wg.Wait()
+	var messages int
+	for _, buf := range append(buffers, errOut) {
+		for _, line := range bytes.SplitAfter(buf.Bytes(), []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			if string(line) != "Warning: message\n" {
+				t.Fatalf("got line %q", line)
+			}
+			messages++
+		}
+	}
+	if messages != 400 {
+		t.Errorf("got %d messages, want 400", messages)
+	}
+}